@@ -1,38 +1,61 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"maps"
 	"net/http"
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/google/go-github/github"
+	"github.com/Masterminds/semver/v3"
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/joshmeranda/chartsutil/pkg/auth"
+	"github.com/joshmeranda/chartsutil/pkg/cache"
 	"github.com/joshmeranda/chartsutil/pkg/display"
 	"github.com/joshmeranda/chartsutil/pkg/images"
 	"github.com/joshmeranda/chartsutil/pkg/iter"
 	"github.com/joshmeranda/chartsutil/pkg/rebase"
 	"github.com/joshmeranda/chartsutil/pkg/release"
+	"github.com/joshmeranda/chartsutil/pkg/resolve"
 	"github.com/rancher/charts-build-scripts/pkg/charts"
 	"github.com/rancher/charts-build-scripts/pkg/filesystem"
+	"github.com/rancher/charts-build-scripts/pkg/options"
 	chartspath "github.com/rancher/charts-build-scripts/pkg/path"
+	"github.com/rancher/charts-build-scripts/pkg/puller"
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v2"
 )
 
 const (
-	EnvPackage   = "PACKAGE"
-	EnvChartsDir = "CHARTS_DIR"
+	EnvPackage    = "PACKAGE"
+	EnvChartsDir  = "CHARTS_DIR"
+	EnvCacheDir   = "CHARTSUTIL_CACHE_DIR"
+	EnvAuthConfig = "CHARTSUTIL_AUTH_CONFIG"
 
 	CategoryPatternMatching = "Pattern Matching"
 	CategoryVerbosity       = "Verbosity"
 	CategoryUpstreamSpec    = "Upstream Specifications"
+	CategoryMerge           = "Merge Options"
+	CategoryIncrement       = "Incremental Steps"
 
 	ImageMirrorFileUrl = "https://raw.githubusercontent.com/rancher/image-mirror/master/images-list"
+
+	// ExitCodeOutdated is the exit code of 'upstream check --fail-if-outdated' when the package is behind its upstream, distinct from the exit code for errors.
+	ExitCodeOutdated = 2
 )
 
 var (
@@ -47,133 +70,1537 @@ func setup(ctx *cli.Context) error {
 	}
 
 	chartsDir := ctx.String("charts-dir")
-	absChartsDir, err := filepath.Abs(chartsDir)
+	absChartsDir, err := filepath.Abs(chartsDir)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path for charts dir: %w", err)
+	}
+
+	if err := ctx.Set("charts-dir", absChartsDir); err != nil {
+		return fmt.Errorf("failed to set absolute path for charts dir: %w", err)
+	}
+
+	var out io.Writer
+
+	out = os.Stdout
+	opts := &slog.HandlerOptions{}
+
+	switch {
+	case ctx.Bool("silent"):
+		out = io.Discard
+	case ctx.Bool("verbose"):
+		out = os.Stdout
+		opts.Level = slog.LevelDebug
+	}
+
+	logger = slog.New(slog.NewTextHandler(out, opts))
+
+	return nil
+}
+
+func pkgRebase(ctx *cli.Context) error {
+	pkgName, err := requirePackage(ctx)
+	if err != nil {
+		return err
+	}
+
+	chartsDir := ctx.String("charts-dir")
+	incremental := ctx.Bool("increment")
+	backup := ctx.Bool("backup")
+	imageNamespcae := ctx.String("image-namespace")
+
+	rootFs := filesystem.GetFilesystem(chartsDir)
+	pkgFs, err := rootFs.Chroot(filepath.Join(chartspath.RepositoryPackagesDir, pkgName))
+	if err != nil {
+		return fmt.Errorf("failed to chroot to package dir: %w", err)
+	}
+
+	pkg, err := charts.GetPackage(rootFs, pkgName)
+	if err != nil {
+		return fmt.Errorf("failed to get package '%s': %w", pkgName, err)
+	} else if pkg == nil {
+		return fmt.Errorf("failed to get package '%s': no such package", pkgName)
+	}
+
+	if pkg.Chart.Upstream, err = iter.PackageUpstream(pkgFs); err != nil {
+		return fmt.Errorf("failed to get upstream for package '%s': %w", pkgName, err)
+	}
+
+	authConfig, err := getAuthConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	upstreamCache, err := getCache(ctx, authConfig)
+	if err != nil {
+		return err
+	}
+
+	pkg.Chart.Upstream = iter.CachedPuller(pkg.Chart.Upstream, upstreamCache)
+
+	delta := iter.UpstreamDelta{}
+
+	if ctx.IsSet("commit") {
+		delta.Commit = rebase.ToPtr(ctx.String("commit"))
+	}
+
+	if ctx.IsSet("url") {
+		delta.URL = ctx.String("url")
+	}
+
+	if ctx.IsSet("subdirectory") {
+		delta.Subdirectory = rebase.ToPtr(ctx.String("subdirectory"))
+	}
+
+	upstreamUrl := pkg.Chart.Upstream.GetOptions().URL
+	if delta.URL != "" {
+		upstreamUrl = delta.URL
+	}
+
+	// non-git upstreams are rejected when the delta is applied
+	if delta.Commit != nil && iter.IsGitUpstream(upstreamUrl) {
+		hash, err := iter.ResolveUpstreamRef(ctx.Context, upstreamCache, upstreamUrl, *delta.Commit)
+		if err != nil {
+			return fmt.Errorf("failed to resolve commit '%s': %w", *delta.Commit, err)
+		}
+
+		delta.Commit = rebase.ToPtr(hash.String())
+	}
+
+	var upstreamIter iter.UpstreamIter
+
+	if incremental {
+		filter, err := stepFilterFromFlags(ctx)
+		if err != nil {
+			return err
+		}
+
+		batch, err := batchFromFlags(ctx)
+		if err != nil {
+			return err
+		}
+
+		upstreamIter, err = iter.IterForUpstream(pkg.Chart.Upstream, delta, iter.IterOptions{
+			Cache:      upstreamCache,
+			MergesOnly: ctx.Bool("merges-only"),
+			Filter:     filter,
+			Batch:      batch,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create puller iterator: %w", err)
+		}
+	} else {
+		upstreamIter, err = iter.NewSingleIter(pkg.Chart.Upstream, delta)
+		if err != nil {
+			return fmt.Errorf("failed to create single puller: %w", err)
+		}
+	}
+
+	if ctx.Bool("plan") {
+		return printPlan(upstreamIter)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to determine chartsutil executable: %w", err)
+	}
+
+	var resolver resolve.Resolver = &resolve.Shell{
+		Logger:        logger.WithGroup("shell"),
+		Package:       pkg,
+		HelperCommand: []string{exe, "--charts-dir", chartsDir, "--package", pkgName, "--silent", "shell", "--image-namespace", imageNamespcae},
+	}
+
+	switch ctx.String("resolver") {
+	case "shell":
+	case "hunk":
+		resolver = &resolve.HunkResolver{
+			Logger:   logger.WithGroup("hunk"),
+			Fallback: resolver,
+		}
+	case "mergetool":
+		resolver = &resolve.Mergetool{
+			Logger:   logger.WithGroup("mergetool"),
+			Fallback: resolver,
+		}
+	case "editor":
+		resolver = &resolve.Editor{
+			Logger:   logger.WithGroup("editor"),
+			Fallback: resolver,
+		}
+	default:
+		return fmt.Errorf("unknown resolver '%s'", ctx.String("resolver"))
+	}
+
+	opts := rebase.Options{
+		Logger:         logger,
+		Resolver:       resolver,
+		EnableBackup:   backup,
+		ImageNamespace: imageNamespcae,
+		Cache:          upstreamCache,
+		Merge: rebase.MergeOptions{
+			Strategy:        ctx.String("merge-strategy"),
+			StrategyOptions: ctx.StringSlice("merge-strategy-option"),
+			ConflictStyle:   ctx.String("conflict-style"),
+		},
+	}
+
+	rb, err := rebase.NewRebase(pkg, rootFs, pkgFs, upstreamIter, opts)
+	if err != nil {
+		return fmt.Errorf("invalid rebaser spec: %w", err)
+	}
+
+	newOpts, err := delta.Apply(pkg.Chart.Upstream.GetOptions())
+	if err != nil {
+		return fmt.Errorf("failed to apply upstream delta: %w", err)
+	}
+
+	logger.Info("attempting to rebase pacakge",
+		"pkg", rb.Package.Name,
+		"from", rebase.UpstreamRef(pkg.Chart.Upstream.GetOptions()),
+		"to", rebase.UpstreamRef(newOpts),
+		"incremental", incremental,
+	)
+
+	if err := rb.Rebase(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func stepFilterFromFlags(ctx *cli.Context) (iter.StepFilter, error) {
+	filter := iter.StepFilter{
+		IncludePaths:     ctx.StringSlice("include-path"),
+		ExcludePaths:     ctx.StringSlice("exclude-path"),
+		ChartVersionOnly: ctx.Bool("chart-version-only"),
+	}
+
+	patterns := map[string]**regexp.Regexp{
+		"include-message": &filter.IncludeMessage,
+		"exclude-message": &filter.ExcludeMessage,
+		"include-author":  &filter.IncludeAuthor,
+		"exclude-author":  &filter.ExcludeAuthor,
+	}
+
+	for flag, dst := range patterns {
+		if !ctx.IsSet(flag) {
+			continue
+		}
+
+		re, err := regexp.Compile(ctx.String(flag))
+		if err != nil {
+			return filter, fmt.Errorf("failed to compile --%s pattern: %w", flag, err)
+		}
+
+		*dst = re
+	}
+
+	return filter, nil
+}
+
+func batchFromFlags(ctx *cli.Context) (iter.Batch, error) {
+	batch := iter.Batch{
+		Step: ctx.Int("increment-step"),
+	}
+
+	if ctx.IsSet("increment-step") && batch.Step < 1 {
+		return batch, fmt.Errorf("--increment-step must be at least 1")
+	}
+
+	if ctx.IsSet("increment-window") {
+		if ctx.IsSet("increment-step") {
+			return batch, fmt.Errorf("--increment-step and --increment-window can not be used together")
+		}
+
+		window, err := display.ParseDuration(ctx.String("increment-window"))
+		if err != nil {
+			return batch, fmt.Errorf("failed to parse --increment-window: %w", err)
+		}
+
+		if window.IsZero() {
+			return batch, fmt.Errorf("--increment-window must not be zero")
+		}
+
+		batch.Window = window
+	}
+
+	return batch, nil
+}
+
+func printPlan(upstreamIter iter.UpstreamIter) error {
+	planner, ok := upstreamIter.(iter.Planner)
+	if !ok {
+		return fmt.Errorf("upstream steps can only be planned for incremental rebases of git upstreams")
+	}
+
+	if closer, ok := upstreamIter.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	plan, err := planner.Plan()
+	if err != nil {
+		return fmt.Errorf("failed to plan upstream steps: %w", err)
+	}
+
+	table := display.NewTable("Step", "Commit", "Author", "Summary")
+	for i, step := range plan {
+		summary := step.Summary
+		if step.Subdirectory != nil {
+			summary = fmt.Sprintf("%s (%s)", summary, *step.Subdirectory)
+		}
+
+		table.AddRow(fmt.Sprint(i+1), step.Hash[:7], step.Author, summary)
+
+		for _, folded := range step.Folded {
+			table.AddRow("", "+ "+folded.Hash[:7], folded.Author, folded.Summary)
+		}
+	}
+
+	fmt.Println(table.String())
+
+	return nil
+}
+
+func upstreamLog(ctx *cli.Context) error {
+	pkgName, err := requirePackage(ctx)
+	if err != nil {
+		return err
+	}
+
+	rootFs := filesystem.GetFilesystem(ctx.String("charts-dir"))
+
+	pkgFs, err := rootFs.Chroot(filepath.Join(chartspath.RepositoryPackagesDir, pkgName))
+	if err != nil {
+		return fmt.Errorf("failed to chroot to package dir: %w", err)
+	}
+
+	pkg, err := charts.GetPackage(rootFs, pkgName)
+	if err != nil {
+		return fmt.Errorf("failed to get package '%s': %w", pkgName, err)
+	} else if pkg == nil {
+		return fmt.Errorf("failed to get package '%s': no such package", pkgName)
+	}
+
+	if pkg.Chart.Upstream, err = iter.PackageUpstream(pkgFs); err != nil {
+		return fmt.Errorf("failed to get upstream for package '%s': %w", pkgName, err)
+	}
+
+	authConfig, err := getAuthConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	upstreamCache, err := getCache(ctx, authConfig)
+	if err != nil {
+		return err
+	}
+
+	pkg.Chart.Upstream = iter.CachedPuller(pkg.Chart.Upstream, upstreamCache)
+
+	upstreamUrl := pkg.Chart.Upstream.GetOptions().URL
+	if !iter.IsGitUpstream(upstreamUrl) {
+		return fmt.Errorf("upstream URL '%s' is not a git repository", upstreamUrl)
+	}
+
+	hash, err := iter.ResolveUpstreamRef(ctx.Context, upstreamCache, upstreamUrl, ctx.String("to"))
+	if err != nil {
+		return fmt.Errorf("failed to resolve '%s': %w", ctx.String("to"), err)
+	}
+
+	delta := iter.UpstreamDelta{
+		Commit: rebase.ToPtr(hash.String()),
+	}
+
+	if ctx.IsSet("subdirectory") {
+		delta.Subdirectory = rebase.ToPtr(ctx.String("subdirectory"))
+	}
+
+	filter, err := stepFilterFromFlags(ctx)
+	if err != nil {
+		return err
+	}
+
+	batch, err := batchFromFlags(ctx)
+	if err != nil {
+		return err
+	}
+
+	upstreamIter, err := iter.IterForUpstream(pkg.Chart.Upstream, delta, iter.IterOptions{
+		Cache:      upstreamCache,
+		MergesOnly: ctx.Bool("merges-only"),
+		Filter:     filter,
+		Batch:      batch,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create upstream iterator: %w", err)
+	}
+
+	gitIter, ok := upstreamIter.(*iter.GitIter)
+	if !ok {
+		return fmt.Errorf("upstream logs are only supported for git upstreams")
+	}
+	defer gitIter.Close()
+
+	var diffFiles []string
+	if ctx.Bool("diff") {
+		diffFiles = ctx.StringSlice("diff-file")
+	}
+
+	log, err := gitIter.Log(diffFiles...)
+	if err != nil {
+		return fmt.Errorf("failed to get upstream log: %w", err)
+	}
+
+	switch ctx.String("format") {
+	case "text":
+		printUpstreamLog(os.Stdout, log)
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+
+		if err := encoder.Encode(log); err != nil {
+			return fmt.Errorf("failed to encode upstream log: %w", err)
+		}
+	default:
+		return fmt.Errorf("unknown format '%s'", ctx.String("format"))
+	}
+
+	return nil
+}
+
+func diffstat(files []iter.FileStat) string {
+	stats := make(object.FileStats, len(files))
+	for i, f := range files {
+		stats[i] = object.FileStat{Name: f.Name, Addition: f.Additions, Deletion: f.Deletions}
+	}
+
+	return stats.String()
+}
+
+func printUpstreamLog(w io.Writer, log *iter.UpstreamLog) {
+	fmt.Fprintf(w, "upstream changes to %s from %.7s to %.7s (%d commits)\n\n", log.URL, log.From, log.To, len(log.Commits))
+
+	for _, c := range log.Commits {
+		folded := ""
+		if !c.Step {
+			folded = " (folded into the next step)"
+		}
+
+		fmt.Fprintf(w, "%.7s %s %s: %s%s\n", c.Hash, c.Date.Format(time.DateOnly), c.Author, c.Summary, folded)
+		fmt.Fprintln(w, diffstat(c.Files))
+	}
+
+	fmt.Fprintln(w, "total:")
+	fmt.Fprint(w, diffstat(log.Files))
+
+	for _, file := range slices.Sorted(maps.Keys(log.Diffs)) {
+		fmt.Fprintf(w, "\n%s", log.Diffs[file])
+	}
+}
+
+// upstreamChecker finds the releases of package upstreams newer than the version they are on.
+type upstreamChecker struct {
+	cache  *cache.Cache
+	client *http.Client
+
+	provider release.Provider
+	apiURL   string
+	helmRepo string
+}
+
+func newUpstreamChecker(ctx *cli.Context) (*upstreamChecker, error) {
+	// the config is loaded once so the cache and the api client cannot disagree on credentials
+	authConfig, err := getAuthConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	upstreamCache, err := getCache(ctx, authConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	apiClient := getAPIClient(upstreamCache, authConfig)
+
+	return &upstreamChecker{
+		cache:    upstreamCache,
+		client:   apiClient,
+		provider: release.Provider(ctx.String("provider")),
+		apiURL:   ctx.String("api-url"),
+		helmRepo: ctx.String("helm-repo"),
+	}, nil
+}
+
+// upstreamStatus is the version an upstream is on and its newer releases.
+type upstreamStatus struct {
+	// Kind is the kind of upstream, one of 'git', 'helm' or 'oci'.
+	Kind string
+
+	// Current is the ref the package pins, or the version of the chart archive.
+	Current string
+
+	// CurrentTag is the tag the package pins, or the tag with the highest version on the pinned commit.
+	CurrentTag     string
+	CurrentVersion *semver.Version
+	CurrentDate    time.Time
+
+	Releases []release.Release
+}
+
+// describeCurrent describes the version a package is on (ex. 'v1.2.3 (2024-05-01)'), using the pinned ref when it has no tag and omitting the date if it is zero.
+func describeCurrent(ref string, tag string, date time.Time) string {
+	current := tag
+	if current == "" {
+		current = ref
+	}
+
+	if plumbing.IsHash(current) {
+		current = current[:7]
+	}
+
+	if date.IsZero() {
+		return current
+	}
+
+	return fmt.Sprintf("%s (%s)", current, date.Format(time.DateOnly))
+}
+
+// check lists the releases matching the query which are newer than the upstream's current version.
+func (c *upstreamChecker) check(ctx context.Context, opts options.UpstreamOptions, query release.ReleaseQuery) (upstreamStatus, error) {
+	switch {
+	case opts.URL == "":
+		return upstreamStatus{}, fmt.Errorf("upstream URL is not set")
+	case iter.IsGitUpstream(opts.URL):
+		return c.checkGit(ctx, opts, query)
+	case release.IsOCIUpstream(opts.URL), strings.HasPrefix(opts.URL, "http://"), strings.HasPrefix(opts.URL, "https://"):
+		return c.checkArchive(ctx, opts, query)
+	default:
+		return upstreamStatus{}, fmt.Errorf("upstream '%s' is not a git repository, chart archive or OCI chart and has no releases", opts.URL)
+	}
+}
+
+// checkGit lists the releases published after the pinned commit or tag.
+func (c *upstreamChecker) checkGit(ctx context.Context, opts options.UpstreamOptions, query release.ReleaseQuery) (upstreamStatus, error) {
+	if opts.Commit == nil {
+		return upstreamStatus{}, fmt.Errorf("upstream commit is not set")
+	}
+
+	status := upstreamStatus{
+		Kind:    "git",
+		Current: *opts.Commit,
+	}
+
+	repo, err := c.cache.Repo(ctx, opts.URL, nil)
+	if err != nil {
+		return status, fmt.Errorf("failed to get cached upstream: %w", err)
+	}
+	defer repo.Close()
+
+	currentHash, err := iter.ResolveRef(repo.Repository, *opts.Commit)
+	if err != nil {
+		return status, fmt.Errorf("failed to resolve current upstream commit: %w", err)
+	}
+
+	var subdirectory string
+	if opts.Subdirectory != nil {
+		subdirectory = *opts.Subdirectory
+	}
+
+	source, err := release.NewSource(opts.URL, release.SourceOptions{
+		Provider:     c.provider,
+		BaseURL:      c.apiURL,
+		Client:       c.client,
+		Repo:         repo.Repository,
+		Subdirectory: subdirectory,
+		Cache:        c.cache,
+		Logger:       logger.WithGroup("release"),
+	})
+	if err != nil {
+		return status, fmt.Errorf("failed to get release source for upstream: %w", err)
+	}
+
+	// the package may pin a tag directly, otherwise use the highest version tagged on the pinned commit
+	if _, err := repo.Tag(*opts.Commit); err == nil {
+		status.CurrentTag = *opts.Commit
+	} else {
+		tags, err := release.TagsAt(repo.Repository, currentHash)
+		if err != nil {
+			return status, fmt.Errorf("failed to find tags for current upstream commit: %w", err)
+		}
+
+		status.CurrentTag = release.LatestTag(tags, query.NamePattern)
+	}
+
+	status.CurrentVersion = release.ParseVersion(status.CurrentTag)
+
+	// use the date of the current release if there is one, otherwise the date of the pinned commit
+	if status.CurrentTag != "" {
+		current, err := source.GetRelease(ctx, status.CurrentTag)
+		if err != nil {
+			logger.Warn("failed to fetch release for current tag, using commit date", "err", err, "tag", status.CurrentTag)
+		}
+
+		status.CurrentDate = current.Date
+	}
+
+	if status.CurrentDate.IsZero() {
+		commit, err := repo.CommitObject(currentHash)
+		if err != nil {
+			return status, fmt.Errorf("failed to find commit for hash: %w", err)
+		}
+
+		status.CurrentDate = commit.Committer.When
+	}
+
+	// releases may be cut from commits before the pinned commit and published after it, so they are compared by version, or by commit if the pinned commit has no version
+	if status.CurrentVersion != nil {
+		query.NewerThan = status.CurrentVersion
+	}
+
+	logger.Info("checking for upstream releases", "url", opts.URL, "query", query)
+	if status.Releases, err = release.ReleasesForUpstream(ctx, source, query); err != nil {
+		return status, fmt.Errorf("failed to list upstream releases: %w", err)
+	}
+
+	if status.CurrentVersion == nil {
+		if status.Releases, err = release.ExcludeAncestors(repo.Repository, currentHash, status.CurrentDate, status.Releases); err != nil {
+			return status, fmt.Errorf("failed to exclude releases in current upstream commit: %w", err)
+		}
+	}
+
+	return status, nil
+}
+
+// checkArchive lists the chart versions higher than the version of the chart archive, found in the Helm repository or OCI registry serving it.
+func (c *upstreamChecker) checkArchive(ctx context.Context, opts options.UpstreamOptions, query release.ReleaseQuery) (upstreamStatus, error) {
+	var status upstreamStatus
+	var source release.ReleaseSource
+
+	if release.IsOCIUpstream(opts.URL) {
+		registry, repository, tag, err := release.ParseOCIRef(opts.URL)
+		if err != nil {
+			return status, err
+		}
+
+		status.Kind = "oci"
+		status.Current = tag
+		source = &release.OCISource{Client: c.client, Registry: registry, Repository: repository}
+	} else {
+		candidates, err := release.HelmRepoCandidates(opts.URL)
+		if err != nil {
+			return status, err
+		}
+
+		if c.helmRepo != "" {
+			candidates = []string{c.helmRepo}
+		}
+
+		helmSource, version, err := release.FindHelmRepo(ctx, c.client, opts.URL, candidates)
+		if err != nil {
+			return status, err
+		}
+
+		status.Kind = "helm"
+		status.Current = version
+		source = helmSource
+	}
+
+	if status.Current == "" {
+		return status, fmt.Errorf("could not determine the chart version of '%s'", opts.URL)
+	}
+
+	if current, err := source.GetRelease(ctx, status.Current); err != nil {
+		logger.Warn("failed to fetch current chart version", "err", err, "version", status.Current)
+	} else {
+		status.CurrentDate = current.Date
+	}
+
+	status.CurrentVersion = release.ParseVersion(status.Current)
+	if status.CurrentVersion == nil {
+		return status, fmt.Errorf("chart version '%s' is not a semantic version", status.Current)
+	}
+
+	query.NewerThan = status.CurrentVersion
+
+	logger.Info("checking for upstream chart versions", "url", opts.URL, "query", query)
+
+	var err error
+	if status.Releases, err = release.ReleasesForUpstream(ctx, source, query); err != nil {
+		return status, fmt.Errorf("failed to list upstream chart versions: %w", err)
+	}
+
+	return status, nil
+}
+
+// releaseQueryFromFlags builds the query for upstream releases from the pattern matching flags.
+func releaseQueryFromFlags(ctx *cli.Context) (release.ReleaseQuery, error) {
+	query := release.ReleaseQuery{
+		IncludePrereleases: ctx.Bool("include-prereleases"),
+	}
+
+	releaseNamePattern := ctx.String("prefix") + ctx.String("pattern") + ctx.String("postfix")
+
+	var err error
+	if query.NamePattern, err = regexp.Compile(releaseNamePattern); err != nil {
+		return query, fmt.Errorf("failed to compile tag pattern: %w", err)
+	}
+
+	if c := ctx.String("constraint"); c != "" {
+		if query.Constraint, err = semver.NewConstraint(c); err != nil {
+			return query, fmt.Errorf("failed to parse version constraint: %w", err)
+		}
+	}
+
+	return query, nil
+}
+
+// checkedRelease is a release listed by 'upstream check'.
+type checkedRelease struct {
+	Name         string     `json:"name" yaml:"name"`
+	Tag          string     `json:"tag" yaml:"tag"`
+	ChartVersion string     `json:"chartVersion,omitempty" yaml:"chartVersion,omitempty"`
+	AppVersion   string     `json:"appVersion,omitempty" yaml:"appVersion,omitempty"`
+	Bump         string     `json:"bump,omitempty" yaml:"bump,omitempty"`
+	Date         *time.Time `json:"date,omitempty" yaml:"date,omitempty"`
+	Age          string     `json:"age,omitempty" yaml:"age,omitempty"`
+	Hash         string     `json:"hash,omitempty" yaml:"hash,omitempty"`
+}
+
+// checkedCommit is an upstream commit changing the chart listed by 'upstream check --commits'.
+type checkedCommit struct {
+	Hash      string    `json:"hash" yaml:"hash"`
+	Author    string    `json:"author" yaml:"author"`
+	Date      time.Time `json:"date" yaml:"date"`
+	Subject   string    `json:"subject" yaml:"subject"`
+	Files     int       `json:"files" yaml:"files"`
+	Additions int       `json:"additions" yaml:"additions"`
+	Deletions int       `json:"deletions" yaml:"deletions"`
+}
+
+// Changes summarizes the files changed by the commit (ex. '3 files, +10 -2').
+func (c checkedCommit) Changes() string {
+	files := "files"
+	if c.Files == 1 {
+		files = "file"
+	}
+
+	return fmt.Sprintf("%d %s, +%d -%d", c.Files, files, c.Additions, c.Deletions)
+}
+
+func newCheckedCommit(entry iter.LogEntry) checkedCommit {
+	c := checkedCommit{
+		Hash:    entry.Hash,
+		Author:  entry.Author,
+		Date:    entry.Date,
+		Subject: entry.Summary,
+		Files:   len(entry.Files),
+	}
+
+	for _, f := range entry.Files {
+		c.Additions += f.Additions
+		c.Deletions += f.Deletions
+	}
+
+	return c
+}
+
+// upstreamCheckResult is the output of 'upstream check' in machine readable formats.
+type upstreamCheckResult struct {
+	Package     string     `json:"package" yaml:"package"`
+	Kind        string     `json:"kind" yaml:"kind"`
+	Current     string     `json:"current" yaml:"current"`
+	CurrentTag  string     `json:"currentTag,omitempty" yaml:"currentTag,omitempty"`
+	CurrentDate *time.Time `json:"currentDate,omitempty" yaml:"currentDate,omitempty"`
+
+	// Head is the commit of the upstream branch the commits were listed up to, only set with --commits.
+	Head string `json:"head,omitempty" yaml:"head,omitempty"`
+
+	// Outdated is true if there are newer releases (or commits), which are older than --older-than if it is set.
+	Outdated bool `json:"outdated" yaml:"outdated"`
+
+	Releases []checkedRelease `json:"releases,omitempty" yaml:"releases,omitempty"`
+	Commits  []checkedCommit  `json:"commits,omitempty" yaml:"commits,omitempty"`
+}
+
+// isOutdated returns true if any of the dates are before the cutoff, or if there are any dates when the cutoff is zero. Zero dates are never before the cutoff.
+func isOutdated(dates []time.Time, cutoff time.Time) bool {
+	if cutoff.IsZero() {
+		return len(dates) > 0
+	}
+
+	return slices.ContainsFunc(dates, func(d time.Time) bool {
+		return !d.IsZero() && d.Before(cutoff)
+	})
+}
+
+// commits lists the commits on the upstream branch after the pinned commit which change the chart, using the upstream's default branch if branch is empty.
+func (c *upstreamChecker) commits(ctx context.Context, upstream puller.Puller, branch string) (*iter.UpstreamLog, error) {
+	opts := upstream.GetOptions()
+	if !iter.IsGitUpstream(opts.URL) {
+		return nil, fmt.Errorf("upstream '%s' is not a git repository and has no commits", opts.URL)
+	}
+
+	head, err := func() (plumbing.Hash, error) {
+		repo, err := c.cache.Repo(ctx, opts.URL, nil)
+		if err != nil {
+			return plumbing.ZeroHash, fmt.Errorf("failed to get cached upstream: %w", err)
+		}
+		defer repo.Close()
+
+		if branch == "" {
+			return repo.DefaultBranch()
+		}
+
+		return iter.ResolveRef(repo.Repository, branch)
+	}()
+	if err != nil {
+		return nil, err
+	}
+
+	upstreamIter, err := iter.IterForUpstream(iter.CachedPuller(upstream, c.cache), iter.UpstreamDelta{Commit: rebase.ToPtr(head.String())}, iter.IterOptions{
+		Cache: c.cache,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create upstream iterator: %w", err)
+	}
+
+	gitIter, ok := upstreamIter.(*iter.GitIter)
+	if !ok {
+		return nil, fmt.Errorf("upstream commits are only supported for git upstreams")
+	}
+	defer gitIter.Close()
+
+	log, err := gitIter.Log()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get upstream log: %w", err)
+	}
+
+	// the head of the branch is always a step, even when it does not change the chart
+	if n := len(log.Commits); n > 0 && log.Commits[n-1].Hash == log.To && len(log.Commits[n-1].Files) == 0 {
+		log.Commits = log.Commits[:n-1]
+	}
+
+	return log, nil
+}
+
+func upstreamCheck(ctx *cli.Context) error {
+	pkgName, err := requirePackage(ctx)
+	if err != nil {
+		return err
+	}
+
+	rootFs := filesystem.GetFilesystem(ctx.String("charts-dir"))
+
+	format := ctx.String("format")
+	if !slices.Contains([]string{"table", "json", "yaml"}, format) {
+		return fmt.Errorf("unknown format '%s'", format)
+	}
+
+	now := time.Now()
+
+	var cutoff time.Time
+	if ctx.IsSet("older-than") {
+		olderThan, err := display.ParseDuration(ctx.String("older-than"))
+		if err != nil {
+			return fmt.Errorf("failed to parse --older-than: %w", err)
+		}
+
+		cutoff = now.Add(-olderThan.Approximate())
+	}
+
+	query, err := releaseQueryFromFlags(ctx)
+	if err != nil {
+		return err
+	}
+
+	pkgFs, err := rootFs.Chroot(filepath.Join(chartspath.RepositoryPackagesDir, pkgName))
+	if err != nil {
+		return fmt.Errorf("failed to chroot to package dir: %w", err)
+	}
+
+	upstream, err := iter.PackageUpstream(pkgFs)
+	if err != nil {
+		return fmt.Errorf("failed to get upstream for package '%s': %w", pkgName, err)
+	}
+
+	checker, err := newUpstreamChecker(ctx)
+	if err != nil {
+		return err
+	}
+
+	if ctx.Bool("commits") {
+		log, err := checker.commits(ctx.Context, upstream, ctx.String("branch"))
+		if err != nil {
+			return err
+		}
+
+		result := upstreamCheckResult{
+			Package: pkgName,
+			Kind:    "git",
+			Current: log.From,
+			Head:    log.To,
+			Commits: make([]checkedCommit, 0, len(log.Commits)),
+		}
+
+		dates := make([]time.Time, 0, len(log.Commits))
+		for _, entry := range log.Commits {
+			result.Commits = append(result.Commits, newCheckedCommit(entry))
+			dates = append(dates, entry.Date)
+		}
+
+		result.Outdated = isOutdated(dates, cutoff)
+
+		return printUpstreamCheck(ctx, format, result)
+	}
+
+	status, err := checker.check(ctx.Context, upstream.GetOptions(), query)
+	if err != nil {
+		return err
+	}
+
+	result := upstreamCheckResult{
+		Package:    pkgName,
+		Kind:       status.Kind,
+		Current:    status.Current,
+		CurrentTag: status.CurrentTag,
+		Releases:   make([]checkedRelease, 0, len(status.Releases)),
+	}
+
+	dates := make([]time.Time, 0, len(status.Releases))
+	for _, r := range status.Releases {
+		dates = append(dates, r.Date)
+	}
+
+	result.Outdated = isOutdated(dates, cutoff)
+
+	if !status.CurrentDate.IsZero() {
+		result.CurrentDate = &status.CurrentDate
+	}
+
+	for _, r := range status.Releases {
+		checked := checkedRelease{
+			Name:         r.Name,
+			Tag:          r.Tag,
+			ChartVersion: r.ChartVersion,
+			AppVersion:   r.AppVersion,
+			Bump:         string(release.BumpKind(status.CurrentVersion, r.Version())),
+			Hash:         r.Hash,
+		}
+
+		if !r.Date.IsZero() {
+			checked.Date = &r.Date
+			checked.Age = display.NewDuration(now.Sub(r.Date)).Round().String()
+		}
+
+		result.Releases = append(result.Releases, checked)
+	}
+
+	return printUpstreamCheck(ctx, format, result)
+}
+
+// printUpstreamCheck prints the result in the format, returning an exit error with ExitCodeOutdated if the package is outdated and --fail-if-outdated is set.
+func printUpstreamCheck(ctx *cli.Context, format string, result upstreamCheckResult) error {
+	switch format {
+	case "table":
+		if ctx.Bool("commits") {
+			table := display.NewTable("Hash", "Date", "Author", "Subject", "Changes")
+			for _, c := range result.Commits {
+				table.AddRow(c.Hash[:7], c.Date.Format(time.DateOnly), c.Author, c.Subject, c.Changes())
+			}
+
+			fmt.Println(table.String())
+			fmt.Printf("%d commits changing the chart since %.7s\n", len(result.Commits), result.Current)
+			break
+		}
+
+		var currentDate time.Time
+		if result.CurrentDate != nil {
+			currentDate = *result.CurrentDate
+		}
+
+		fmt.Printf("current: %s\n\n", describeCurrent(result.Current, result.CurrentTag, currentDate))
+
+		table := display.NewTable("Name", "Chart Version", "App Version", "Bump", "Age", "Hash")
+		for _, r := range result.Releases {
+			table.AddRow(r.Name, r.ChartVersion, r.AppVersion, r.Bump, r.Age, r.Hash)
+		}
+
+		fmt.Println(table.String())
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+
+		if err := encoder.Encode(result); err != nil {
+			return fmt.Errorf("failed to encode upstream check: %w", err)
+		}
+	case "yaml":
+		data, err := yaml.Marshal(result)
+		if err != nil {
+			return fmt.Errorf("failed to encode upstream check: %w", err)
+		}
+
+		fmt.Print(string(data))
+	}
+
+	if ctx.Bool("fail-if-outdated") && result.Outdated {
+		if ctx.Bool("commits") {
+			return cli.Exit(fmt.Sprintf("package '%s' is behind its upstream by %d commit(s)", result.Package, len(result.Commits)), ExitCodeOutdated)
+		}
+
+		return cli.Exit(fmt.Sprintf("package '%s' is behind its upstream by %d release(s)", result.Package, len(result.Releases)), ExitCodeOutdated)
+	}
+
+	return nil
+}
+
+// changelog reads the sections of the changelog in the upstream's subdirectory at the target ref which describe the versions after the current version, through the target version.
+func (c *upstreamChecker) changelog(ctx context.Context, opts options.UpstreamOptions, status upstreamStatus, target string, through *semver.Version) ([]release.ChangelogSection, error) {
+	repo, err := c.cache.Repo(ctx, opts.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cached upstream: %w", err)
+	}
+	defer repo.Close()
+
+	hash, err := iter.ResolveRef(repo.Repository, target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve '%s': %w", target, err)
+	}
+
+	commit, err := repo.CommitObject(hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find commit for '%s': %w", target, err)
+	}
+
+	var subdirectory string
+	if opts.Subdirectory != nil {
+		subdirectory = *opts.Subdirectory
+	}
+
+	contents, err := release.ReadChangelog(commit, subdirectory)
+	if err != nil {
+		return nil, err
+	}
+
+	sections := make([]release.ChangelogSection, 0)
+
+	for _, section := range release.ParseChangelog(contents) {
+		if status.CurrentVersion != nil && !section.Version.GreaterThan(status.CurrentVersion) {
+			continue
+		}
+
+		if section.Version.GreaterThan(through) {
+			continue
+		}
+
+		sections = append(sections, section)
+	}
+
+	return sections, nil
+}
+
+// targetVersion returns the version of the upstream at target, which may be any ref of a git upstream (ex. a branch, commit or tag) or a version.
+func (c *upstreamChecker) targetVersion(ctx context.Context, opts options.UpstreamOptions, target string, pattern *regexp.Regexp) (*semver.Version, error) {
+	if iter.IsGitUpstream(opts.URL) {
+		repo, err := c.cache.Repo(ctx, opts.URL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get cached upstream: %w", err)
+		}
+		defer repo.Close()
+
+		var subdirectory string
+		if opts.Subdirectory != nil {
+			subdirectory = *opts.Subdirectory
+		}
+
+		version, err := release.VersionAt(repo.Repository, target, subdirectory, pattern)
+		switch {
+		case err == nil && version == nil:
+			return nil, fmt.Errorf("could not find the version of '%s', it has no version tag or chart version", target)
+		case err == nil:
+			return version, nil
+		case !errors.Is(err, iter.ErrUnknownRef):
+			return nil, fmt.Errorf("failed to find the version of '%s': %w", target, err)
+		}
+	}
+
+	if version := release.ParseVersion(target); version != nil {
+		return version, nil
+	}
+
+	return nil, fmt.Errorf("target '%s' is not a version or a ref of the upstream", target)
+}
+
+// releaseNotes are the notes of a single upstream version, from its release and the upstream's changelog.
+type releaseNotes struct {
+	Name      string
+	Date      time.Time
+	Version   *semver.Version
+	Body      string
+	Changelog string
+}
+
+// collectReleaseNotes pairs the releases with the changelog sections of the same version, from the highest version to the lowest.
+func collectReleaseNotes(releases []release.Release, changelog []release.ChangelogSection) []releaseNotes {
+	notes := make([]releaseNotes, 0, len(releases))
+	paired := make(map[int]bool)
+
+	for _, r := range releases {
+		n := releaseNotes{Name: r.Name, Date: r.Date, Version: r.Version(), Body: r.Body}
+
+		for i, section := range changelog {
+			if n.Version != nil && section.Version.Equal(n.Version) {
+				n.Changelog = section.Body
+				paired[i] = true
+			}
+		}
+
+		notes = append(notes, n)
+	}
+
+	// versions may be in the changelog without having been released
+	for i, section := range changelog {
+		if !paired[i] {
+			notes = append(notes, releaseNotes{Name: section.Heading, Version: section.Version, Changelog: section.Body})
+		}
+	}
+
+	slices.SortStableFunc(notes, func(a, b releaseNotes) int {
+		if a.Version == nil || b.Version == nil {
+			return 0
+		}
+
+		return b.Version.Compare(a.Version)
+	})
+
+	return notes
+}
+
+var (
+	// markdownListItem captures the list marker of a markdown line, so the rest of the line can be emphasized.
+	markdownListItem = regexp.MustCompile(`^(\s*(?:[-*+]|[0-9]+\.)\s+)?(.*?)\s*$`)
+
+	// markdownHeadingLevel matches the start of a markdown heading.
+	markdownHeadingLevel = regexp.MustCompile(`^#{1,6}\s`)
+)
+
+// formatNotes demotes the headings in notes below the heading of their release, and emphasizes the lines matching the breaking change pattern.
+func formatNotes(notes string, breaking *regexp.Regexp) string {
+	lines := strings.Split(strings.TrimSpace(notes), "\n")
+
+	for i, line := range lines {
+		switch {
+		case markdownHeadingLevel.MatchString(line):
+			level := len(line) - len(strings.TrimLeft(line, "#"))
+			lines[i] = strings.Repeat("#", min(level+3, 6)-level) + line
+		case breaking.MatchString(line):
+			m := markdownListItem.FindStringSubmatch(line)
+			lines[i] = fmt.Sprintf("%s**%s**", m[1], m[2])
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func printReleaseNotes(w io.Writer, pkgName string, status upstreamStatus, target string, notes []releaseNotes, breaking *regexp.Regexp) {
+	fmt.Fprintf(w, "# Upstream changes to %s from %s to %s\n", pkgName, status.Current, target)
+
+	fmt.Fprint(w, "\n## Potentially Breaking Changes\n\n")
+
+	found := false
+	for _, n := range notes {
+		for _, line := range release.BreakingChanges(n.Body+"\n"+n.Changelog, breaking) {
+			fmt.Fprintf(w, "- **%s**: %s\n", n.Name, strings.TrimLeft(line, "-*+ "))
+			found = true
+		}
+	}
+
+	if !found {
+		fmt.Fprintln(w, "None found.")
+	}
+
+	for _, n := range notes {
+		fmt.Fprintf(w, "\n## %s", n.Name)
+		if !n.Date.IsZero() {
+			fmt.Fprintf(w, " (%s)", n.Date.Format(time.DateOnly))
+		}
+		fmt.Fprint(w, "\n")
+
+		if n.Body != "" {
+			fmt.Fprintf(w, "\n%s\n", formatNotes(n.Body, breaking))
+		}
+
+		if n.Changelog != "" {
+			fmt.Fprintf(w, "\n### %s\n\n%s\n", release.ChangelogFileName, formatNotes(n.Changelog, breaking))
+		}
+
+		if n.Body == "" && n.Changelog == "" {
+			fmt.Fprint(w, "\nNo release notes.\n")
+		}
+	}
+}
+
+func upstreamNotes(ctx *cli.Context) error {
+	pkgName, err := requirePackage(ctx)
+	if err != nil {
+		return err
+	}
+
+	rootFs := filesystem.GetFilesystem(ctx.String("charts-dir"))
+
+	breaking, err := regexp.Compile(ctx.String("breaking-pattern"))
+	if err != nil {
+		return fmt.Errorf("failed to compile --breaking-pattern: %w", err)
+	}
+
+	query, err := releaseQueryFromFlags(ctx)
+	if err != nil {
+		return err
+	}
+
+	pkgFs, err := rootFs.Chroot(filepath.Join(chartspath.RepositoryPackagesDir, pkgName))
+	if err != nil {
+		return fmt.Errorf("failed to chroot to package dir: %w", err)
+	}
+
+	upstream, err := iter.PackageUpstream(pkgFs)
+	if err != nil {
+		return fmt.Errorf("failed to get upstream for package '%s': %w", pkgName, err)
+	}
+
+	checker, err := newUpstreamChecker(ctx)
+	if err != nil {
+		return err
+	}
+
+	opts := upstream.GetOptions()
+
+	target := ctx.String("to")
+	if query.Through, err = checker.targetVersion(ctx.Context, opts, target, query.NamePattern); err != nil {
+		return err
+	}
+
+	status, err := checker.check(ctx.Context, opts, query)
+	if err != nil {
+		return err
+	}
+
+	var changelog []release.ChangelogSection
+	if status.Kind == "git" {
+		if changelog, err = checker.changelog(ctx.Context, opts, status, target, query.Through); err != nil {
+			logger.Warn("failed to read upstream changelog", "err", err)
+		}
+	}
+
+	printReleaseNotes(os.Stdout, pkgName, status, target, collectReleaseNotes(status.Releases, changelog), breaking)
+
+	return nil
+}
+
+// packageReport is how far a single package is behind its upstream.
+type packageReport struct {
+	Package string `json:"package"`
+	Kind    string `json:"kind,omitempty"`
+	Current string `json:"current,omitempty"`
+
+	// Latest is the newest release after the current version, or empty if the package is up to date.
+	Latest string `json:"latest,omitempty"`
+
+	// Behind is the number of releases after the current version.
+	Behind int `json:"behind"`
+
+	CurrentDate *time.Time `json:"currentDate,omitempty"`
+	Age         string     `json:"age,omitempty"`
+
+	Error string `json:"error,omitempty"`
+}
+
+// findPackages lists the packages in the repository, which are the directories under packages/ with a package.yaml. Packages may be nested under other directories (ex. packages/rancher-monitoring/rancher-monitoring-crd).
+func findPackages(chartsDir string) ([]string, error) {
+	packagesDir := filepath.Join(chartsDir, chartspath.RepositoryPackagesDir)
+	packages := make([]string, 0)
+
+	err := filepath.WalkDir(packagesDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !d.IsDir() {
+			return nil
+		}
+
+		if _, err := os.Stat(filepath.Join(path, chartspath.PackageOptionsFile)); err != nil {
+			return nil
+		}
+
+		rel, err := filepath.Rel(packagesDir, path)
+		if err != nil {
+			return err
+		}
+
+		packages = append(packages, rel)
+
+		return filepath.SkipDir
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find packages: %w", err)
+	}
+
+	return packages, nil
+}
+
+// reportPackages checks the upstream of each package, running no more than concurrency checks at once. The reports are in the same order as the packages.
+func reportPackages(ctx context.Context, rootFs billy.Filesystem, checker *upstreamChecker, query release.ReleaseQuery, packages []string, concurrency int) []packageReport {
+	reports := make([]packageReport, len(packages))
+	now := time.Now()
+
+	jobs := make(chan int)
+	wg := sync.WaitGroup{}
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := range jobs {
+				reports[i] = reportPackage(ctx, rootFs, checker, query, packages[i], now)
+			}
+		}()
+	}
+
+	for i := range packages {
+		jobs <- i
+	}
+	close(jobs)
+
+	wg.Wait()
+
+	return reports
+}
+
+func reportPackage(ctx context.Context, rootFs billy.Filesystem, checker *upstreamChecker, query release.ReleaseQuery, pkgName string, now time.Time) packageReport {
+	report := packageReport{Package: pkgName}
+
+	pkgFs, err := rootFs.Chroot(filepath.Join(chartspath.RepositoryPackagesDir, pkgName))
+	if err != nil {
+		report.Error = fmt.Sprintf("failed to chroot to package dir: %s", err)
+		return report
+	}
+
+	upstream, err := iter.PackageUpstream(pkgFs)
+	if err != nil {
+		report.Error = fmt.Sprintf("failed to get upstream: %s", err)
+		return report
+	}
+
+	status, err := checker.check(ctx, upstream.GetOptions(), query)
+
+	report.Kind = status.Kind
+	report.Current = describeCurrent(status.Current, status.CurrentTag, time.Time{})
+
+	if err != nil {
+		report.Error = err.Error()
+		return report
+	}
+
+	if len(status.Releases) > 0 {
+		report.Latest = status.Releases[0].Name
+	}
+
+	report.Behind = len(status.Releases)
+
+	if !status.CurrentDate.IsZero() {
+		report.CurrentDate = &status.CurrentDate
+		report.Age = display.NewDuration(now.Sub(status.CurrentDate)).Round().String()
+	}
+
+	return report
+}
+
+func upstreamReport(ctx *cli.Context) error {
+	chartsDir := ctx.String("charts-dir")
+	rootFs := filesystem.GetFilesystem(chartsDir)
+
+	concurrency := ctx.Int("concurrency")
+	if concurrency < 1 {
+		return fmt.Errorf("--concurrency must be at least 1")
+	}
+
+	query, err := releaseQueryFromFlags(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to get absolute path for charts dir: %w", err)
+		return err
 	}
 
-	if err := ctx.Set("charts-dir", absChartsDir); err != nil {
-		return fmt.Errorf("failed to set absolute path for charts dir: %w", err)
+	packages, err := findPackages(chartsDir)
+	if err != nil {
+		return err
 	}
 
-	var out io.Writer
+	checker, err := newUpstreamChecker(ctx)
+	if err != nil {
+		return err
+	}
 
-	out = os.Stdout
-	opts := &slog.HandlerOptions{}
+	reports := reportPackages(ctx.Context, rootFs, checker, query, packages, concurrency)
 
-	switch {
-	case ctx.Bool("silent"):
-		out = io.Discard
-	case ctx.Bool("verbose"):
-		out = os.Stdout
-		opts.Level = slog.LevelDebug
+	table := display.NewTable("Package", "Kind", "Current", "Latest", "Behind", "Age", "Error")
+	for _, r := range reports {
+		table.AddRow(r.Package, r.Kind, r.Current, r.Latest, fmt.Sprint(r.Behind), r.Age, r.Error)
 	}
 
-	logger = slog.New(slog.NewTextHandler(out, opts))
+	switch ctx.String("format") {
+	case "table":
+		fmt.Println(table.String())
+	case "markdown":
+		fmt.Print(table.Markdown())
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+
+		if err := encoder.Encode(reports); err != nil {
+			return fmt.Errorf("failed to encode upstream report: %w", err)
+		}
+	default:
+		return fmt.Errorf("unknown format '%s'", ctx.String("format"))
+	}
 
 	return nil
 }
 
-func pkgRebase(ctx *cli.Context) error {
+// requirePackage returns the package given by --package, which is required by all commands working on a single package.
+func requirePackage(ctx *cli.Context) (string, error) {
 	pkgName := ctx.String("package")
-	chartsDir := ctx.String("charts-dir")
-	incremental := ctx.Bool("increment")
-	backup := ctx.Bool("backup")
-	imageNamespcae := ctx.String("image-namespace")
-
-	rootFs := filesystem.GetFilesystem(chartsDir)
-	pkgFs, err := rootFs.Chroot(filepath.Join(chartspath.RepositoryPackagesDir, pkgName))
-	if err != nil {
-		return fmt.Errorf("failed to chroot to package dir: %w", err)
+	if pkgName == "" {
+		return "", fmt.Errorf("required flag \"package\" not set")
 	}
 
-	gitRoot, err := os.MkdirTemp(os.TempDir(), "chart-utils-")
-	if err != nil {
-		return fmt.Errorf("failed to create temporary directory: %w", err)
+	return pkgName, nil
+}
+
+// getAuthConfig loads the credential config from --auth-config, or the default path if unset.
+func getAuthConfig(ctx *cli.Context) (*auth.Config, error) {
+	path := ctx.String("auth-config")
+	if path == "" {
+		var err error
+		if path, err = auth.DefaultConfigPath(); err != nil {
+			return nil, err
+		}
 	}
-	defer os.RemoveAll(gitRoot)
 
-	pkg, err := charts.GetPackage(rootFs, pkgName)
-	if err != nil {
-		return fmt.Errorf("failed to get package '%s': %w", pkgName, err)
-	} else if pkg == nil {
-		return fmt.Errorf("failed to get package '%s': no such package", pkgName)
+	return auth.LoadConfig(path)
+}
+
+// getCache returns the upstream cache in --cache-dir, or the default cache if unset, which authenticates with authConfig.
+func getCache(ctx *cli.Context, authConfig *auth.Config) (*cache.Cache, error) {
+	c := &cache.Cache{Root: ctx.String("cache-dir")}
+
+	if c.Root == "" {
+		var err error
+		if c, err = cache.Default(); err != nil {
+			return nil, fmt.Errorf("failed to get upstream cache: %w", err)
+		}
 	}
 
-	delta := iter.UpstreamDelta{}
+	c.Auth = authConfig.GitAuth
+	c.Client = authConfig.HTTPClient()
 
-	if ctx.IsSet("commit") {
-		delta.Commit = rebase.ToPtr(ctx.String("commit"))
+	return c, nil
+}
+
+// getAPIClient returns the client for provider apis, which authenticates requests, waits out rate limits and revalidates responses stored in the cache.
+func getAPIClient(c *cache.Cache, authConfig *auth.Config) *http.Client {
+	rateLimited := &release.RateLimitTransport{
+		Base:   c.Transport(nil),
+		Logger: logger.WithGroup("api"),
 	}
 
-	if ctx.IsSet("url") {
-		delta.URL = ctx.String("url")
+	return &http.Client{Transport: authConfig.Transport(rateLimited)}
+}
+
+// parseOlderThan parses durations like those of upstream check (ex. 30d), also accepting go durations (ex. 720h0m0s) which cache prune took before.
+func parseOlderThan(s string) (time.Duration, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return d, nil
 	}
 
-	if ctx.IsSet("subdirectory") {
-		delta.Subdirectory = rebase.ToPtr(ctx.String("subdirectory"))
+	d, err := display.ParseDuration(s)
+	if err != nil {
+		return 0, err
 	}
 
-	var upstreamIter iter.UpstreamIter
+	return d.Approximate(), nil
+}
 
-	if incremental {
-		upstreamIter, err = iter.IterForUpstream(pkg.Chart.Upstream, delta)
-		if err != nil {
-			return fmt.Errorf("failed to create puller iterator: %w", err)
-		}
-	} else {
-		upstreamIter, err = iter.NewSingleIter(pkg.Chart.Upstream, delta)
-		if err != nil {
-			return fmt.Errorf("failed to create single puller: %w", err)
-		}
+func cachePrune(ctx *cli.Context) error {
+	authConfig, err := getAuthConfig(ctx)
+	if err != nil {
+		return err
 	}
 
-	opts := rebase.Options{
-		Logger:         logger,
-		EnableBackup:   backup,
-		ImageNamespace: imageNamespcae,
+	c, err := getCache(ctx, authConfig)
+	if err != nil {
+		return err
 	}
 
-	rb, err := rebase.NewRebase(pkg, rootFs, pkgFs, upstreamIter, opts)
+	olderThan, err := parseOlderThan(ctx.String("older-than"))
 	if err != nil {
-		return fmt.Errorf("invalid rebaser spec: %w", err)
+		return fmt.Errorf("failed to parse --older-than: %w", err)
 	}
 
-	newOpts, err := delta.Apply(pkg.Chart.Upstream.GetOptions())
+	before := time.Now().Add(-olderThan)
+	if ctx.Bool("all") {
+		before = time.Now()
+	}
+
+	pruned, err := c.Prune(before)
+	for _, entry := range pruned {
+		logger.Info("pruned cache entry", "url", entry.URL, "last-used", entry.LastUsed)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to apply upstream delta: %w", err)
+		return fmt.Errorf("failed to prune cache: %w", err)
 	}
 
-	logger.Info("attempting to rebase pacakge",
-		"pkg", rb.Package.Name,
-		"from", rebase.UpstreamRef(pkg.Chart.Upstream.GetOptions()),
-		"to", rebase.UpstreamRef(newOpts),
-		"incremental", incremental,
-	)
+	return nil
+}
 
-	if err := rb.Rebase(); err != nil {
+func shellConflicts(ctx *cli.Context) error {
+	files, err := resolve.Conflicts(ctx.String("charts-dir"))
+	if err != nil {
 		return err
 	}
 
+	for _, file := range files {
+		fmt.Println(file)
+	}
+
 	return nil
 }
 
-func upstreamCheck(ctx *cli.Context) error {
-	pkgName := ctx.String("package")
-	chartsDir := ctx.String("charts-dir")
-	rootFs := filesystem.GetFilesystem(chartsDir)
+func shellUpstreamDiff(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		return fmt.Errorf("expected exactly one file but found %d", ctx.NArg())
+	}
 
-	releaseNamePattern := ctx.String("prefix") + ctx.String("pattern") + ctx.String("postfix")
-	releaseRegex, err := regexp.Compile(releaseNamePattern)
+	step, err := resolve.StepFromEnv()
 	if err != nil {
-		return fmt.Errorf("failed to compile tag pattern: %w", err)
+		return err
 	}
 
-	gitRoot, err := os.MkdirTemp(os.TempDir(), "chart-utils-")
+	return resolve.UpstreamDiff(os.Stdout, step, ctx.Args().First())
+}
+
+func shellPatchDiff(ctx *cli.Context) error {
+	pkgName, err := requirePackage(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to create temporary directory: %w", err)
+		return err
+	}
+
+	chartsDir := ctx.String("charts-dir")
+	rootFs := filesystem.GetFilesystem(chartsDir)
+
+	if ctx.NArg() != 1 {
+		return fmt.Errorf("expected exactly one file but found %d", ctx.NArg())
 	}
-	defer os.RemoveAll(gitRoot)
 
 	pkg, err := charts.GetPackage(rootFs, pkgName)
 	if err != nil {
@@ -182,68 +1609,86 @@ func upstreamCheck(ctx *cli.Context) error {
 		return fmt.Errorf("failed to get package '%s': no such package", pkgName)
 	}
 
-	pullOpts := pkg.Chart.Upstream.GetOptions()
+	pkgDir := filepath.Join(chartsDir, chartspath.RepositoryPackagesDir, pkgName)
 
-	if pullOpts.URL == "" {
-		return fmt.Errorf("upstream URL is not set")
-	}
+	return resolve.PatchDiff(os.Stdout, pkgDir, pkg.WorkingDir, ctx.Args().First())
+}
 
-	if !strings.HasSuffix(pullOpts.URL, ".git") {
-		return fmt.Errorf("upstream URL '%s' is not a git repository", pullOpts.URL)
+func shellValidate(ctx *cli.Context) error {
+	pkgName, err := requirePackage(ctx)
+	if err != nil {
+		return err
 	}
 
-	ref, err := release.RepoRefFromUrl(pullOpts.URL)
+	chartsDir := ctx.String("charts-dir")
+	rootFs := filesystem.GetFilesystem(chartsDir)
+
+	pkg, err := charts.GetPackage(rootFs, pkgName)
 	if err != nil {
-		return fmt.Errorf("failed to get upstream owner and name from url: %w", err)
+		return fmt.Errorf("failed to get package '%s': %w", pkgName, err)
+	} else if pkg == nil {
+		return fmt.Errorf("failed to get package '%s': no such package", pkgName)
 	}
 
-	var currentReleaseDate time.Time
+	pkgFs, err := rootFs.Chroot(filepath.Join(chartspath.RepositoryPackagesDir, pkgName))
+	if err != nil {
+		return fmt.Errorf("failed to chroot to package dir: %w", err)
+	}
 
-	client := github.NewClient(nil)
-	tag, _, err := client.Git.GetTag(ctx.Context, ref.Owner, ref.Name, *pullOpts.Commit)
-	switch err {
-	case nil:
-		release, _, err := client.Repositories.GetReleaseByTag(ctx.Context, ref.Owner, ref.Name, *tag.Tag)
-		if err != nil {
-			return fmt.Errorf("failed to fetch release for tag: %w", err)
-		}
+	repo, err := git.PlainOpen(chartsDir)
+	if err != nil {
+		return fmt.Errorf("failed to open charts repository: %w", err)
+	}
 
-		currentReleaseDate = release.CreatedAt.Time
-	default:
-		logger.Warn("failed to fetch tag for current commit, using commit date", "err", err, "commit", *pullOpts.Commit)
+	wt, err := repo.Worktree()
+	if err != nil {
+		return fmt.Errorf("failed to get charts worktree: %w", err)
+	}
 
-		commit, _, err := client.Git.GetCommit(ctx.Context, ref.Owner, ref.Name, *pullOpts.Commit)
-		if err != nil {
-			return fmt.Errorf("failed to fetch commit for hash: %w", err)
+	failed := false
+	for _, validator := range rebase.DefaultValidators(ctx.String("image-namespace")) {
+		err := validator(pkg, wt, pkgFs)
+		if errors.Is(err, rebase.ValidateError{}) {
+			fmt.Println(err)
+			failed = true
+		} else if err != nil {
+			return fmt.Errorf("could not verify chart: %w", err)
 		}
-
-		currentReleaseDate = *commit.Committer.Date
 	}
 
-	query := release.ReleaseQuery{
-		Since:       currentReleaseDate,
-		NamePattern: releaseRegex,
+	if failed {
+		return fmt.Errorf("worktree failed validation")
 	}
 
-	logger.Info("checking for upstream releases", "query", query)
-	releases, err := release.ReleasesForUpstream(ctx.Context, ref, query)
+	fmt.Println("worktree has passed all validators")
+
+	return nil
+}
+
+func shellStatus(ctx *cli.Context) error {
+	step, err := resolve.StepFromEnv()
 	if err != nil {
-		return fmt.Errorf("failed to list upstream releases: %w", err)
+		return err
 	}
 
-	table := display.NewTable("Name", "Age", "Hash")
-	for _, release := range releases {
-		age := display.NewDuration(release.Age).Round()
-		table.AddRow(release.Name, age.String(), release.Hash)
+	fmt.Println(step)
+
+	files, err := resolve.Conflicts(ctx.String("charts-dir"))
+	if err != nil {
+		return err
 	}
 
-	fmt.Print(table.String())
+	fmt.Printf("%d file(s) with unresolved conflicts\n", len(files))
 
 	return nil
 }
 
 func imagesMirror(ctx *cli.Context) error {
-	pkgName := ctx.String("package")
+	pkgName, err := requirePackage(ctx)
+	if err != nil {
+		return err
+	}
+
 	chartsDir := ctx.String("charts-dir")
 	rootFs := filesystem.GetFilesystem(chartsDir)
 	imagesListUrl := ctx.String("images-list")
@@ -301,6 +1746,108 @@ func imagesMirror(ctx *cli.Context) error {
 	return nil
 }
 
+// stepFilterFlags are the flags selecting which upstream commits are stepped through, shared by the commands which plan incremental rebases.
+func stepFilterFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.BoolFlag{
+			Name:     "merges-only",
+			Usage:    "when incrementing, only step through the merge commits on the upstream's main line",
+			Category: CategoryIncrement,
+		},
+		&cli.StringFlag{
+			Name:     "include-message",
+			Usage:    "when incrementing, only step to commits whose message matches this regex",
+			Category: CategoryIncrement,
+		},
+		&cli.StringFlag{
+			Name:     "exclude-message",
+			Usage:    "when incrementing, do not step to commits whose message matches this regex",
+			Category: CategoryIncrement,
+		},
+		&cli.StringFlag{
+			Name:     "include-author",
+			Usage:    "when incrementing, only step to commits whose author ('name <email>') matches this regex",
+			Category: CategoryIncrement,
+		},
+		&cli.StringFlag{
+			Name:     "exclude-author",
+			Usage:    "when incrementing, do not step to commits whose author ('name <email>') matches this regex",
+			Category: CategoryIncrement,
+		},
+		&cli.StringSliceFlag{
+			Name:     "include-path",
+			Usage:    "when incrementing, only step to commits changing a file matching this glob (relative to the upstream subdirectory, '**' matches any directories)",
+			Category: CategoryIncrement,
+		},
+		&cli.StringSliceFlag{
+			Name:     "exclude-path",
+			Usage:    "when incrementing, ignore changes to files matching this glob",
+			Category: CategoryIncrement,
+		},
+		&cli.BoolFlag{
+			Name:     "chart-version-only",
+			Usage:    "when incrementing, only step to commits which change the chart version",
+			Category: CategoryIncrement,
+		},
+		&cli.IntFlag{
+			Name:     "increment-step",
+			Usage:    "when incrementing, only step to every Nth commit which passes the filters",
+			Category: CategoryIncrement,
+		},
+		&cli.StringFlag{
+			Name:     "increment-window",
+			Usage:    "when incrementing, only step to the last commit of each calendar window (ex. '7d', '1w', '1 month')",
+			Category: CategoryIncrement,
+		},
+	}
+}
+
+// releaseQueryFlags are the flags selecting which upstream releases are listed, shared by the commands which query releases.
+func releaseQueryFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:     "pattern",
+			Usage:    "regex pattern to match release names",
+			Value:    release.DefaultReleaseNamePattern,
+			Category: CategoryPatternMatching,
+		},
+		&cli.StringFlag{
+			Name:     "prefix",
+			Usage:    "prefix to add to the release pattern",
+			Category: CategoryPatternMatching,
+		},
+		&cli.StringFlag{
+			Name:     "postfix",
+			Aliases:  []string{"sufix"},
+			Usage:    "postfix to add to the release pattern",
+			Category: CategoryPatternMatching,
+		},
+		&cli.BoolFlag{
+			Name:     "include-prereleases",
+			Usage:    "list prereleases, which are matched against --constraint as if they were their final release",
+			Category: CategoryPatternMatching,
+		},
+	}
+}
+
+// releaseSourceFlags are the flags selecting where the releases of a package's upstream are found, shared by the commands which check a single package.
+func releaseSourceFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  "provider",
+			Usage: fmt.Sprintf("where to find upstream releases (one of %s), detected from the upstream host by default with plain git tags used for unknown hosts", strings.Join(release.Providers, ", ")),
+		},
+		&cli.StringFlag{
+			Name:  "helm-repo",
+			Usage: "url of the helm repository serving an archive upstream, detected from the archive url by default",
+		},
+		&cli.StringFlag{
+			Name:  "api-url",
+			Usage: "base url of the provider api, for self-hosted providers (ex. https://gitlab.example.com/api/v4)",
+		},
+	}
+}
+
 func main() {
 	app := cli.App{
 		Name:    "chart-utils",
@@ -314,10 +1861,19 @@ func main() {
 				EnvVars: []string{EnvChartsDir},
 			},
 			&cli.StringFlag{
-				Name:     "package",
-				Usage:    "The target package for chartsutils operations",
-				EnvVars:  []string{EnvPackage},
-				Required: true,
+				Name:    "package",
+				Usage:   "The target package for chartsutils operations",
+				EnvVars: []string{EnvPackage},
+			},
+			&cli.StringFlag{
+				Name:    "cache-dir",
+				Usage:   "directory to cache upstream repositories and archives in (defaults to the user cache dir)",
+				EnvVars: []string{EnvCacheDir},
+			},
+			&cli.StringFlag{
+				Name:    "auth-config",
+				Usage:   "file configuring where to find credentials for each upstream host (defaults to auth.yaml in the user config dir)",
+				EnvVars: []string{EnvAuthConfig},
 			},
 
 			&cli.BoolFlag{
@@ -334,29 +1890,103 @@ func main() {
 				Name:        "upstream",
 				Description: "commands for interacting with upstream repositories",
 				Subcommands: []*cli.Command{
+					{
+						Name:        "log",
+						Description: "show the upstream changes to the chart between the current upstream commit and a target ref",
+						Action:      upstreamLog,
+						Flags: append([]cli.Flag{
+							&cli.StringFlag{
+								Name:     "to",
+								Usage:    "the commit, tag, or branch to show changes up to",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:     "subdirectory",
+								Usage:    "the subdirectory of the upstream repository at the target",
+								Category: CategoryUpstreamSpec,
+							},
+							&cli.StringFlag{
+								Name:  "format",
+								Usage: "the output format, one of 'text' or 'json'",
+								Value: "text",
+							},
+							&cli.BoolFlag{
+								Name:  "diff",
+								Usage: "include the full diff of the --diff-file files",
+							},
+							&cli.StringSliceFlag{
+								Name:  "diff-file",
+								Usage: "a file (relative to the upstream subdirectory) to show the full diff of with --diff",
+								Value: cli.NewStringSlice(iter.DefaultLogDiffFiles...),
+							},
+						}, stepFilterFlags()...),
+					},
 					{
 						Name:        "check",
 						Description: "check the chart upstream for newer versions of the base chart",
 						Action:      upstreamCheck,
-						Flags: []cli.Flag{
+						Flags: append([]cli.Flag{
 							&cli.StringFlag{
-								Name:     "pattern",
-								Usage:    "regex pattern to match release names",
-								Value:    release.DefaultReleaseNamePattern,
-								Category: CategoryPatternMatching,
+								Name:  "format",
+								Usage: "the output format, one of 'table', 'json' or 'yaml'",
+								Value: "table",
+							},
+							&cli.BoolFlag{
+								Name:  "fail-if-outdated",
+								Usage: fmt.Sprintf("exit with code %d if there are newer releases, for use in ci", ExitCodeOutdated),
 							},
 							&cli.StringFlag{
-								Name:     "prefix",
-								Usage:    "prefix to add to the release pattern",
-								Category: CategoryPatternMatching,
+								Name:  "older-than",
+								Usage: "only consider the package outdated if a newer release (or commit) was published longer ago than this (ex. 30d, 2w)",
+							},
+							&cli.BoolFlag{
+								Name:  "commits",
+								Usage: "list the upstream commits changing the chart since the pinned commit instead of releases, for upstreams which do not publish releases",
+							},
+							&cli.StringFlag{
+								Name:  "branch",
+								Usage: "the upstream branch to list commits from with --commits, the upstream's default branch by default",
 							},
 							&cli.StringFlag{
-								Name:     "postfix",
-								Aliases:  []string{"sufix"},
-								Usage:    "postfix to add to the release pattern",
+								Name:     "constraint",
+								Usage:    "only list releases whose version matches the semver constraint (ex. '>=55.0.0 <56')",
 								Category: CategoryPatternMatching,
 							},
-						},
+						}, append(releaseSourceFlags(), releaseQueryFlags()...)...),
+					},
+					{
+						Name:        "notes",
+						Description: "collect the release notes and changelog of every upstream release between the current version and a target version as markdown",
+						Action:      upstreamNotes,
+						Flags: append([]cli.Flag{
+							&cli.StringFlag{
+								Name:     "to",
+								Usage:    "the version, or ref of a git upstream (ex. a tag, branch or commit), to collect notes up to",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:  "breaking-pattern",
+								Usage: "regex pattern matching lines of the notes which describe breaking changes",
+								Value: release.DefaultBreakingChangePattern,
+							},
+						}, append(releaseSourceFlags(), releaseQueryFlags()...)...),
+					},
+					{
+						Name:        "report",
+						Description: "report how far behind its upstream every package in the repository is",
+						Action:      upstreamReport,
+						Flags: append([]cli.Flag{
+							&cli.StringFlag{
+								Name:  "format",
+								Usage: "the output format, one of 'table', 'json' or 'markdown'",
+								Value: "table",
+							},
+							&cli.IntFlag{
+								Name:  "concurrency",
+								Usage: "the number of packages to check at once",
+								Value: 4,
+							},
+						}, releaseQueryFlags()...),
 					},
 				},
 			},
@@ -365,11 +1995,16 @@ func main() {
 				Action:    pkgRebase,
 				Usage:     "Rebase a chart to a new version of the base chart",
 				UsageText: "chart-utils rebase [options]",
-				Flags: []cli.Flag{
+				Flags: append([]cli.Flag{
 					&cli.BoolFlag{
 						Name:  "increment",
 						Usage: "iterate through intermediary versions until the target upstream is achieved (only meaningful fr giuthub upstreams)",
 					},
+					&cli.BoolFlag{
+						Name:     "plan",
+						Usage:    "print the upstream commits an incremental rebase would step through, and which are folded into each step, without rebasing",
+						Category: CategoryIncrement,
+					},
 					&cli.BoolFlag{
 						Name:  "backup",
 						Usage: "create a backup of the package working dir after each upstream is merged",
@@ -378,9 +2013,30 @@ func main() {
 						Name:  "no-validate",
 						Usage: "do not run validators after resolving upstream changes",
 					},
+					&cli.StringFlag{
+						Name:  "resolver",
+						Usage: "how to resolve conflicts with upstream, one of 'shell', 'hunk' (falls back to 'shell' when stdin is not a terminal), 'mergetool', or 'editor'",
+						Value: "shell",
+					},
+					&cli.StringFlag{
+						Name:     "merge-strategy",
+						Usage:    "the strategy used to merge each upstream (ort, recursive, or resolve)",
+						Category: CategoryMerge,
+					},
+					&cli.StringSliceFlag{
+						Name:     "merge-strategy-option",
+						Aliases:  []string{"X"},
+						Usage:    "an option passed to the merge strategy (ex. patience, histogram, ignore-space-change, find-renames=50)",
+						Category: CategoryMerge,
+					},
+					&cli.StringFlag{
+						Name:     "conflict-style",
+						Usage:    "the style of conflict markers to write (merge, diff3, or zdiff3)",
+						Category: CategoryMerge,
+					},
 					&cli.StringFlag{
 						Name:     "commit",
-						Usage:    "the commit to rebase to",
+						Usage:    "the commit to rebase to, may also be a tag, branch, or short commit hash",
 						Category: CategoryUpstreamSpec,
 					},
 					&cli.StringFlag{
@@ -390,14 +2046,77 @@ func main() {
 					},
 					&cli.StringFlag{
 						Name:     "url",
-						Usage:    "the URL of the upstream repository to rebase to",
+						Usage:    "the URL of the upstream repository to rebase to, or an absolute path (or file:// url) to a local directory or git repository",
 						Category: CategoryUpstreamSpec,
 					},
 					&cli.StringFlag{
 						Name:     "subdirectory",
-						Usage:    "the subdirectory of the upstream repository to rebase to",
+						Usage:    "the subdirectory of the upstream repository to rebase to, incremental rebases switch to it when it is introduced or the current subdirectory is removed",
 						Category: CategoryUpstreamSpec,
 					},
+				}, stepFilterFlags()...),
+			},
+			{
+				Name:   "shell",
+				Usage:  "helper commands used from within the interactive rebase shell",
+				Hidden: true,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "image-namespace",
+						Usage: "the namespace to enforce for all chart images, set to '' to disable this check",
+						Value: "rancher",
+					},
+				},
+				Subcommands: []*cli.Command{
+					{
+						Name:   "conflicts",
+						Usage:  "list files with unresolved conflicts",
+						Action: shellConflicts,
+					},
+					{
+						Name:      "upstream-diff",
+						Usage:     "show what upstream changed in a file since the last step",
+						ArgsUsage: "<file>",
+						Action:    shellUpstreamDiff,
+					},
+					{
+						Name:      "patch-diff",
+						Usage:     "show the generated changes for a file",
+						ArgsUsage: "<file>",
+						Action:    shellPatchDiff,
+					},
+					{
+						Name:   "validate",
+						Usage:  "run the validators against the current worktree",
+						Action: shellValidate,
+					},
+					{
+						Name:   "status",
+						Usage:  "show the current upstream step",
+						Action: shellStatus,
+					},
+				},
+			},
+			{
+				Name:  "cache",
+				Usage: "manage the cache of upstream repositories and archives",
+				Subcommands: []*cli.Command{
+					{
+						Name:   "prune",
+						Usage:  "remove cached upstreams which have not been used recently",
+						Action: cachePrune,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "older-than",
+								Usage: "remove upstreams not used within this duration (ex. 30d, 2w or 720h)",
+								Value: "30d",
+							},
+							&cli.BoolFlag{
+								Name:  "all",
+								Usage: "remove all upstreams not currently in use",
+							},
+						},
+					},
 				},
 			},
 			{
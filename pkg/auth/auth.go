@@ -0,0 +1,324 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"gopkg.in/yaml.v2"
+)
+
+// Source is a place credentials can be found.
+type Source string
+
+const (
+	// SourceEnv reads a token from the host's token environment variables.
+	SourceEnv Source = "env"
+
+	// SourceNetrc reads the login and password for the host from ~/.netrc (or $NETRC).
+	SourceNetrc Source = "netrc"
+
+	// SourceCredentialHelper asks the git credential helpers configured for the host.
+	SourceCredentialHelper Source = "credential-helper"
+
+	// SourceSSHAgent authenticates with the keys in the running ssh-agent.
+	SourceSSHAgent Source = "ssh-agent"
+
+	// SourceSSHKey authenticates with a private key file.
+	SourceSSHKey Source = "ssh-key"
+)
+
+var (
+	// DefaultHTTPSources are the sources used for https upstreams when a host does not configure any.
+	DefaultHTTPSources = []Source{SourceEnv, SourceNetrc, SourceCredentialHelper}
+
+	// DefaultSSHSources are the sources used for ssh upstreams when a host does not configure any.
+	DefaultSSHSources = []Source{SourceSSHAgent, SourceSSHKey}
+
+	// DefaultSSHKeys are the key files tried by SourceSSHKey when a host does not configure one.
+	DefaultSSHKeys = []string{"~/.ssh/id_ed25519", "~/.ssh/id_ecdsa", "~/.ssh/id_rsa"}
+)
+
+// DefaultTokenUsername is the username sent with tokens when a host does not configure one. Most forges ignore the username when given a token.
+const DefaultTokenUsername = "chartsutil"
+
+// HostConfig configures where the credentials for a single host are found.
+type HostConfig struct {
+	// Sources are tried in order until one provides credentials.
+	Sources []Source `yaml:"sources,omitempty"`
+
+	// TokenEnv are the environment variables checked by SourceEnv, defaults to CHARTSUTIL_TOKEN_<HOST> (ex. CHARTSUTIL_TOKEN_GITHUB_COM).
+	TokenEnv []string `yaml:"tokenEnv,omitempty"`
+
+	// Username is sent along with tokens from SourceEnv.
+	Username string `yaml:"username,omitempty"`
+
+	// SSHUser is the user to authenticate as over ssh when the url does not specify one, defaults to 'git'.
+	SSHUser string `yaml:"sshUser,omitempty"`
+
+	// SSHKey is the private key file used by SourceSSHKey.
+	SSHKey string `yaml:"sshKey,omitempty"`
+
+	// SSHKeyPassphraseEnv is the environment variable holding the passphrase of SSHKey.
+	SSHKeyPassphraseEnv string `yaml:"sshKeyPassphraseEnv,omitempty"`
+}
+
+// Config holds the credential configuration of each host, the host "*" applies to any host without its own config.
+type Config struct {
+	Hosts map[string]HostConfig `yaml:"hosts,omitempty"`
+
+	// creds caches the credentials found for each host by the transports of this config, so credential helpers are run once per host.
+	mu    sync.Mutex
+	creds map[string]*Credentials
+}
+
+// DefaultConfigPath returns the path of the auth config in the user's config dir (ex. $XDG_CONFIG_HOME/chartsutil/auth.yaml).
+func DefaultConfigPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to determine user config dir: %w", err)
+	}
+
+	return filepath.Join(dir, "chartsutil", "auth.yaml"), nil
+}
+
+// LoadConfig reads the config at path, returning an empty config if it does not exist.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Config{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read auth config: %w", err)
+	}
+
+	config := &Config{}
+	if err := yaml.UnmarshalStrict(data, config); err != nil {
+		return nil, fmt.Errorf("failed to parse auth config: %w", err)
+	}
+
+	return config, nil
+}
+
+// Host returns the config for host.
+func (c *Config) Host(host string) HostConfig {
+	if c == nil {
+		return HostConfig{}
+	}
+
+	if hc, ok := c.Hosts[host]; ok {
+		return hc
+	}
+
+	return c.Hosts["*"]
+}
+
+// Credentials are a username and password (or token) for an http host.
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// HTTPCredentials searches the sources for host for credentials, returning nil if none are found.
+func (c *Config) HTTPCredentials(host string, path string) (*Credentials, error) {
+	hc := c.Host(host)
+
+	sources := hc.Sources
+	if len(sources) == 0 {
+		sources = DefaultHTTPSources
+	}
+
+	for _, source := range sources {
+		var creds *Credentials
+		var err error
+
+		switch source {
+		case SourceEnv:
+			creds = hc.envCredentials(host)
+		case SourceNetrc:
+			creds, err = netrcCredentials(host)
+		case SourceCredentialHelper:
+			creds, err = helperCredentials(host, path)
+		case SourceSSHAgent, SourceSSHKey:
+			continue
+		default:
+			return nil, fmt.Errorf("unknown credential source '%s' for host '%s'", source, host)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to read credentials from %s: %w", source, err)
+		}
+
+		if creds != nil && creds.Password != "" {
+			return creds, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// defaultTokenEnv returns the token environment variables checked for a host without its own TokenEnv. Along with CHARTSUTIL_TOKEN_<HOST>, the variables used by the GitHub cli are checked for GitHub and GitHub Enterprise hosts.
+func defaultTokenEnv(host string) []string {
+	vars := []string{tokenEnvName(host)}
+
+	switch {
+	case host == "github.com":
+		vars = append(vars, "GITHUB_TOKEN", "GH_TOKEN")
+	case strings.HasPrefix(host, "github."):
+		vars = append(vars, "GITHUB_ENTERPRISE_TOKEN", "GH_ENTERPRISE_TOKEN")
+	}
+
+	return vars
+}
+
+// tokenEnvName returns the default token environment variable for host.
+func tokenEnvName(host string) string {
+	name := strings.Map(func(r rune) rune {
+		if ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9') {
+			return r
+		}
+
+		return '_'
+	}, host)
+
+	return "CHARTSUTIL_TOKEN_" + strings.ToUpper(name)
+}
+
+func (hc HostConfig) envCredentials(host string) *Credentials {
+	vars := hc.TokenEnv
+	if len(vars) == 0 {
+		vars = defaultTokenEnv(host)
+	}
+
+	username := hc.Username
+	if username == "" {
+		username = DefaultTokenUsername
+	}
+
+	for _, v := range vars {
+		if token := os.Getenv(v); token != "" {
+			return &Credentials{Username: username, Password: token}
+		}
+	}
+
+	return nil
+}
+
+// GitAuth returns the auth method for cloning or fetching the git repository at url, or nil if no credentials were found.
+func (c *Config) GitAuth(url string) (transport.AuthMethod, error) {
+	endpoint, err := transport.NewEndpoint(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid git url '%s': %w", url, err)
+	}
+
+	switch endpoint.Protocol {
+	case "http", "https":
+		creds, err := c.HTTPCredentials(endpoint.Host, strings.TrimPrefix(endpoint.Path, "/"))
+		if err != nil || creds == nil {
+			return nil, err
+		}
+
+		return &githttp.BasicAuth{Username: creds.Username, Password: creds.Password}, nil
+	case "ssh":
+		return c.sshAuth(endpoint)
+	default:
+		return nil, nil
+	}
+}
+
+func (c *Config) sshAuth(endpoint *transport.Endpoint) (transport.AuthMethod, error) {
+	hc := c.Host(endpoint.Host)
+
+	user := endpoint.User
+	if user == "" {
+		user = hc.SSHUser
+	}
+	if user == "" {
+		user = "git"
+	}
+
+	sources := hc.Sources
+	if len(sources) == 0 {
+		sources = DefaultSSHSources
+	}
+
+	for _, source := range sources {
+		switch source {
+		case SourceSSHAgent:
+			if os.Getenv("SSH_AUTH_SOCK") == "" {
+				continue
+			}
+
+			method, err := gitssh.NewSSHAgentAuth(user)
+			if err != nil {
+				return nil, fmt.Errorf("failed to connect to ssh-agent: %w", err)
+			}
+
+			return method, nil
+		case SourceSSHKey:
+			keys := DefaultSSHKeys
+			if hc.SSHKey != "" {
+				keys = []string{hc.SSHKey}
+			}
+
+			for _, key := range keys {
+				key = expandHome(key)
+
+				if _, err := os.Stat(key); errors.Is(err, os.ErrNotExist) && hc.SSHKey == "" {
+					continue
+				}
+
+				var passphrase string
+				if hc.SSHKeyPassphraseEnv != "" {
+					passphrase = os.Getenv(hc.SSHKeyPassphraseEnv)
+				}
+
+				method, err := gitssh.NewPublicKeysFromFile(user, key, passphrase)
+				if err != nil {
+					return nil, fmt.Errorf("failed to load ssh key '%s': %w", key, err)
+				}
+
+				return method, nil
+			}
+		case SourceEnv, SourceNetrc, SourceCredentialHelper:
+			continue
+		default:
+			return nil, fmt.Errorf("unknown credential source '%s' for host '%s'", source, endpoint.Host)
+		}
+	}
+
+	return nil, nil
+}
+
+func expandHome(path string) string {
+	if !strings.HasPrefix(path, "~/") {
+		return path
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+
+	return filepath.Join(home, path[2:])
+}
+
+// Transport returns an http.RoundTripper which adds basic auth (or the Scheme given with WithScheme) to requests for hosts with credentials. Requests to "api.<host>" use the credentials for "<host>" if they have none of their own.
+func (c *Config) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return &transportWithAuth{config: c, base: base}
+}
+
+// HTTPClient returns an http client using Transport.
+func (c *Config) HTTPClient() *http.Client {
+	return &http.Client{Transport: c.Transport(nil)}
+}
@@ -0,0 +1,348 @@
+package auth_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/joshmeranda/chartsutil/pkg/auth"
+)
+
+func TestParseNetrc(t *testing.T) {
+	netrc := `machine github.com
+	login octocat
+	password github-token
+
+machine gitlab.example.com login gitlab password gitlab-token account ignored
+
+default login anonymous password default-token
+`
+
+	type testCase struct {
+		Name     string
+		Host     string
+		Expected *auth.Credentials
+	}
+
+	cases := []testCase{
+		{
+			Name:     "Multiline",
+			Host:     "github.com",
+			Expected: &auth.Credentials{Username: "octocat", Password: "github-token"},
+		},
+		{
+			Name:     "SingleLine",
+			Host:     "gitlab.example.com",
+			Expected: &auth.Credentials{Username: "gitlab", Password: "gitlab-token"},
+		},
+		{
+			Name:     "Default",
+			Host:     "gitea.example.com",
+			Expected: &auth.Credentials{Username: "anonymous", Password: "default-token"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			actual, err := auth.ParseNetrc(strings.NewReader(netrc), c.Host)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if *actual != *c.Expected {
+				t.Errorf("expected %+v but found %+v", c.Expected, actual)
+			}
+		})
+	}
+}
+
+func TestParseCredentialOutput(t *testing.T) {
+	out := "protocol=https\nhost=github.com\nusername=octocat\npassword=secret\n"
+
+	actual := auth.ParseCredentialOutput([]byte(out))
+	if actual == nil || *actual != (auth.Credentials{Username: "octocat", Password: "secret"}) {
+		t.Errorf("unexpected credentials: %+v", actual)
+	}
+
+	if actual := auth.ParseCredentialOutput([]byte("protocol=https\nhost=github.com\n")); actual != nil {
+		t.Errorf("expected no credentials but found %+v", actual)
+	}
+}
+
+// isolateCredentials points every credential source at empty or test specific files.
+func isolateCredentials(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	t.Setenv("NETRC", filepath.Join(dir, "netrc"))
+	t.Setenv("GIT_CONFIG_GLOBAL", filepath.Join(dir, "gitconfig"))
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("SSH_AUTH_SOCK", "")
+
+	return dir
+}
+
+func TestHTTPCredentials(t *testing.T) {
+	dir := isolateCredentials(t)
+
+	if err := os.WriteFile(filepath.Join(dir, "netrc"), []byte("machine netrc.example.com login netrc password netrc-token\n"), 0600); err != nil {
+		t.Fatalf("failed to write netrc: %v", err)
+	}
+
+	helper := "[credential \"https://helper.example.com\"]\n\thelper = \"!f() { echo username=helper; echo password=helper-token; }; f\"\n"
+	if err := os.WriteFile(filepath.Join(dir, "gitconfig"), []byte(helper), 0600); err != nil {
+		t.Fatalf("failed to write gitconfig: %v", err)
+	}
+
+	t.Setenv("CHARTSUTIL_TOKEN_ENV_EXAMPLE_COM", "env-token")
+	t.Setenv("CUSTOM_TOKEN", "custom-token")
+	t.Setenv("GITHUB_TOKEN", "")
+	t.Setenv("GH_TOKEN", "gh-token")
+	t.Setenv("GH_ENTERPRISE_TOKEN", "gh-enterprise-token")
+
+	config := &auth.Config{
+		Hosts: map[string]auth.HostConfig{
+			"custom.example.com": {
+				TokenEnv: []string{"CUSTOM_TOKEN"},
+				Username: "oauth2",
+			},
+			"helper-only.example.com": {
+				Sources: []auth.Source{auth.SourceCredentialHelper},
+			},
+		},
+	}
+
+	type testCase struct {
+		Name     string
+		Host     string
+		Expected *auth.Credentials
+	}
+
+	cases := []testCase{
+		{
+			Name:     "DefaultEnv",
+			Host:     "env.example.com",
+			Expected: &auth.Credentials{Username: auth.DefaultTokenUsername, Password: "env-token"},
+		},
+		{
+			Name:     "GitHubEnv",
+			Host:     "github.com",
+			Expected: &auth.Credentials{Username: auth.DefaultTokenUsername, Password: "gh-token"},
+		},
+		{
+			Name:     "GitHubEnterpriseEnv",
+			Host:     "github.example.com",
+			Expected: &auth.Credentials{Username: auth.DefaultTokenUsername, Password: "gh-enterprise-token"},
+		},
+		{
+			Name:     "CustomEnv",
+			Host:     "custom.example.com",
+			Expected: &auth.Credentials{Username: "oauth2", Password: "custom-token"},
+		},
+		{
+			Name:     "Netrc",
+			Host:     "netrc.example.com",
+			Expected: &auth.Credentials{Username: "netrc", Password: "netrc-token"},
+		},
+		{
+			Name:     "CredentialHelper",
+			Host:     "helper.example.com",
+			Expected: &auth.Credentials{Username: "helper", Password: "helper-token"},
+		},
+		{
+			Name: "RestrictedSources",
+			Host: "helper-only.example.com",
+		},
+		{
+			Name: "None",
+			Host: "none.example.com",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			actual, err := config.HTTPCredentials(c.Host, "")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if c.Expected == nil {
+				if actual != nil {
+					t.Errorf("expected no credentials but found %+v", actual)
+				}
+
+				return
+			}
+
+			if actual == nil || *actual != *c.Expected {
+				t.Errorf("expected %+v but found %+v", c.Expected, actual)
+			}
+		})
+	}
+}
+
+func TestGitAuth(t *testing.T) {
+	isolateCredentials(t)
+	t.Setenv("CHARTSUTIL_TOKEN_GIT_EXAMPLE_COM", "token")
+
+	config := &auth.Config{}
+
+	method, err := config.GitAuth("https://git.example.com/org/chart.git")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if basic, ok := method.(*githttp.BasicAuth); !ok || basic.Password != "token" {
+		t.Errorf("expected basic auth with token but found %v", method)
+	}
+
+	method, err = config.GitAuth("https://other.example.com/org/chart.git")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if method != nil {
+		t.Errorf("expected no auth but found %v", method)
+	}
+
+	if _, err := (&auth.Config{Hosts: map[string]auth.HostConfig{"git.example.com": {Sources: []auth.Source{auth.SourceSSHKey}, SSHKey: "/does/not/exist"}}}).GitAuth("git@git.example.com:org/chart.git"); err == nil {
+		t.Errorf("expected error for missing ssh key but found none")
+	}
+}
+
+func TestTransport(t *testing.T) {
+	isolateCredentials(t)
+	t.Setenv("CUSTOM_TOKEN", "secret")
+
+	var username, password string
+	var ok bool
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok = r.BasicAuth()
+	}))
+	defer server.Close()
+
+	config := &auth.Config{
+		Hosts: map[string]auth.HostConfig{
+			"127.0.0.1": {
+				Sources:  []auth.Source{auth.SourceEnv},
+				TokenEnv: []string{"CUSTOM_TOKEN"},
+			},
+		},
+	}
+
+	resp, err := config.HTTPClient().Get(server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	if !ok || username != auth.DefaultTokenUsername || password != "secret" {
+		t.Errorf("expected request to be authenticated but found '%s:%s'", username, password)
+	}
+}
+
+func TestTransportSharesCredentials(t *testing.T) {
+	isolateCredentials(t)
+	t.Setenv("CUSTOM_TOKEN", "secret")
+
+	var password string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, password, _ = r.BasicAuth()
+	}))
+	defer server.Close()
+
+	config := &auth.Config{
+		Hosts: map[string]auth.HostConfig{
+			"127.0.0.1": {
+				Sources:  []auth.Source{auth.SourceEnv},
+				TokenEnv: []string{"CUSTOM_TOKEN"},
+			},
+		},
+	}
+
+	resp, err := config.HTTPClient().Get(server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	// a second client from the same config should reuse the credentials found by the first
+	t.Setenv("CUSTOM_TOKEN", "changed")
+
+	resp, err = config.HTTPClient().Get(server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	if password != "secret" {
+		t.Errorf("expected credentials to be shared between clients but found '%s'", password)
+	}
+}
+
+func TestTransportScheme(t *testing.T) {
+	isolateCredentials(t)
+	t.Setenv("CUSTOM_TOKEN", "secret")
+
+	type Case struct {
+		Name     string
+		Scheme   auth.Scheme
+		Expected string
+	}
+
+	cases := []Case{
+		{
+			Name:     "Bearer",
+			Scheme:   auth.SchemeBearer,
+			Expected: "Bearer secret",
+		},
+		{
+			Name:     "Token",
+			Scheme:   auth.SchemeToken,
+			Expected: "token secret",
+		},
+	}
+
+	var actual string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actual = r.Header.Get("Authorization")
+	}))
+	defer server.Close()
+
+	config := &auth.Config{
+		Hosts: map[string]auth.HostConfig{
+			"127.0.0.1": {
+				Sources:  []auth.Source{auth.SourceEnv},
+				TokenEnv: []string{"CUSTOM_TOKEN"},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			req, err := http.NewRequestWithContext(auth.WithScheme(context.Background(), c.Scheme), http.MethodGet, server.URL, nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			resp, err := config.HTTPClient().Do(req)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			resp.Body.Close()
+
+			if actual != c.Expected {
+				t.Errorf("expected Authorization header '%s' but found '%s'", c.Expected, actual)
+			}
+		})
+	}
+}
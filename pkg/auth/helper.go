@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// helperCredentials asks git's configured credential helpers for the credentials of host, without prompting the user.
+func helperCredentials(host string, path string) (*Credentials, error) {
+	input := fmt.Sprintf("protocol=https\nhost=%s\n", host)
+	if path != "" {
+		input += fmt.Sprintf("path=%s\n", path)
+	}
+	input += "\n"
+
+	cmd := exec.Command("git", "-c", "credential.interactive=never", "credential", "fill")
+	cmd.Stdin = strings.NewReader(input)
+	cmd.Env = helperEnv()
+
+	out, err := cmd.Output()
+	if err != nil {
+		// git exits non-zero when no helper has credentials and it can't prompt for them
+		if _, ok := err.(*exec.ExitError); ok {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to run git credential: %w", err)
+	}
+
+	return ParseCredentialOutput(out), nil
+}
+
+// helperEnv is the current environment with prompting disabled.
+func helperEnv() []string {
+	env := make([]string, 0, len(os.Environ())+1)
+
+	for _, v := range os.Environ() {
+		if strings.HasPrefix(v, "GIT_ASKPASS=") || strings.HasPrefix(v, "SSH_ASKPASS=") {
+			continue
+		}
+
+		env = append(env, v)
+	}
+
+	return append(env, "GIT_TERMINAL_PROMPT=0")
+}
+
+// ParseCredentialOutput parses the key=value output of 'git credential fill'.
+func ParseCredentialOutput(out []byte) *Credentials {
+	creds := &Credentials{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+
+		switch key {
+		case "username":
+			creds.Username = value
+		case "password":
+			creds.Password = value
+		}
+	}
+
+	if creds.Password == "" {
+		return nil
+	}
+
+	return creds
+}
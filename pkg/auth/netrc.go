@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// netrcPath returns the path of the user's netrc file.
+func netrcPath() string {
+	if path := os.Getenv("NETRC"); path != "" {
+		return path
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(home, ".netrc")
+}
+
+func netrcCredentials(host string) (*Credentials, error) {
+	path := netrcPath()
+	if path == "" {
+		return nil, nil
+	}
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to open netrc: %w", err)
+	}
+	defer f.Close()
+
+	return ParseNetrc(f, host)
+}
+
+// ParseNetrc finds the login and password for host in a netrc file, falling back to the default entry. Returns nil if there is no matching entry.
+func ParseNetrc(r io.Reader, host string) (*Credentials, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Split(bufio.ScanWords)
+
+	var found, fallback *Credentials
+	var current *Credentials
+
+	for scanner.Scan() {
+		switch scanner.Text() {
+		case "machine":
+			if !scanner.Scan() {
+				return nil, fmt.Errorf("missing name after 'machine'")
+			}
+
+			current = nil
+			if scanner.Text() == host && found == nil {
+				found = &Credentials{}
+				current = found
+			}
+		case "default":
+			current = nil
+			if fallback == nil {
+				fallback = &Credentials{}
+				current = fallback
+			}
+		case "login":
+			if !scanner.Scan() {
+				return nil, fmt.Errorf("missing value after 'login'")
+			}
+
+			if current != nil {
+				current.Username = scanner.Text()
+			}
+		case "password":
+			if !scanner.Scan() {
+				return nil, fmt.Errorf("missing value after 'password'")
+			}
+
+			if current != nil {
+				current.Password = scanner.Text()
+			}
+		case "account":
+			scanner.Scan()
+		case "macdef":
+			// macro definitions run until an empty line, which is lost when splitting on words so we ignore everything after
+			current = nil
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read netrc: %w", err)
+	}
+
+	if found != nil {
+		return found, nil
+	}
+
+	return fallback, nil
+}
@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// Scheme is how credentials are sent with a request.
+type Scheme int
+
+const (
+	// SchemeBasic sends the credentials with basic auth, which most forges accept for tokens as well as passwords.
+	SchemeBasic Scheme = iota
+
+	// SchemeBearer sends the password as a bearer token (ex. for the GitLab api, which does not accept tokens with basic auth).
+	SchemeBearer
+
+	// SchemeToken sends the password in a 'token' Authorization header (ex. for the Gitea api).
+	SchemeToken
+)
+
+type schemeKey struct{}
+
+// WithScheme returns a context whose requests send their credentials with scheme rather than basic auth.
+func WithScheme(ctx context.Context, scheme Scheme) context.Context {
+	return context.WithValue(ctx, schemeKey{}, scheme)
+}
+
+func schemeFrom(ctx context.Context) Scheme {
+	scheme, _ := ctx.Value(schemeKey{}).(Scheme)
+	return scheme
+}
+
+type transportWithAuth struct {
+	config *Config
+	base   http.RoundTripper
+}
+
+// transportCredentials looks up and caches the credentials for host so credential helpers are not run for every request. Requests to "api.<host>" use the credentials for "<host>" if they have none of their own.
+func (c *Config) transportCredentials(host string) (*Credentials, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if creds, ok := c.creds[host]; ok {
+		return creds, nil
+	}
+
+	creds, err := c.HTTPCredentials(host, "")
+	if err != nil {
+		return nil, err
+	}
+
+	if creds == nil && strings.HasPrefix(host, "api.") {
+		if creds, err = c.HTTPCredentials(strings.TrimPrefix(host, "api."), ""); err != nil {
+			return nil, err
+		}
+	}
+
+	if c.creds == nil {
+		c.creds = make(map[string]*Credentials)
+	}
+	c.creds[host] = creds
+
+	return creds, nil
+}
+
+func (t *transportWithAuth) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Authorization") != "" {
+		return t.base.RoundTrip(req)
+	}
+
+	creds, err := t.config.transportCredentials(req.URL.Hostname())
+	if err != nil {
+		return nil, err
+	}
+
+	if creds == nil {
+		return t.base.RoundTrip(req)
+	}
+
+	req = req.Clone(req.Context())
+
+	switch schemeFrom(req.Context()) {
+	case SchemeBearer:
+		req.Header.Set("Authorization", "Bearer "+creds.Password)
+	case SchemeToken:
+		req.Header.Set("Authorization", "token "+creds.Password)
+	default:
+		req.SetBasicAuth(creds.Username, creds.Password)
+	}
+
+	return t.base.RoundTrip(req)
+}
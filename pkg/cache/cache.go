@@ -0,0 +1,444 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+const (
+	// ReposDir is the directory in the cache holding bare clones of upstream repositories.
+	ReposDir = "repos"
+
+	// ArchivesDir is the directory in the cache holding downloaded upstream archives.
+	ArchivesDir = "archives"
+
+	// ResponsesDir is the directory in the cache holding http responses which are revalidated by their ETag or modification time.
+	ResponsesDir = "responses"
+
+	// lockFileName is the name of the lock file held while an entry is in use, its modification time doubles as the last time the entry was used.
+	lockFileName = ".chartsutil-lock"
+
+	// urlFileName records the url an entry was fetched from.
+	urlFileName = ".chartsutil-url"
+
+	// DefaultBranchRef is where the commit of the upstream's default branch is fetched to, since the HEAD of a cached clone is not the upstream's.
+	DefaultBranchRef = plumbing.ReferenceName("refs/remotes/origin/HEAD")
+)
+
+var (
+	// fetchRefSpecs mirrors all branches and tags of the upstream so any ref can be resolved from the cache.
+	fetchRefSpecs = []config.RefSpec{
+		"+refs/heads/*:refs/heads/*",
+		"+refs/tags/*:refs/tags/*",
+		config.RefSpec("+HEAD:" + DefaultBranchRef),
+	}
+)
+
+// AuthFunc returns the auth method used to fetch the repository at url, or nil for anonymous access.
+type AuthFunc func(url string) (transport.AuthMethod, error)
+
+// Cache manages clones and downloads of upstreams which are shared between runs.
+type Cache struct {
+	Root string
+
+	// Auth is used to authenticate fetches which were not given their own auth method.
+	Auth AuthFunc
+
+	// Client is used to download archives, if nil http.DefaultClient is used.
+	Client *http.Client
+}
+
+// Default returns the cache in the user's cache directory (ex. $XDG_CACHE_HOME/chartsutil).
+func Default() (*Cache, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine user cache dir: %w", err)
+	}
+
+	return &Cache{
+		Root: filepath.Join(dir, "chartsutil"),
+	}, nil
+}
+
+// OrDefault returns c if it is not nil, otherwise the default cache.
+func OrDefault(c *Cache) (*Cache, error) {
+	if c != nil {
+		return c, nil
+	}
+
+	return Default()
+}
+
+func key(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// RepoDir returns the directory of the cached clone for the given url.
+func (c *Cache) RepoDir(url string) string {
+	return filepath.Join(c.Root, ReposDir, key(url))
+}
+
+// openRepo is a cached clone opened by this process, shared between all of its open Repos since a second lock on the same entry would block on our own.
+type openRepo struct {
+	// ready is closed once the clone has been synced, or err is set.
+	ready chan struct{}
+	err   error
+
+	lock *lock
+	refs int
+}
+
+var (
+	openReposMu sync.Mutex
+	openRepos   = map[string]*openRepo{}
+)
+
+// releaseRepo drops a reference to the open clone at dir, unlocking it once no references remain.
+func releaseRepo(dir string, o *openRepo) error {
+	openReposMu.Lock()
+	defer openReposMu.Unlock()
+
+	o.refs--
+	if o.refs > 0 {
+		return nil
+	}
+
+	if openRepos[dir] == o {
+		delete(openRepos, dir)
+	}
+
+	if o.lock == nil {
+		return nil
+	}
+
+	return o.lock.Unlock()
+}
+
+// Repo is a cached clone of an upstream repository, which must be closed to release its lock.
+type Repo struct {
+	*git.Repository
+
+	URL  string
+	Path string
+
+	open *openRepo
+}
+
+func (r *Repo) Close() error {
+	return releaseRepo(r.Path, r.open)
+}
+
+// DefaultBranch returns the commit at the head of the upstream's default branch.
+func (r *Repo) DefaultBranch() (plumbing.Hash, error) {
+	ref, err := r.Reference(DefaultBranchRef, true)
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("failed to find default branch of '%s': %w", r.URL, err)
+	}
+
+	return ref.Hash(), nil
+}
+
+// Repo opens the cached clone of url, cloning or fetching any new changes as needed. While open, the clone will not be modified by other processes.
+// If the clone is already open in this process, it is reused as is without fetching again.
+func (c *Cache) Repo(ctx context.Context, url string, opts *git.FetchOptions) (*Repo, error) {
+	dir := c.RepoDir(url)
+
+	openReposMu.Lock()
+	o, found := openRepos[dir]
+	if found {
+		o.refs++
+	} else {
+		o = &openRepo{ready: make(chan struct{}), refs: 1}
+		openRepos[dir] = o
+	}
+	openReposMu.Unlock()
+
+	if !found {
+		o.lock, o.err = c.open(ctx, dir, url, opts)
+		if o.err != nil {
+			openReposMu.Lock()
+			delete(openRepos, dir)
+			openReposMu.Unlock()
+		}
+
+		close(o.ready)
+	}
+
+	select {
+	case <-o.ready:
+	case <-ctx.Done():
+		releaseRepo(dir, o)
+		return nil, ctx.Err()
+	}
+
+	if o.err != nil {
+		releaseRepo(dir, o)
+		return nil, o.err
+	}
+
+	// each Repo gets its own handle since a git.Repository is not safe for concurrent use
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		releaseRepo(dir, o)
+		return nil, fmt.Errorf("failed to open cached repository: %w", err)
+	}
+
+	return &Repo{
+		Repository: repo,
+		URL:        url,
+		Path:       dir,
+		open:       o,
+	}, nil
+}
+
+// open locks and syncs the cached clone at dir, returning the shared lock held while it is in use.
+func (c *Cache) open(ctx context.Context, dir string, url string, opts *git.FetchOptions) (*lock, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cache dir: %w", err)
+	}
+
+	l, err := acquire(filepath.Join(dir, lockFileName), true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock cached repository: %w", err)
+	}
+
+	if _, err := c.sync(ctx, dir, url, opts); err != nil {
+		l.Unlock()
+		return nil, err
+	}
+
+	// other processes may read from the clone once we are done updating it
+	if err := l.Downgrade(); err != nil {
+		l.Unlock()
+		return nil, fmt.Errorf("failed to downgrade cache lock: %w", err)
+	}
+
+	return l, nil
+}
+
+func (c *Cache) sync(ctx context.Context, dir string, url string, opts *git.FetchOptions) (*git.Repository, error) {
+	if opts == nil {
+		opts = &git.FetchOptions{}
+	}
+
+	fetchOpts := *opts
+	fetchOpts.RemoteName = git.DefaultRemoteName
+	fetchOpts.RefSpecs = fetchRefSpecs
+	fetchOpts.Tags = git.AllTags
+	fetchOpts.Force = true
+
+	if fetchOpts.Auth == nil && c.Auth != nil {
+		auth, err := c.Auth(url)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get credentials for '%s': %w", url, err)
+		}
+
+		fetchOpts.Auth = auth
+	}
+
+	fresh := false
+
+	repo, err := git.PlainOpen(dir)
+	if errors.Is(err, git.ErrRepositoryNotExists) {
+		fresh = true
+
+		if repo, err = git.PlainInit(dir, true); err != nil {
+			return nil, fmt.Errorf("failed to init cached repository: %w", err)
+		}
+
+		if _, err := repo.CreateRemote(&config.RemoteConfig{Name: git.DefaultRemoteName, URLs: []string{url}, Fetch: fetchRefSpecs}); err != nil {
+			return nil, fmt.Errorf("failed to add remote to cached repository: %w", err)
+		}
+
+		if err := os.WriteFile(filepath.Join(dir, urlFileName), []byte(url), 0644); err != nil {
+			return nil, fmt.Errorf("failed to record url of cached repository: %w", err)
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to open cached repository: %w", err)
+	}
+
+	if err := repo.FetchContext(ctx, &fetchOpts); err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		// a failed clone would leave behind an empty repository which we would then never try to clone again
+		if fresh {
+			clearDir(dir)
+		}
+
+		return nil, fmt.Errorf("failed to fetch '%s': %w", url, err)
+	}
+
+	return repo, nil
+}
+
+// clearDir removes everything in dir except for its lock file, which is still held by the caller.
+func clearDir(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	for _, e := range entries {
+		if e.Name() != lockFileName {
+			os.RemoveAll(filepath.Join(dir, e.Name()))
+		}
+	}
+}
+
+// ArchivePath returns the path of the cached download of url.
+func (c *Cache) ArchivePath(url string) string {
+	return filepath.Join(c.Root, ArchivesDir, key(url), filepath.Base(strings.TrimSuffix(url, "/")))
+}
+
+// Archive returns the path to a cached download of the archive at url, downloading it if not yet cached. Archives are expected to be immutable and are never re-downloaded.
+func (c *Cache) Archive(ctx context.Context, url string) (string, error) {
+	path := c.ArchivePath(url)
+	dir := filepath.Dir(path)
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create cache dir: %w", err)
+	}
+
+	l, err := acquire(filepath.Join(dir, lockFileName), true)
+	if err != nil {
+		return "", fmt.Errorf("failed to lock cached archive: %w", err)
+	}
+	defer l.Unlock()
+
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to download archive: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download archive: %s", resp.Status)
+	}
+
+	tmp, err := os.CreateTemp(dir, "download-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, resp.Body); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to download archive: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("failed to close downloaded archive: %w", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, urlFileName), []byte(url), 0644); err != nil {
+		return "", fmt.Errorf("failed to record url of cached archive: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", fmt.Errorf("failed to move downloaded archive into cache: %w", err)
+	}
+
+	return path, nil
+}
+
+// Entry is a single cached repository, archive or http response.
+type Entry struct {
+	URL      string
+	Path     string
+	LastUsed time.Time
+}
+
+// Entries lists all repositories, archives and http responses in the cache.
+func (c *Cache) Entries() ([]Entry, error) {
+	entries := make([]Entry, 0)
+
+	for _, kind := range []string{ReposDir, ArchivesDir, ResponsesDir} {
+		dirs, err := os.ReadDir(filepath.Join(c.Root, kind))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to list cache entries: %w", err)
+		}
+
+		for _, d := range dirs {
+			path := filepath.Join(c.Root, kind, d.Name())
+
+			url, _ := os.ReadFile(filepath.Join(path, urlFileName))
+
+			var lastUsed time.Time
+			if info, err := os.Stat(filepath.Join(path, lockFileName)); err == nil {
+				lastUsed = info.ModTime()
+			}
+
+			entries = append(entries, Entry{
+				URL:      string(url),
+				Path:     path,
+				LastUsed: lastUsed,
+			})
+		}
+	}
+
+	return entries, nil
+}
+
+// Prune removes all entries which have not been used since the given time, skipping any which are currently in use.
+func (c *Cache) Prune(before time.Time) ([]Entry, error) {
+	entries, err := c.Entries()
+	if err != nil {
+		return nil, err
+	}
+
+	pruned := make([]Entry, 0)
+
+	for _, entry := range entries {
+		if entry.LastUsed.After(before) {
+			continue
+		}
+
+		l, err := tryAcquire(filepath.Join(entry.Path, lockFileName))
+		if errors.Is(err, ErrLocked) {
+			continue
+		} else if err != nil {
+			return pruned, fmt.Errorf("failed to lock cache entry '%s': %w", entry.Path, err)
+		}
+
+		err = os.RemoveAll(entry.Path)
+		l.Unlock()
+
+		if err != nil {
+			return pruned, fmt.Errorf("failed to remove cache entry '%s': %w", entry.Path, err)
+		}
+
+		pruned = append(pruned, entry)
+	}
+
+	return pruned, nil
+}
@@ -0,0 +1,336 @@
+package cache_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/joshmeranda/chartsutil/pkg/cache"
+)
+
+func commitFile(t *testing.T, repo *git.Repository, name string, content string) plumbing.Hash {
+	t.Helper()
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("failed to get worktree: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(wt.Filesystem.Root(), name), []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	if _, err := wt.Add(name); err != nil {
+		t.Fatalf("failed to add file: %v", err)
+	}
+
+	hash, err := wt.Commit("update "+name, &git.CommitOptions{
+		Author: &object.Signature{Name: "chartsutil-test", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+
+	return hash
+}
+
+func setupUpstream(t *testing.T) (string, *git.Repository) {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatalf("failed to init upstream: %v", err)
+	}
+
+	return dir, repo
+}
+
+func TestRepo(t *testing.T) {
+	upstreamDir, upstream := setupUpstream(t)
+	first := commitFile(t, upstream, "README.md", "first")
+
+	c := &cache.Cache{Root: t.TempDir()}
+
+	repo, err := c.Repo(context.Background(), upstreamDir, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := repo.CommitObject(first); err != nil {
+		t.Errorf("expected cached clone to contain first commit: %v", err)
+	}
+
+	if err := repo.Close(); err != nil {
+		t.Fatalf("failed to close repo: %v", err)
+	}
+
+	second := commitFile(t, upstream, "README.md", "second")
+
+	repo, err = c.Repo(context.Background(), upstreamDir, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer repo.Close()
+
+	if repo.Path != c.RepoDir(upstreamDir) {
+		t.Errorf("expected repo at '%s' but found '%s'", c.RepoDir(upstreamDir), repo.Path)
+	}
+
+	if _, err := repo.CommitObject(second); err != nil {
+		t.Errorf("expected cached clone to be fetched with second commit: %v", err)
+	}
+}
+
+func TestRepoAlreadyOpen(t *testing.T) {
+	upstreamDir, upstream := setupUpstream(t)
+	commitFile(t, upstream, "README.md", "first")
+
+	c := &cache.Cache{Root: t.TempDir()}
+
+	first, err := c.Repo(context.Background(), upstreamDir, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	second, err := c.Repo(ctx, upstreamDir, nil)
+	if err != nil {
+		t.Fatalf("expected clone already open in this process to be reused: %v", err)
+	}
+
+	if err := first.Close(); err != nil {
+		t.Fatalf("failed to close repo: %v", err)
+	}
+
+	if _, err := second.Head(); err != nil {
+		t.Errorf("expected clone to still be usable after another handle was closed: %v", err)
+	}
+
+	if err := second.Close(); err != nil {
+		t.Fatalf("failed to close repo: %v", err)
+	}
+
+	// once every handle is closed the entry is no longer in use and can be pruned
+	pruned, err := c.Prune(time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(pruned) != 1 {
+		t.Errorf("expected closed clone to be pruned but found %d pruned entries", len(pruned))
+	}
+}
+
+func TestRepoDefaultBranch(t *testing.T) {
+	upstreamDir := t.TempDir()
+
+	upstream, err := git.PlainInitWithOptions(upstreamDir, &git.PlainInitOptions{
+		InitOptions: git.InitOptions{DefaultBranch: plumbing.NewBranchReferenceName("main")},
+	})
+	if err != nil {
+		t.Fatalf("failed to init upstream: %v", err)
+	}
+
+	head := commitFile(t, upstream, "README.md", "main")
+
+	c := &cache.Cache{Root: t.TempDir()}
+
+	repo, err := c.Repo(context.Background(), upstreamDir, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer repo.Close()
+
+	actual, err := repo.DefaultBranch()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if actual != head {
+		t.Errorf("expected default branch at '%s' but found '%s'", head, actual)
+	}
+}
+
+func TestRepoMissing(t *testing.T) {
+	c := &cache.Cache{Root: t.TempDir()}
+	missing := filepath.Join(t.TempDir(), "missing")
+
+	if _, err := c.Repo(context.Background(), missing, nil); err == nil {
+		t.Fatal("expected error but found none")
+	}
+
+	entries, err := os.ReadDir(c.RepoDir(missing))
+	if err != nil {
+		t.Fatalf("failed to read cache dir: %v", err)
+	}
+
+	for _, entry := range entries {
+		if entry.Name() != ".chartsutil-lock" {
+			t.Errorf("expected failed clone to be cleaned up but found '%s'", entry.Name())
+		}
+	}
+}
+
+func TestPrune(t *testing.T) {
+	upstreamDir, upstream := setupUpstream(t)
+	commitFile(t, upstream, "README.md", "first")
+
+	otherDir, other := setupUpstream(t)
+	commitFile(t, other, "README.md", "other")
+
+	c := &cache.Cache{Root: t.TempDir()}
+
+	repo, err := c.Repo(context.Background(), upstreamDir, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	repo.Close()
+
+	inUse, err := c.Repo(context.Background(), otherDir, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer inUse.Close()
+
+	pruned, err := c.Prune(time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(pruned) != 0 {
+		t.Errorf("expected recently used entries to be kept but pruned %v", pruned)
+	}
+
+	pruned, err = c.Prune(time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(pruned) != 1 || pruned[0].URL != upstreamDir {
+		t.Errorf("expected only '%s' to be pruned but found %v", upstreamDir, pruned)
+	}
+
+	if _, err := os.Stat(c.RepoDir(upstreamDir)); !os.IsNotExist(err) {
+		t.Errorf("expected pruned entry to be removed: %v", err)
+	}
+
+	if _, err := os.Stat(c.RepoDir(otherDir)); err != nil {
+		t.Errorf("expected entry in use to be kept: %v", err)
+	}
+}
+
+func TestArchive(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte("archive"))
+	}))
+	defer server.Close()
+
+	c := &cache.Cache{Root: t.TempDir()}
+	url := server.URL + "/chart-1.0.0.tgz"
+
+	for i := 0; i < 2; i++ {
+		path, err := c.Archive(context.Background(), url)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("failed to read archive: %v", err)
+		}
+
+		if string(data) != "archive" {
+			t.Errorf("expected 'archive' but found '%s'", data)
+		}
+	}
+
+	if requests != 1 {
+		t.Errorf("expected archive to be downloaded once but was downloaded %d times", requests)
+	}
+}
+
+func TestArchiveNotFound(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	c := &cache.Cache{Root: t.TempDir()}
+
+	if _, err := c.Archive(context.Background(), server.URL+"/missing.tgz"); err == nil {
+		t.Fatal("expected error but found none")
+	}
+}
+
+func TestTransport(t *testing.T) {
+	requests, notModified := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		w.Header().Set("X-Request", fmt.Sprint(requests))
+
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("response"))
+	}))
+	defer server.Close()
+
+	c := &cache.Cache{Root: t.TempDir()}
+	client := &http.Client{Transport: c.Transport(nil)}
+
+	for i := 1; i <= 3; i++ {
+		resp, err := client.Get(server.URL + "/releases")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("failed to read body: %v", err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("expected status 200 but found %d", resp.StatusCode)
+		}
+
+		if string(body) != "response" {
+			t.Errorf("expected 'response' but found '%s'", body)
+		}
+
+		if actual := resp.Header.Get("X-Request"); actual != fmt.Sprint(i) {
+			t.Errorf("expected fresh headers from request %d but found '%s'", i, actual)
+		}
+	}
+
+	if notModified != 2 {
+		t.Errorf("expected 2 revalidated responses but found %d", notModified)
+	}
+
+	entries, err := c.Entries()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(entries) != 1 || entries[0].URL != server.URL+"/releases" {
+		t.Errorf("expected a single entry for the response but found %v", entries)
+	}
+}
@@ -0,0 +1,158 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// responseFileName is the name of the stored response in a cached response entry.
+const responseFileName = "response"
+
+// cachingTransport revalidates GET requests against their stored responses so unchanged responses are served from the cache.
+type cachingTransport struct {
+	cache *Cache
+	base  http.RoundTripper
+}
+
+// Transport returns an http.RoundTripper which stores responses carrying an ETag or Last-Modified header, and serves them again when the server reports they are unchanged (304 Not Modified). Responses are keyed by url, Accept and Authorization headers so credentials never share a response.
+func (c *Cache) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return &cachingTransport{cache: c, base: base}
+}
+
+// ResponseDir returns the directory of the cached response for the request.
+func (c *Cache) ResponseDir(req *http.Request) string {
+	return filepath.Join(c.Root, ResponsesDir, key(req.URL.String()+"\n"+req.Header.Get("Accept")+"\n"+req.Header.Get("Authorization")))
+}
+
+func (t *cachingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet || req.Header.Get("Range") != "" {
+		return t.base.RoundTrip(req)
+	}
+
+	dir := t.cache.ResponseDir(req)
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cache dir: %w", err)
+	}
+
+	l, err := acquire(filepath.Join(dir, lockFileName), true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock cached response: %w", err)
+	}
+	defer l.Unlock()
+
+	path := filepath.Join(dir, responseFileName)
+
+	// a missing or corrupt response is simply fetched again
+	cached, _ := readResponse(path, req)
+
+	if cached != nil {
+		req = req.Clone(req.Context())
+
+		if etag := cached.Header.Get("ETag"); etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+
+		if modified := cached.Header.Get("Last-Modified"); modified != "" {
+			req.Header.Set("If-Modified-Since", modified)
+		}
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		resp.Body.Close()
+
+		// the fresh headers carry information like the remaining rate limit
+		for k, v := range resp.Header {
+			cached.Header[k] = v
+		}
+
+		return cached, nil
+	}
+
+	if resp.StatusCode != http.StatusOK || (resp.Header.Get("ETag") == "" && resp.Header.Get("Last-Modified") == "") {
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	resp.ContentLength = int64(len(body))
+	resp.TransferEncoding = nil
+
+	if err := writeResponse(dir, path, resp, body); err != nil {
+		return nil, err
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, urlFileName), []byte(req.URL.String()), 0644); err != nil {
+		return nil, fmt.Errorf("failed to record url of cached response: %w", err)
+	}
+
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	return resp, nil
+}
+
+// readResponse reads the response stored at path for the request.
+func readResponse(path string, req *http.Request) (*http.Response, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(data)), req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, errors.New("cached response is not ok")
+	}
+
+	return resp, nil
+}
+
+// writeResponse atomically stores the response, whose body has already been read, at path.
+func writeResponse(dir string, path string, resp *http.Response, body []byte) error {
+	tmp, err := os.CreateTemp(dir, "response-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	stored := *resp
+	stored.Body = io.NopCloser(bytes.NewReader(body))
+
+	if err := stored.Write(tmp); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write cached response: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close cached response: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to move response into cache: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ErrLocked is returned when a cache entry is in use by another process.
+var ErrLocked = errors.New("cache entry is locked")
+
+// lock is an advisory lock on a cache entry. Shared locks are held while an entry is read, exclusive locks while it is being modified.
+type lock struct {
+	f *os.File
+}
+
+func openLockFile(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	// track when the entry was last used for pruning
+	now := time.Now()
+	os.Chtimes(path, now, now)
+
+	return f, nil
+}
+
+// acquire blocks until the lock at path is held, exclusively if requested.
+func acquire(path string, exclusive bool) (*lock, error) {
+	f, err := openLockFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := flock(f, exclusive, true); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &lock{f: f}, nil
+}
+
+// tryAcquire takes an exclusive lock at path without blocking, returning ErrLocked if it is held elsewhere. Unlike acquire, the entry's last use is not updated.
+func tryAcquire(path string) (*lock, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	if err := flock(f, true, false); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &lock{f: f}, nil
+}
+
+// Downgrade converts an exclusive lock into a shared lock.
+func (l *lock) Downgrade() error {
+	return flock(l.f, false, true)
+}
+
+func (l *lock) Unlock() error {
+	if err := funlock(l.f); err != nil {
+		l.f.Close()
+		return err
+	}
+
+	return l.f.Close()
+}
@@ -0,0 +1,16 @@
+//go:build !unix
+
+package cache
+
+import (
+	"os"
+)
+
+// flock is a no-op on platforms without flock, concurrent runs sharing a cache are not protected.
+func flock(f *os.File, exclusive bool, block bool) error {
+	return nil
+}
+
+func funlock(f *os.File) error {
+	return nil
+}
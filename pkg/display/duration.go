@@ -3,6 +3,8 @@ package display
 import (
 	"fmt"
 	"math"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -159,3 +161,117 @@ func (d Duration) String() string {
 
 	return out
 }
+
+var durationUnitPattern = regexp.MustCompile(`(\d+)\s*([a-zA-Z]+)\s*`)
+
+// ParseDuration parses a duration in the units printed by Duration.String, either in full ("1 year 2 weeks") or abbreviated ("1y2w"). Supported abbreviations are y, mo, w, d, h, and m.
+func ParseDuration(s string) (Duration, error) {
+	var d Duration
+
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return d, fmt.Errorf("empty duration")
+	}
+
+	matches := durationUnitPattern.FindAllStringSubmatchIndex(s, -1)
+
+	end := 0
+	for _, m := range matches {
+		if m[0] != end {
+			return d, fmt.Errorf("invalid duration '%s'", s)
+		}
+		end = m[1]
+
+		n, err := strconv.ParseInt(s[m[2]:m[3]], 10, 64)
+		if err != nil {
+			return d, fmt.Errorf("invalid duration '%s': %w", s, err)
+		}
+
+		switch unit := strings.ToLower(s[m[4]:m[5]]); unit {
+		case "y", "year", "years":
+			d.Years += n
+		case "mo", "month", "months":
+			d.Months += n
+		case "w", "week", "weeks":
+			d.Weeks += n
+		case "d", "day", "days":
+			d.Days += n
+		case "h", "hour", "hours":
+			d.Hours += n
+		case "m", "min", "mins", "minute", "minutes":
+			d.Minutes += n
+		default:
+			return d, fmt.Errorf("unknown duration unit '%s'", unit)
+		}
+	}
+
+	if end != len(s) {
+		return d, fmt.Errorf("invalid duration '%s'", s)
+	}
+
+	return d, nil
+}
+
+// IsZero returns true if the duration has no non-zero fields.
+func (d Duration) IsZero() bool {
+	return d == Duration{}
+}
+
+// Approximate converts the duration to a time.Duration using the approximate number of hours in each unit.
+func (d Duration) Approximate() time.Duration {
+	hours := d.Hours +
+		d.Days*ApproximateHoursPerDay +
+		d.Weeks*ApproximateHoursPerWeek +
+		d.Months*ApproximateHoursPerMonth +
+		d.Years*ApproximateHoursPerYear
+
+	return time.Duration(hours)*time.Hour + time.Duration(d.Minutes)*time.Minute
+}
+
+// firstMonday is the first Monday after the unix epoch, which weeks are counted from.
+var firstMonday = time.Date(1970, time.January, 5, 0, 0, 0, 0, time.UTC)
+
+// WindowStart returns the start of the calendar window of length d containing t, in UTC. Durations of a single unit of days or longer are aligned to the calendar (ex. "1 week" windows start on Monday, and "3 months" windows start in January, April, July, and October), anything else is aligned to the zero time.
+func (d Duration) WindowStart(t time.Time) time.Time {
+	t = t.UTC()
+
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+
+	switch d {
+	case Duration{Years: d.Years}:
+		if d.Years > 0 {
+			return time.Date(t.Year()-t.Year()%int(d.Years), time.January, 1, 0, 0, 0, 0, time.UTC)
+		}
+	case Duration{Months: d.Months}:
+		month := t.Year()*12 + int(t.Month()) - 1
+		month -= month % int(d.Months)
+
+		return time.Date(month/12, time.Month(month%12+1), 1, 0, 0, 0, 0, time.UTC)
+	case Duration{Weeks: d.Weeks}:
+		weeks := floorDiv(int64(day.Sub(firstMonday).Hours()), ApproximateHoursPerWeek)
+		weeks = floorDiv(weeks, d.Weeks) * d.Weeks
+
+		return firstMonday.AddDate(0, 0, int(weeks*7))
+	case Duration{Days: d.Days}:
+		days := floorDiv(int64(day.Sub(firstMonday).Hours()), ApproximateHoursPerDay)
+		days = floorDiv(days, d.Days) * d.Days
+
+		return firstMonday.AddDate(0, 0, int(days))
+	}
+
+	if d.IsZero() {
+		return t
+	}
+
+	return t.Truncate(d.Approximate())
+}
+
+// floorDiv divides a by b rounding towards negative infinity, so times before firstMonday fall into the right window.
+func floorDiv(a int64, b int64) int64 {
+	q := a / b
+	if (a%b != 0) && ((a < 0) != (b < 0)) {
+		q--
+	}
+
+	return q
+}
@@ -126,3 +126,140 @@ func TestStringifyDuration(t *testing.T) {
 		}
 	}
 }
+
+func TestParseDuration(t *testing.T) {
+	type Case struct {
+		Name     string
+		Input    string
+		Expected display.Duration
+		Err      bool
+	}
+
+	cases := []Case{
+		{
+			Name:     "Abbreviated",
+			Input:    "7d",
+			Expected: display.Duration{Days: 7},
+		},
+		{
+			Name:     "AbbreviatedMultiple",
+			Input:    "1y2mo3w",
+			Expected: display.Duration{Years: 1, Months: 2, Weeks: 3},
+		},
+		{
+			Name:     "Full",
+			Input:    "6 years 5 months 4 weeks 3 days 2 hours 1 minute",
+			Expected: display.Duration{Years: 6, Months: 5, Weeks: 4, Days: 3, Hours: 2, Minutes: 1},
+		},
+		{
+			Name:  "Empty",
+			Input: "",
+			Err:   true,
+		},
+		{
+			Name:  "NoUnit",
+			Input: "7",
+			Err:   true,
+		},
+		{
+			Name:  "UnknownUnit",
+			Input: "7 fortnights",
+			Err:   true,
+		},
+		{
+			Name:  "Garbage",
+			Input: "about 7d",
+			Err:   true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			actual, err := display.ParseDuration(c.Input)
+			if c.Err {
+				if err == nil {
+					t.Fatalf("expected error but found %+v", actual)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if actual != c.Expected {
+				t.Errorf("duration is wrong:\nexpected: %+v\n   found: %+v", c.Expected, actual)
+			}
+
+			// anything we print should be parsable
+			if roundTrip, err := display.ParseDuration(actual.String()); err != nil || roundTrip != actual {
+				t.Errorf("failed to parse printed duration '%s': %v", actual.String(), err)
+			}
+		})
+	}
+}
+
+func TestWindowStart(t *testing.T) {
+	type Case struct {
+		Name     string
+		Window   display.Duration
+		Time     time.Time
+		Expected time.Time
+	}
+
+	// 2024-05-15 is a Wednesday
+	now := time.Date(2024, time.May, 15, 13, 45, 0, 0, time.UTC)
+
+	cases := []Case{
+		{
+			Name:     "Day",
+			Window:   display.Duration{Days: 1},
+			Time:     now,
+			Expected: time.Date(2024, time.May, 15, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			Name:     "Week",
+			Window:   display.Duration{Weeks: 1},
+			Time:     now,
+			Expected: time.Date(2024, time.May, 13, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			Name:     "Month",
+			Window:   display.Duration{Months: 1},
+			Time:     now,
+			Expected: time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			Name:     "Quarter",
+			Window:   display.Duration{Months: 3},
+			Time:     now,
+			Expected: time.Date(2024, time.April, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			Name:     "Year",
+			Window:   display.Duration{Years: 1},
+			Time:     now,
+			Expected: time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			Name:     "Hours",
+			Window:   display.Duration{Hours: 6},
+			Time:     now,
+			Expected: time.Date(2024, time.May, 15, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			Name:     "Timezone",
+			Window:   display.Duration{Days: 1},
+			Time:     time.Date(2024, time.May, 15, 23, 0, 0, 0, time.FixedZone("UTC-2", -2*60*60)),
+			Expected: time.Date(2024, time.May, 16, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			if actual := c.Window.WindowStart(c.Time); !actual.Equal(c.Expected) {
+				t.Errorf("expected window to start at %s but found %s", c.Expected, actual)
+			}
+		})
+	}
+}
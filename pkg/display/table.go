@@ -55,3 +55,32 @@ func (t Table) String() string {
 
 	return builder.String()
 }
+
+// Markdown formats the table as a GitHub flavored markdown table.
+func (t Table) Markdown() string {
+	builder := strings.Builder{}
+
+	escape := strings.NewReplacer("|", "\\|", "\n", " ")
+
+	builder.WriteRune('|')
+	for _, c := range t {
+		builder.WriteString(fmt.Sprintf(" %s |", escape.Replace(c.Header)))
+	}
+	builder.WriteRune('\n')
+
+	builder.WriteRune('|')
+	for range t {
+		builder.WriteString(" --- |")
+	}
+	builder.WriteRune('\n')
+
+	for i := 0; i < len(t[0].Data); i++ {
+		builder.WriteRune('|')
+		for _, c := range t {
+			builder.WriteString(fmt.Sprintf(" %s |", escape.Replace(c.Data[i])))
+		}
+		builder.WriteRune('\n')
+	}
+
+	return builder.String()
+}
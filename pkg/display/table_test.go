@@ -26,3 +26,19 @@ some-super-long-tag-v1.0.0 5s  123        `
 		t.Fail()
 	}
 }
+
+func TestTableMarkdown(t *testing.T) {
+	table := display.NewTable("Name", "Age")
+	table.AddRow("v1.0.0", "1h")
+	table.AddRow("a|b", "")
+
+	expected := `| Name | Age |
+| --- | --- |
+| v1.0.0 | 1h |
+| a\|b |  |
+`
+
+	if actual := table.Markdown(); expected != actual {
+		t.Errorf("expected:\n%s\nbut found:\n%s", expected, actual)
+	}
+}
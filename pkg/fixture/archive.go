@@ -0,0 +1,139 @@
+package fixture
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// ArchiveURL returns the url of a gzipped tarball of the named commit, served by a local http server which is closed when the test completes. Like archives from GitHub, every file in the archive is nested in a single root directory.
+func (u *Upstream) ArchiveURL(name string) string {
+	u.t.Helper()
+
+	// fail early rather than in the server
+	u.Hash(name)
+
+	if u.archives == nil {
+		// serves archives of upstream commits like GitHub's '/archive/<ref>.tar.gz' endpoint
+		u.archives = httptest.NewServer(http.HandlerFunc(u.serveArchive))
+		u.t.Cleanup(u.archives.Close)
+	}
+
+	return fmt.Sprintf("%s/archive/%s.tar.gz", u.archives.URL, name)
+}
+
+func (u *Upstream) serveArchive(w http.ResponseWriter, r *http.Request) {
+	name, found := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/archive/"), ".tar.gz")
+	if !found {
+		http.NotFound(w, r)
+		return
+	}
+
+	hash, found := u.hashes[name]
+	if !found {
+		http.NotFound(w, r)
+		return
+	}
+
+	commit, err := u.Repo.CommitObject(hash)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/gzip")
+
+	if err := writeArchive(w, commit, "upstream-"+name); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// writeArchive writes the tree of the commit as a gzipped tarball with every file under root.
+func writeArchive(w io.Writer, commit *object.Commit, root string) error {
+	tree, err := commit.Tree()
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	dirs := map[string]bool{}
+
+	writeDir := func(dir string) error {
+		if dirs[dir] {
+			return nil
+		}
+		dirs[dir] = true
+
+		return tw.WriteHeader(&tar.Header{
+			Name:     dir + "/",
+			Typeflag: tar.TypeDir,
+			Mode:     0755,
+			ModTime:  commit.Committer.When,
+		})
+	}
+
+	if err := writeDir(root); err != nil {
+		return err
+	}
+
+	err = tree.Files().ForEach(func(f *object.File) error {
+		name := path.Join(root, f.Name)
+
+		// parent directories must come before the files in them
+		dir := root
+		for _, part := range strings.Split(path.Dir(f.Name), "/") {
+			if part == "." {
+				break
+			}
+
+			dir = path.Join(dir, part)
+			if err := writeDir(dir); err != nil {
+				return err
+			}
+		}
+
+		contents, err := f.Contents()
+		if err != nil {
+			return err
+		}
+
+		mode := int64(0644)
+		if f.Mode == filemode.Executable {
+			mode = 0755
+		}
+
+		header := &tar.Header{
+			Name:     name,
+			Typeflag: tar.TypeReg,
+			Mode:     mode,
+			Size:     int64(len(contents)),
+			ModTime:  commit.Committer.When,
+		}
+
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+
+		_, err = io.WriteString(tw, contents)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	return gz.Close()
+}
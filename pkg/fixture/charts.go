@@ -0,0 +1,230 @@
+package fixture
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/joshmeranda/chartsutil/pkg/cache"
+	"github.com/joshmeranda/chartsutil/pkg/iter"
+	"github.com/rancher/charts-build-scripts/pkg/charts"
+	"github.com/rancher/charts-build-scripts/pkg/filesystem"
+	"github.com/rancher/charts-build-scripts/pkg/options"
+	chartspath "github.com/rancher/charts-build-scripts/pkg/path"
+)
+
+// Chart returns the files of a minimal helm chart under dir, which deploys the nginx image set in its values.
+func Chart(dir string, name string, version string) map[string]string {
+	return map[string]string{
+		filepath.Join(dir, "Chart.yaml"):  fmt.Sprintf("apiVersion: v2\nname: %s\nversion: %s\nappVersion: %s\n", name, version, version),
+		filepath.Join(dir, "values.yaml"): "replicas: 1\nimage:\n  repository: nginx\n  tag: latest\n",
+		filepath.Join(dir, "templates", "deployment.yaml"): `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: {{ .Release.Name }}
+spec:
+  replicas: {{ .Values.replicas }}
+  selector:
+    matchLabels:
+      app: {{ .Release.Name }}
+  template:
+    metadata:
+      labels:
+        app: {{ .Release.Name }}
+    spec:
+      containers:
+        - name: nginx
+          image: {{ .Values.image.repository }}:{{ .Values.image.tag }}
+`,
+	}
+}
+
+// Charts is a charts repository laid out as expected by charts-build-scripts.
+type Charts struct {
+	// Dir is the root of the repository.
+	Dir string
+
+	Repo   *git.Repository
+	RootFs billy.Filesystem
+
+	// Cache holds the package upstreams, kept apart from the user's cache.
+	Cache *cache.Cache
+
+	t  testing.TB
+	wt *git.Worktree
+}
+
+// NewCharts creates a charts repository with a single commit which is removed when the test completes.
+func NewCharts(t testing.TB) *Charts {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatalf("failed to init charts repository: %v", err)
+	}
+
+	// rebases shell out to git, which needs to know who is committing
+	cfg, err := repo.Config()
+	if err != nil {
+		t.Fatalf("failed to get charts repository config: %v", err)
+	}
+
+	cfg.User.Name = Author
+	cfg.User.Email = Author + "@example.com"
+
+	if err := repo.SetConfig(cfg); err != nil {
+		t.Fatalf("failed to set charts repository config: %v", err)
+	}
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("failed to get worktree: %v", err)
+	}
+
+	c := &Charts{
+		Dir:    dir,
+		Repo:   repo,
+		RootFs: filesystem.GetFilesystem(dir),
+		Cache:  &cache.Cache{Root: t.TempDir()},
+		t:      t,
+		wt:     wt,
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("# Charts\n"), 0644); err != nil {
+		t.Fatalf("failed to write readme: %v", err)
+	}
+
+	c.commit("initial commit", "README.md")
+
+	return c
+}
+
+// AddPackage adds and commits a package tracking the upstream. The changes (file paths relative to the chart mapped to their content) are made to the prepared chart and saved to the package's generated-changes.
+func (c *Charts) AddPackage(name string, upstream options.UpstreamOptions, changes map[string]string) *charts.Package {
+	c.t.Helper()
+
+	pkgDir := filepath.Join(chartspath.RepositoryPackagesDir, name)
+
+	if err := os.MkdirAll(filepath.Join(c.Dir, pkgDir), 0755); err != nil {
+		c.t.Fatalf("failed to create package dir: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(c.Dir, pkgDir, chartspath.PackageOptionsFile), []byte(packageYaml(upstream)), 0644); err != nil {
+		c.t.Fatalf("failed to write package.yaml: %v", err)
+	}
+
+	pkg, pkgFs := c.Package(name)
+
+	if err := pkg.Prepare(); err != nil {
+		c.t.Fatalf("failed to prepare package: %v", err)
+	}
+
+	for file, content := range changes {
+		path := filepath.Join(pkgFs.Root(), pkg.WorkingDir, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			c.t.Fatalf("failed to create dir: %v", err)
+		}
+
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			c.t.Fatalf("failed to write chart change: %v", err)
+		}
+	}
+
+	if err := pkg.GeneratePatch(); err != nil {
+		c.t.Fatalf("failed to generate patch: %v", err)
+	}
+
+	if err := pkg.Clean(); err != nil {
+		c.t.Fatalf("failed to clean package: %v", err)
+	}
+
+	c.commit("add package "+name, pkgDir)
+
+	pkg, _ = c.Package(name)
+
+	return pkg
+}
+
+// Package loads the named package and its filesystem. The package upstream is pulled from the fixture's cache and keeps the url from the package.yaml.
+func (c *Charts) Package(name string) (*charts.Package, billy.Filesystem) {
+	c.t.Helper()
+
+	pkgFs, err := c.RootFs.Chroot(filepath.Join(chartspath.RepositoryPackagesDir, name))
+	if err != nil {
+		c.t.Fatalf("failed to chroot to package dir: %v", err)
+	}
+
+	pkg, err := charts.GetPackage(c.RootFs, name)
+	if err != nil {
+		c.t.Fatalf("failed to get package: %v", err)
+	} else if pkg == nil {
+		c.t.Fatalf("no such package '%s'", name)
+	}
+
+	upstream, err := iter.PackageUpstream(pkgFs)
+	if err != nil {
+		c.t.Fatalf("failed to get package upstream: %v", err)
+	}
+
+	pkg.Chart.Upstream = iter.CachedPuller(upstream, c.Cache)
+
+	return pkg, pkgFs
+}
+
+// Head returns the commit at the HEAD of the charts repository.
+func (c *Charts) Head() *object.Commit {
+	c.t.Helper()
+
+	head, err := c.Repo.Head()
+	if err != nil {
+		c.t.Fatalf("failed to get HEAD: %v", err)
+	}
+
+	commit, err := c.Repo.CommitObject(head.Hash())
+	if err != nil {
+		c.t.Fatalf("failed to get commit: %v", err)
+	}
+
+	return commit
+}
+
+func (c *Charts) commit(msg string, path string) {
+	c.t.Helper()
+
+	if _, err := c.wt.Add(path); err != nil {
+		c.t.Fatalf("failed to stage '%s': %v", path, err)
+	}
+
+	_, err := c.wt.Commit(msg, &git.CommitOptions{
+		Author: &object.Signature{Name: Author, Email: Author + "@example.com", When: time.Now()},
+	})
+	if err != nil {
+		c.t.Fatalf("failed to commit: %v", err)
+	}
+}
+
+func packageYaml(upstream options.UpstreamOptions) string {
+	b := &strings.Builder{}
+
+	fmt.Fprintf(b, "url: %s\n", upstream.URL)
+
+	if upstream.Commit != nil {
+		fmt.Fprintf(b, "commit: %s\n", *upstream.Commit)
+	}
+
+	if upstream.Subdirectory != nil {
+		fmt.Fprintf(b, "subdirectory: %s\n", *upstream.Subdirectory)
+	}
+
+	b.WriteString("packageVersion: 1\n")
+
+	return b.String()
+}
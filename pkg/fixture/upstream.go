@@ -0,0 +1,241 @@
+// Package fixture builds upstream git repositories and charts repositories in temporary directories so tests can run without network access.
+package fixture
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// Author is the name of the author of every upstream commit.
+const Author = "chartsutil-test"
+
+// Upstream is a local upstream git repository whose history is scripted one named commit at a time.
+type Upstream struct {
+	// Dir is the path to the repository, it ends in '.git' so it can be used as an upstream url.
+	Dir string
+
+	Repo *git.Repository
+
+	// Interval is the time between each commit, the first commit is made one interval after the unix epoch.
+	Interval time.Duration
+
+	t      testing.TB
+	wt     *git.Worktree
+	n      int
+	hashes map[string]plumbing.Hash
+
+	archives *httptest.Server
+}
+
+// NewUpstream creates an empty upstream repository which is removed when the test completes.
+func NewUpstream(t testing.TB) *Upstream {
+	t.Helper()
+
+	dir := filepath.Join(t.TempDir(), "upstream.git")
+
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatalf("failed to init upstream: %v", err)
+	}
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("failed to get worktree: %v", err)
+	}
+
+	return &Upstream{
+		Dir:      dir,
+		Repo:     repo,
+		Interval: time.Second,
+		t:        t,
+		wt:       wt,
+		hashes:   make(map[string]plumbing.Hash),
+	}
+}
+
+// Hash returns the hash of the named commit.
+func (u *Upstream) Hash(name string) plumbing.Hash {
+	u.t.Helper()
+
+	hash, found := u.hashes[name]
+	if !found {
+		u.t.Fatalf("no upstream commit named '%s'", name)
+	}
+
+	return hash
+}
+
+// Name returns the name of the commit with the given hash.
+func (u *Upstream) Name(hash string) (string, bool) {
+	for name, h := range u.hashes {
+		if h.String() == hash {
+			return name, true
+		}
+	}
+
+	return "", false
+}
+
+// Commit creates a commit named name which writes its name to file.
+func (u *Upstream) Commit(name string, file string, parents ...string) plumbing.Hash {
+	u.t.Helper()
+	return u.CommitFiles(name, map[string]string{file: name}, parents...)
+}
+
+// CommitContent creates a commit named name which writes content to file.
+func (u *Upstream) CommitContent(name string, file string, content string, parents ...string) plumbing.Hash {
+	u.t.Helper()
+	return u.CommitFiles(name, map[string]string{file: content}, parents...)
+}
+
+// CommitFiles creates a commit named name on top of the given parents which writes each of the files. The first parent's tree is used as the base of the commit.
+func (u *Upstream) CommitFiles(name string, files map[string]string, parents ...string) plumbing.Hash {
+	u.t.Helper()
+
+	u.checkout(parents)
+
+	for file, content := range files {
+		path := filepath.Join(u.Dir, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			u.t.Fatalf("failed to create dir: %v", err)
+		}
+
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			u.t.Fatalf("failed to write file: %v", err)
+		}
+
+		if _, err := u.wt.Add(file); err != nil {
+			u.t.Fatalf("failed to add file: %v", err)
+		}
+	}
+
+	return u.commit(name, parents)
+}
+
+// Move creates a commit named name which moves the from directory to the to directory, if to is empty the directory is removed instead.
+func (u *Upstream) Move(name string, from string, to string, parent string) plumbing.Hash {
+	u.t.Helper()
+
+	u.checkout([]string{parent})
+
+	if from != "" {
+		if to == "" {
+			if err := os.RemoveAll(filepath.Join(u.Dir, from)); err != nil {
+				u.t.Fatalf("failed to remove dir: %v", err)
+			}
+		} else {
+			if err := os.MkdirAll(filepath.Dir(filepath.Join(u.Dir, to)), 0755); err != nil {
+				u.t.Fatalf("failed to create dir: %v", err)
+			}
+
+			if err := os.Rename(filepath.Join(u.Dir, from), filepath.Join(u.Dir, to)); err != nil {
+				u.t.Fatalf("failed to move dir: %v", err)
+			}
+		}
+	}
+
+	status, err := u.wt.Status()
+	if err != nil {
+		u.t.Fatalf("failed to get status: %v", err)
+	}
+
+	for file, s := range status {
+		if s.Worktree == git.Deleted {
+			_, err = u.wt.Remove(file)
+		} else {
+			_, err = u.wt.Add(file)
+		}
+
+		if err != nil {
+			u.t.Fatalf("failed to stage '%s': %v", file, err)
+		}
+	}
+
+	return u.commit(name, []string{parent})
+}
+
+// Branch points a branch at the named commit. Commits are only fetched from the upstream when they are reachable from a branch or tag.
+func (u *Upstream) Branch(branch string, name string) {
+	u.t.Helper()
+
+	ref := plumbing.NewHashReference(plumbing.NewBranchReferenceName(branch), u.Hash(name))
+	if err := u.Repo.Storer.SetReference(ref); err != nil {
+		u.t.Fatalf("failed to create branch: %v", err)
+	}
+}
+
+// Tag creates a lightweight tag pointing at the named commit.
+func (u *Upstream) Tag(tag string, name string) {
+	u.t.Helper()
+
+	if _, err := u.Repo.CreateTag(tag, u.Hash(name), nil); err != nil {
+		u.t.Fatalf("failed to create tag: %v", err)
+	}
+}
+
+// AnnotatedTag creates an annotated tag pointing at the named commit.
+func (u *Upstream) AnnotatedTag(tag string, name string, message string) {
+	u.t.Helper()
+
+	opts := &git.CreateTagOptions{
+		Tagger:  u.signature(),
+		Message: message,
+	}
+
+	if _, err := u.Repo.CreateTag(tag, u.Hash(name), opts); err != nil {
+		u.t.Fatalf("failed to create tag: %v", err)
+	}
+}
+
+// checkout starts the next commit from the first parent's tree rather than whatever was last committed.
+func (u *Upstream) checkout(parents []string) {
+	u.t.Helper()
+
+	if len(parents) == 0 {
+		return
+	}
+
+	if err := u.wt.Checkout(&git.CheckoutOptions{Hash: u.Hash(parents[0]), Force: true}); err != nil {
+		u.t.Fatalf("failed to checkout parent: %v", err)
+	}
+}
+
+func (u *Upstream) commit(name string, parents []string) plumbing.Hash {
+	u.t.Helper()
+
+	u.n++
+
+	hashes := make([]plumbing.Hash, len(parents))
+	for i, p := range parents {
+		hashes[i] = u.Hash(p)
+	}
+
+	hash, err := u.wt.Commit(name, &git.CommitOptions{
+		Author:  u.signature(),
+		Parents: hashes,
+		// go-git considers the tree clean when every file in it is removed
+		AllowEmptyCommits: true,
+	})
+	if err != nil {
+		u.t.Fatalf("failed to commit: %v", err)
+	}
+
+	u.hashes[name] = hash
+
+	return hash
+}
+
+func (u *Upstream) signature() *object.Signature {
+	return &object.Signature{
+		Name:  Author,
+		Email: Author + "@example.com",
+		When:  time.Unix(0, 0).Add(time.Duration(u.n) * u.Interval).UTC(),
+	}
+}
@@ -0,0 +1,44 @@
+package iter
+
+import (
+	"time"
+
+	"github.com/joshmeranda/chartsutil/pkg/display"
+)
+
+// Batch groups the commits a GitIter would step through into fewer steps, any commits which are not stepped to are folded into the next step. Only one of Step or Window may be set.
+type Batch struct {
+	// Step only steps to every Nth commit.
+	Step int
+
+	// Window only steps to the last commit in each calendar window (see display.Duration.WindowStart).
+	Window display.Duration
+}
+
+// IsZero returns true if the batch steps through every commit.
+func (b Batch) IsZero() bool {
+	return b.Step <= 1 && b.Window.IsZero()
+}
+
+// selectSteps returns which of the commits with the given commit times, ordered oldest first, should be stepped to. Forced commits are always stepped to and start a new batch.
+func (b Batch) selectSteps(times []time.Time, forced []bool) []bool {
+	selected := make([]bool, len(times))
+	count := 0
+
+	for n := range times {
+		switch {
+		case forced[n]:
+			selected[n] = true
+			count = 0
+		case !b.Window.IsZero():
+			selected[n] = n+1 == len(times) || !b.Window.WindowStart(times[n]).Equal(b.Window.WindowStart(times[n+1]))
+		case b.Step > 1:
+			count++
+			selected[n] = count%b.Step == 0
+		default:
+			selected[n] = true
+		}
+	}
+
+	return selected
+}
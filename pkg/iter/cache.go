@@ -0,0 +1,116 @@
+package iter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/joshmeranda/chartsutil/pkg/cache"
+	"github.com/rancher/charts-build-scripts/pkg/filesystem"
+	"github.com/rancher/charts-build-scripts/pkg/options"
+	"github.com/rancher/charts-build-scripts/pkg/puller"
+)
+
+// archiveFilepath is where cached archives are copied before being unpacked, matching the path used by puller.Archive.
+const archiveFilepath = "chart.tgz"
+
+// CachedPuller wraps the given upstream to pull from the cache rather than fetching the upstream each time. Upstreams which cannot be cached are returned as is.
+func CachedPuller(upstream puller.Puller, c *cache.Cache) puller.Puller {
+	switch u := upstream.(type) {
+	case puller.GithubRepository:
+		return &CheckoutPuller{
+			Cache: c,
+			Opts:  u.GetOptions(),
+		}
+	case *CheckoutPuller:
+		if u.Repo == nil && u.Cache == nil {
+			u.Cache = c
+		}
+
+		return u
+	case puller.Archive:
+		return &ArchivePuller{
+			Cache: c,
+			Opts: options.UpstreamOptions{
+				URL:          u.URL,
+				Subdirectory: u.Subdirectory,
+			},
+		}
+	default:
+		return upstream
+	}
+}
+
+// ArchivePuller pulls an upstream archive, downloading it to the cache only once.
+type ArchivePuller struct {
+	// Cache holds the downloaded archive, if nil the default cache is used.
+	Cache *cache.Cache
+
+	Opts options.UpstreamOptions
+}
+
+func (p *ArchivePuller) Pull(rootFs billy.Filesystem, fs billy.Filesystem, path string) error {
+	c, err := cache.OrDefault(p.Cache)
+	if err != nil {
+		return fmt.Errorf("failed to get upstream cache: %w", err)
+	}
+
+	archive, err := c.Archive(context.Background(), p.Opts.URL)
+	if err != nil {
+		return fmt.Errorf("failed to get cached archive: %w", err)
+	}
+
+	if err := copyIntoFs(fs, archive, archiveFilepath, 0644); err != nil {
+		return fmt.Errorf("failed to copy cached archive: %w", err)
+	}
+	defer fs.Remove(archiveFilepath)
+
+	if err := fs.MkdirAll(path, os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create destination: %w", err)
+	}
+	defer filesystem.PruneEmptyDirsInPath(fs, path)
+
+	var subdirectory string
+	if p.Opts.Subdirectory != nil {
+		subdirectory = *p.Opts.Subdirectory
+	}
+
+	if err := filesystem.UnarchiveTgz(fs, archiveFilepath, subdirectory, path, true); err != nil {
+		return fmt.Errorf("failed to unarchive upstream: %w", err)
+	}
+
+	return nil
+}
+
+func copyIntoFs(fs billy.Filesystem, src string, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := fs.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, perm)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
+
+// GetOptions returns only the URL to match puller.Archive.
+func (p *ArchivePuller) GetOptions() options.UpstreamOptions {
+	return options.UpstreamOptions{
+		URL: p.Opts.URL,
+	}
+}
+
+func (p *ArchivePuller) IsWithinPackage() bool {
+	return false
+}
@@ -0,0 +1,259 @@
+package iter
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"gopkg.in/yaml.v2"
+)
+
+// StepFilter selects which upstream commits an incremental rebase steps through. Commits which are filtered out are folded into the next step.
+type StepFilter struct {
+	// IncludeMessage keeps only commits whose message matches.
+	IncludeMessage *regexp.Regexp
+
+	// ExcludeMessage drops commits whose message matches.
+	ExcludeMessage *regexp.Regexp
+
+	// IncludeAuthor keeps only commits whose author, formatted as "name <email>", matches.
+	IncludeAuthor *regexp.Regexp
+
+	// ExcludeAuthor drops commits whose author, formatted as "name <email>", matches.
+	ExcludeAuthor *regexp.Regexp
+
+	// IncludePaths keeps only commits which change a file matching one of these globs, relative to the upstream subdirectory. A '**' matches any number of directories.
+	IncludePaths []string
+
+	// ExcludePaths ignores changes to files matching these globs, dropping commits which only change such files.
+	ExcludePaths []string
+
+	// ChartVersionOnly keeps only commits which change the version in the chart's Chart.yaml.
+	ChartVersionOnly bool
+}
+
+// Keep checks if the commit should be its own step, given the chart is in subdirectory.
+func (f StepFilter) Keep(c *object.Commit, subdirectory string) (bool, error) {
+	if f.IncludeMessage != nil && !f.IncludeMessage.MatchString(c.Message) {
+		return false, nil
+	}
+
+	if f.ExcludeMessage != nil && f.ExcludeMessage.MatchString(c.Message) {
+		return false, nil
+	}
+
+	author := fmt.Sprintf("%s <%s>", c.Author.Name, c.Author.Email)
+
+	if f.IncludeAuthor != nil && !f.IncludeAuthor.MatchString(author) {
+		return false, nil
+	}
+
+	if f.ExcludeAuthor != nil && f.ExcludeAuthor.MatchString(author) {
+		return false, nil
+	}
+
+	if len(f.IncludePaths) > 0 || len(f.ExcludePaths) > 0 {
+		files, err := changedFiles(c, subdirectory)
+		if err != nil {
+			return false, fmt.Errorf("failed to list changed files: %w", err)
+		}
+
+		if !anyFileMatches(files, f.IncludePaths, f.ExcludePaths) {
+			return false, nil
+		}
+	}
+
+	if f.ChartVersionOnly {
+		changed, err := changesChartVersion(c, subdirectory)
+		if err != nil {
+			return false, fmt.Errorf("failed to compare chart versions: %w", err)
+		}
+
+		if !changed {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+func anyFileMatches(files []string, include []string, exclude []string) bool {
+	for _, file := range files {
+		if matchesAny(exclude, file) {
+			continue
+		}
+
+		if len(include) == 0 || matchesAny(include, file) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if MatchGlob(pattern, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MatchGlob reports whether the slash separated name matches pattern, which follows path.Match except that a '**' element matches any number of directories.
+func MatchGlob(pattern string, name string) bool {
+	return matchSegments(strings.Split(strings.Trim(pattern, "/"), "/"), strings.Split(strings.Trim(name, "/"), "/"))
+}
+
+func matchSegments(pattern []string, name []string) bool {
+	if len(pattern) == 0 {
+		return len(name) == 0
+	}
+
+	if pattern[0] == "**" {
+		for i := 0; i <= len(name); i++ {
+			if matchSegments(pattern[1:], name[i:]) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if len(name) == 0 {
+		return false
+	}
+
+	if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+		return false
+	}
+
+	return matchSegments(pattern[1:], name[1:])
+}
+
+// subtree returns the tree at subdirectory in the commit, or an empty tree if it does not exist.
+func subtree(c *object.Commit, subdirectory string) (*object.Tree, error) {
+	tree, err := c.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	if subdirectory == "" {
+		return tree, nil
+	}
+
+	sub, err := tree.Tree(subdirectory)
+	if errors.Is(err, object.ErrDirectoryNotFound) {
+		return &object.Tree{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return sub, nil
+}
+
+// changedFiles lists the files changed by the commit within subdirectory, relative to the subdirectory.
+func changedFiles(c *object.Commit, subdirectory string) ([]string, error) {
+	current, err := subtree(c, subdirectory)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := &object.Tree{}
+
+	if c.NumParents() > 0 {
+		parent, err := c.Parent(0)
+		if err != nil {
+			return nil, err
+		}
+
+		if previous, err = subtree(parent, subdirectory); err != nil {
+			return nil, err
+		}
+	}
+
+	changes, err := object.DiffTree(previous, current)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]string, 0, len(changes))
+	for _, change := range changes {
+		if change.To.Name != "" {
+			files = append(files, change.To.Name)
+		}
+
+		if change.From.Name != "" && change.From.Name != change.To.Name {
+			files = append(files, change.From.Name)
+		}
+	}
+
+	return files, nil
+}
+
+// ChartMetadata is the version information from a chart's Chart.yaml.
+type ChartMetadata struct {
+	Version    string `yaml:"version"`
+	AppVersion string `yaml:"appVersion"`
+}
+
+// ReadChartMetadata reads the Chart.yaml in subdirectory at the commit, returning empty metadata if there is none.
+func ReadChartMetadata(c *object.Commit, subdirectory string) (ChartMetadata, error) {
+	tree, err := subtree(c, subdirectory)
+	if err != nil {
+		return ChartMetadata{}, err
+	}
+
+	f, err := tree.File("Chart.yaml")
+	if errors.Is(err, object.ErrFileNotFound) {
+		return ChartMetadata{}, nil
+	} else if err != nil {
+		return ChartMetadata{}, err
+	}
+
+	contents, err := f.Contents()
+	if err != nil {
+		return ChartMetadata{}, err
+	}
+
+	var chart ChartMetadata
+
+	if err := yaml.Unmarshal([]byte(contents), &chart); err != nil {
+		return ChartMetadata{}, fmt.Errorf("failed to parse Chart.yaml: %w", err)
+	}
+
+	return chart, nil
+}
+
+// chartVersion reads the version from the Chart.yaml in subdirectory, returning an empty string if there is none.
+func chartVersion(c *object.Commit, subdirectory string) (string, error) {
+	chart, err := ReadChartMetadata(c, subdirectory)
+	return chart.Version, err
+}
+
+func changesChartVersion(c *object.Commit, subdirectory string) (bool, error) {
+	current, err := chartVersion(c, subdirectory)
+	if err != nil {
+		return false, err
+	}
+
+	if c.NumParents() == 0 {
+		return current != "", nil
+	}
+
+	parent, err := c.Parent(0)
+	if err != nil {
+		return false, err
+	}
+
+	previous, err := chartVersion(parent, subdirectory)
+	if err != nil {
+		return false, err
+	}
+
+	return current != previous, nil
+}
@@ -0,0 +1,75 @@
+package iter_test
+
+import (
+	"testing"
+
+	"github.com/joshmeranda/chartsutil/pkg/iter"
+)
+
+func TestMatchGlob(t *testing.T) {
+	type testCase struct {
+		Name     string
+		Pattern  string
+		Path     string
+		Expected bool
+	}
+
+	cases := []testCase{
+		{
+			Name:     "Exact",
+			Pattern:  "values.yaml",
+			Path:     "values.yaml",
+			Expected: true,
+		},
+		{
+			Name:     "Star",
+			Pattern:  "*.yaml",
+			Path:     "Chart.yaml",
+			Expected: true,
+		},
+		{
+			Name:     "StarDoesNotCrossDirectories",
+			Pattern:  "*.yaml",
+			Path:     "templates/deployment.yaml",
+			Expected: false,
+		},
+		{
+			Name:     "DoubleStarSuffix",
+			Pattern:  "templates/**",
+			Path:     "templates/tests/test.yaml",
+			Expected: true,
+		},
+		{
+			Name:     "DoubleStarPrefix",
+			Pattern:  "**/README.md",
+			Path:     "charts/sub/README.md",
+			Expected: true,
+		},
+		{
+			Name:     "DoubleStarMatchesNoDirectories",
+			Pattern:  "**/README.md",
+			Path:     "README.md",
+			Expected: true,
+		},
+		{
+			Name:     "DoubleStarMiddle",
+			Pattern:  "charts/**/values.yaml",
+			Path:     "charts/a/b/values.yaml",
+			Expected: true,
+		},
+		{
+			Name:     "NoMatch",
+			Pattern:  "ci/**",
+			Path:     "templates/ci.yaml",
+			Expected: false,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			if actual := iter.MatchGlob(c.Pattern, c.Path); actual != c.Expected {
+				t.Errorf("expected %t but found %t", c.Expected, actual)
+			}
+		})
+	}
+}
@@ -1,35 +1,63 @@
 package iter
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/go-git/go-billy/v5"
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
 	"github.com/go-git/go-git/v5/plumbing/object"
-	cp "github.com/otiai10/copy"
-	"github.com/rancher/charts-build-scripts/pkg/filesystem"
+	"github.com/joshmeranda/chartsutil/pkg/cache"
 	"github.com/rancher/charts-build-scripts/pkg/options"
 	"github.com/rancher/charts-build-scripts/pkg/puller"
 )
 
+// ErrNotDescendant is returned when the target upstream commit does not descend from the current upstream commit.
+var ErrNotDescendant = errors.New("commit is not a descendant")
+
 type GitIter struct {
 	// UpstreamOptions are the options for the current package.
 	UpstreamOptions options.UpstreamOptions
 	Delta           UpstreamDelta
 
+	// Cache holds the clone of the upstream repository, if nil the default cache is used.
+	Cache *cache.Cache
+
+	// fromRef and toRef may be any ref accepted by ResolveRef and are resolved when the upstream is cloned.
+	fromRef string
+	toRef   string
+
 	fromCommit plumbing.Hash
 	toCommit   plumbing.Hash
 
+	// toSubdirectory is the subdirectory explicitly requested for the target, if any.
+	toSubdirectory *string
+
 	// commits []*object.Commit
 	deltas []UpstreamDelta
 
-	repo   *git.Repository
-	repoWt *git.Worktree
+	// MergesOnly only steps through the merge commits on the first-parent history of the upstream.
+	MergesOnly bool
+
+	// Filter selects which commits are stepped through, any others are folded into the next step.
+	Filter StepFilter
+
+	// Batch groups the commits which pass the filter into fewer steps.
+	Batch Batch
+
+	// plan describes each remaining delta, stored in the same order
+	plan []PlannedStep
+
+	repo *cache.Repo
 
 	isInit bool
 }
@@ -39,83 +67,146 @@ func NewGitIter(opts options.UpstreamOptions, delta UpstreamDelta) (*GitIter, er
 		return nil, fmt.Errorf("upstream must have an initial commit")
 	}
 
-	if delta.Subdirectory != nil {
-		return nil, fmt.Errorf("subdirectory is not supported for git iter")
-	}
-
 	iter := &GitIter{
 		UpstreamOptions: opts,
 	}
 
-	iter.fromCommit = plumbing.NewHash(*opts.Commit)
-	iter.toCommit = plumbing.NewHash(*delta.Commit)
+	if delta.Commit == nil {
+		return nil, fmt.Errorf("delta must have a target commit")
+	}
+
+	iter.fromRef = *opts.Commit
+	iter.toRef = *delta.Commit
+	iter.toSubdirectory = delta.Subdirectory
 
 	return iter, nil
 }
 
-func (i *GitIter) init() error {
-	// clone the repository
-	tempDir, err := os.MkdirTemp("", "rebase-commit-iter-*")
+func (i *GitIter) init() (err error) {
+	c, err := cache.OrDefault(i.Cache)
 	if err != nil {
-		return fmt.Errorf("failed to create temp dir: %w", err)
+		return fmt.Errorf("failed to get upstream cache: %w", err)
 	}
 
-	cloneOpts := git.CloneOptions{
-		URL: i.UpstreamOptions.URL,
+	if i.repo, err = c.Repo(context.Background(), i.UpstreamOptions.URL, nil); err != nil {
+		return fmt.Errorf("failed to get cached repository: %w", err)
 	}
 
-	if i.repo, err = git.PlainClone(tempDir, false, &cloneOpts); err != nil {
-		return fmt.Errorf("failed to clone repository: %w", err)
-	}
+	defer func() {
+		if err != nil {
+			i.Close()
+		}
+	}()
 
-	if i.repoWt, err = i.repo.Worktree(); err != nil {
-		return fmt.Errorf("failed to get worktree: %w", err)
+	if i.fromCommit, err = ResolveRef(i.repo.Repository, i.fromRef); err != nil {
+		return fmt.Errorf("failed to resolve current upstream commit: %w", err)
 	}
 
-	// get the commit iterator
-	checkoutOpts := git.CheckoutOptions{
-		Hash: i.fromCommit,
-	}
-	if err := i.repoWt.Checkout(&checkoutOpts); err != nil {
-		return fmt.Errorf("failed to checkout final commit: %w", err)
+	if i.toCommit, err = ResolveRef(i.repo.Repository, i.toRef); err != nil {
+		return fmt.Errorf("failed to resolve target upstream commit: %w", err)
 	}
 
-	fromCommit, err := i.repo.CommitObject(i.fromCommit)
+	path, err := FirstParentPath(i.repo.Repository, i.fromCommit, i.toCommit)
 	if err != nil {
-		return fmt.Errorf("failed to get from commit: %w", err)
+		return err
 	}
 
-	// increment "since" to avoid including the current commit
-	since := fromCommit.Committer.When.Add(1)
-	logOpts := git.LogOptions{
-		From:  i.toCommit,
-		Order: git.LogOrderDefault,
-		PathFilter: func(p string) bool {
-			if i.UpstreamOptions.Subdirectory == nil {
-				return true
+	current := normalizeSubdirectory(i.UpstreamOptions.Subdirectory)
+	target := normalizeSubdirectory(i.toSubdirectory)
+	tracksSubdirectory := i.UpstreamOptions.Subdirectory != nil || i.toSubdirectory != nil
+
+	// candidates are the commits changing the chart which pass the filters, or must be stepped to
+	candidates := make([]*object.Commit, 0, len(path))
+	subdirectories := make([]string, 0, len(path))
+	forced := make([]bool, 0, len(path))
+	times := make([]time.Time, 0, len(path))
+
+	// commits changing the chart which were filtered out, to be brought in by the next step
+	folded := make([][]CommitSummary, 0, len(path))
+	pending := make([]CommitSummary, 0)
+
+	// walk oldest to newest so we can follow the chart as it is moved around the upstream
+	for n := len(path) - 1; n >= 0; n-- {
+		c := path[n]
+
+		next, err := nextSubdirectory(c, current, target)
+		if err != nil {
+			return fmt.Errorf("failed to follow upstream subdirectory: %w", err)
+		}
+
+		// the target is always included so the rebase ends where it was asked to, as are any moves so the subdirectory is never stale
+		force := n == 0 || next != current
+		if n == 0 && target != "" {
+			next = target
+		}
+
+		if !force {
+			if changed, err := changesSubdirectory(c, current); err != nil {
+				return fmt.Errorf("failed to check commit '%s' for changes: %w", c.Hash, err)
+			} else if !changed {
+				continue
 			}
 
-			return strings.HasPrefix(p, *i.UpstreamOptions.Subdirectory)
-		},
-		Since: &since,
-	}
+			keep := !i.MergesOnly || c.NumParents() > 1
+			if keep {
+				if keep, err = i.Filter.Keep(c, current); err != nil {
+					return fmt.Errorf("failed to filter commit '%s': %w", c.Hash, err)
+				}
+			}
 
-	commitIter, err := i.repo.Log(&logOpts)
-	if err != nil {
-		return fmt.Errorf("failed to get commit iterator: %w", err)
+			if !keep {
+				pending = append(pending, summarizeCommit(c))
+				continue
+			}
+		}
+
+		current = next
+
+		candidates = append(candidates, c)
+		subdirectories = append(subdirectories, current)
+		forced = append(forced, force)
+		times = append(times, c.Committer.When)
+		folded = append(folded, pending)
+
+		pending = make([]CommitSummary, 0)
 	}
 
-	i.deltas = make([]UpstreamDelta, 0)
-	commitIter.ForEach(func(c *object.Commit) error {
+	selected := i.Batch.selectSteps(times, forced)
+
+	i.deltas = make([]UpstreamDelta, 0, len(candidates))
+	i.plan = make([]PlannedStep, 0, len(candidates))
+
+	for n, c := range candidates {
+		pending = append(pending, folded[n]...)
+
+		if !selected[n] {
+			pending = append(pending, summarizeCommit(c))
+			continue
+		}
+
 		delta := i.Delta
 
 		hash := c.Hash.String()
 		delta.Commit = &hash
 
+		if tracksSubdirectory {
+			subdirectory := subdirectories[n]
+			delta.Subdirectory = &subdirectory
+		}
+
 		i.deltas = append(i.deltas, delta)
+		i.plan = append(i.plan, PlannedStep{
+			CommitSummary: summarizeCommit(c),
+			Subdirectory:  delta.Subdirectory,
+			Folded:        pending,
+		})
 
-		return nil
-	})
+		pending = make([]CommitSummary, 0)
+	}
+
+	// deltas are stored in reverse order
+	slices.Reverse(i.deltas)
+	slices.Reverse(i.plan)
 
 	i.isInit = true
 
@@ -136,6 +227,7 @@ func (i *GitIter) Next() (puller.Puller, error) {
 	// deltas are stored in reverse order
 	delta := i.deltas[len(i.deltas)-1]
 	i.deltas = i.deltas[:len(i.deltas)-1]
+	i.plan = i.plan[:len(i.plan)-1]
 
 	newOpts, err := delta.Apply(i.UpstreamOptions)
 	if err != nil {
@@ -143,51 +235,276 @@ func (i *GitIter) Next() (puller.Puller, error) {
 	}
 
 	p := &CheckoutPuller{
-		Wt:   i.repoWt,
+		Repo: i.repo.Repository,
 		Opts: newOpts,
 	}
 
 	return p, nil
 }
 
-func shouldSkipCommit(srcinfo os.FileInfo, src, dest string) (bool, error) {
-	if filepath.Base(src) == ".git" {
+func (i *GitIter) Len() (int, error) {
+	if !i.isInit {
+		if err := i.init(); err != nil {
+			return 0, fmt.Errorf("failed to init git iter: %w", err)
+		}
+	}
+
+	return len(i.deltas), nil
+}
+
+// FirstParentPath returns the commits reached by following the first parent of each commit from to back to from, ordered newest first and excluding from itself. Returns ErrNotDescendant if to does not descend from from.
+func FirstParentPath(repo *git.Repository, from plumbing.Hash, to plumbing.Hash) ([]*object.Commit, error) {
+	fromCommit, err := repo.CommitObject(from)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get from commit: %w", err)
+	}
+
+	toCommit, err := repo.CommitObject(to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get to commit: %w", err)
+	}
+
+	if isAncestor, err := fromCommit.IsAncestor(toCommit); err != nil {
+		return nil, fmt.Errorf("failed to check commit ancestry: %w", err)
+	} else if !isAncestor {
+		return nil, fmt.Errorf("%w: '%s' is not a descendant of '%s'", ErrNotDescendant, to, from)
+	}
+
+	path, found, err := walkFirstParents(toCommit, from, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// from was merged in from a side branch rather than being on the first-parent history, so stop at the first commit which does not contain it
+	if !found {
+		path, _, err = walkFirstParents(toCommit, from, func(c *object.Commit) (bool, error) {
+			return fromCommit.IsAncestor(c)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return path, nil
+}
+
+// walkFirstParents follows the first parents of start until reaching stop, a root commit, or a commit for which include returns false. Returns whether stop was reached.
+func walkFirstParents(start *object.Commit, stop plumbing.Hash, include func(*object.Commit) (bool, error)) ([]*object.Commit, bool, error) {
+	path := make([]*object.Commit, 0)
+	c := start
+
+	for c.Hash != stop {
+		if include != nil && len(path) > 0 {
+			if ok, err := include(c); err != nil {
+				return nil, false, fmt.Errorf("failed to check commit ancestry: %w", err)
+			} else if !ok {
+				return path, false, nil
+			}
+		}
+
+		path = append(path, c)
+
+		if c.NumParents() == 0 {
+			return path, false, nil
+		}
+
+		parent, err := c.Parent(0)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to get parent of '%s': %w", c.Hash, err)
+		}
+
+		c = parent
+	}
+
+	return path, true, nil
+}
+
+// changesSubdirectory checks if the commit changes anything within subdirectory compared to its first parent.
+func changesSubdirectory(c *object.Commit, subdirectory string) (bool, error) {
+	if subdirectory == "" || c.NumParents() == 0 {
 		return true, nil
 	}
 
-	return false, nil
+	parent, err := c.Parent(0)
+	if err != nil {
+		return false, err
+	}
+
+	current, err := subtreeHash(c, subdirectory)
+	if err != nil {
+		return false, err
+	}
+
+	previous, err := subtreeHash(parent, subdirectory)
+	if err != nil {
+		return false, err
+	}
+
+	return current != previous, nil
+}
+
+func subtreeHash(c *object.Commit, subdirectory string) (plumbing.Hash, error) {
+	tree, err := c.Tree()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	entry, err := tree.FindEntry(strings.Trim(subdirectory, "/"))
+	if errors.Is(err, object.ErrEntryNotFound) || errors.Is(err, object.ErrDirectoryNotFound) {
+		return plumbing.ZeroHash, nil
+	} else if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return entry.Hash, nil
 }
 
+// Plan returns the remaining steps of the iterator in the order they will be returned by Next.
+func (i *GitIter) Plan() ([]PlannedStep, error) {
+	if !i.isInit {
+		if err := i.init(); err != nil {
+			return nil, fmt.Errorf("failed to init git iter: %w", err)
+		}
+	}
+
+	plan := slices.Clone(i.plan)
+	slices.Reverse(plan)
+
+	return plan, nil
+}
+
+// Close releases the cached clone of the upstream, any pullers returned by the iterator should not be used afterwards.
+func (i *GitIter) Close() error {
+	if i.repo == nil {
+		return nil
+	}
+
+	err := i.repo.Close()
+	i.repo = nil
+	i.isInit = false
+
+	return err
+}
+
+// CheckoutPuller pulls the files of a single upstream commit.
 type CheckoutPuller struct {
-	Wt   *git.Worktree
+	// Repo is the repository holding the commit, if nil the upstream is opened from Cache.
+	Repo *git.Repository
+
+	// Cache is used to open the upstream repository when Repo is nil, if nil the default cache is used.
+	Cache *cache.Cache
+
 	Opts options.UpstreamOptions
 }
 
-// Pull checks out the commit from upstream options and copies the files to the destination.
-//
-// Because this method mutatues the filesystem, it is not safe to call concurrently.
+// Pull copies the files from the commit in the upstream options to the destination.
 func (p *CheckoutPuller) Pull(rootFs billy.Filesystem, fs billy.Filesystem, path string) error {
-	checkoutOpts := git.CheckoutOptions{
-		Hash: plumbing.NewHash(*p.Opts.Commit),
+	if p.Opts.Commit == nil {
+		return fmt.Errorf("upstream must have a commit")
+	}
+
+	repo := p.Repo
+
+	if repo == nil {
+		c, err := cache.OrDefault(p.Cache)
+		if err != nil {
+			return fmt.Errorf("failed to get upstream cache: %w", err)
+		}
+
+		cached, err := c.Repo(context.Background(), p.Opts.URL, nil)
+		if err != nil {
+			return fmt.Errorf("failed to get cached repository: %w", err)
+		}
+		defer cached.Close()
+
+		repo = cached.Repository
+	}
+
+	hash, err := ResolveRef(repo, *p.Opts.Commit)
+	if err != nil {
+		return fmt.Errorf("failed to resolve commit: %w", err)
+	}
+
+	commit, err := repo.CommitObject(hash)
+	if err != nil {
+		return fmt.Errorf("failed to get commit '%s': %w", *p.Opts.Commit, err)
+	}
+
+	tree, err := commit.Tree()
+	if err != nil {
+		return fmt.Errorf("failed to get tree for commit: %w", err)
 	}
-	if err := p.Wt.Checkout(&checkoutOpts); err != nil {
-		return fmt.Errorf("failed to checkout commit: %w", err)
+
+	if p.Opts.Subdirectory != nil && strings.Trim(*p.Opts.Subdirectory, "/") != "" {
+		if tree, err = tree.Tree(strings.Trim(*p.Opts.Subdirectory, "/")); err != nil {
+			return fmt.Errorf("failed to find subdirectory '%s' in commit: %w", *p.Opts.Subdirectory, err)
+		}
 	}
 
-	src := p.Wt.Filesystem.Root()
-	if p.Opts.Subdirectory != nil {
-		src = filepath.Join(src, *p.Opts.Subdirectory)
+	if err := fs.MkdirAll(path, os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create destination: %w", err)
 	}
 
-	dst := filesystem.GetAbsPath(fs, path)
+	err = tree.Files().ForEach(func(f *object.File) error {
+		if err := writeTreeFile(fs, filepath.Join(path, f.Name), f); err != nil {
+			return fmt.Errorf("failed to write '%s': %w", f.Name, err)
+		}
 
-	if err := cp.Copy(src, dst, cp.Options{Skip: shouldSkipCommit}); err != nil {
+		return nil
+	})
+	if err != nil {
 		return fmt.Errorf("failed to copy files: %w", err)
 	}
 
 	return nil
 }
 
+func writeTreeFile(fs billy.Filesystem, path string, f *object.File) error {
+	if err := fs.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+
+	if f.Mode == filemode.Submodule {
+		return nil
+	}
+
+	if f.Mode == filemode.Symlink {
+		target, err := f.Contents()
+		if err != nil {
+			return err
+		}
+
+		if err := fs.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+
+		return fs.Symlink(target, path)
+	}
+
+	perm := os.FileMode(0644)
+	if f.Mode == filemode.Executable {
+		perm = 0755
+	}
+
+	r, err := f.Reader()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	out, err := fs.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, perm)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, r); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
+
 func (p *CheckoutPuller) GetOptions() options.UpstreamOptions {
 	return p.Opts
 }
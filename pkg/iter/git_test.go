@@ -1,11 +1,25 @@
 package iter_test
 
 import (
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/joshmeranda/chartsutil/pkg/cache"
+	"github.com/joshmeranda/chartsutil/pkg/display"
+	"github.com/joshmeranda/chartsutil/pkg/fixture"
 	"github.com/joshmeranda/chartsutil/pkg/iter"
 	"github.com/joshmeranda/chartsutil/pkg/rebase"
 	"github.com/rancher/charts-build-scripts/pkg/options"
+	"github.com/rancher/charts-build-scripts/pkg/puller"
 )
 
 func assertNextCommit(t *testing.T, iter *iter.GitIter, expected string) {
@@ -22,20 +36,605 @@ func assertNextCommit(t *testing.T, iter *iter.GitIter, expected string) {
 }
 
 func TestNewGitIter(t *testing.T) {
+	b := fixture.NewUpstream(t)
+
+	b.CommitFiles("A", fixture.Chart("", "example", "0.0.1"))
+	b.CommitContent("B", "values.yaml", "replicas: 2\n", "A")
+	b.Commit("C", "templates/configmap.yaml", "B")
+	b.CommitContent("D", "Chart.yaml", "apiVersion: v2\nname: example\nversion: 0.1.0\n", "C")
+	b.Branch("main", "D")
+
 	opts := options.UpstreamOptions{
-		URL:    "https://github.com/joshmeranda/chartsutil-example-upstream.git",
-		Commit: rebase.ToPtr("d71e29b3f50fbe2ff4d3c2dd95684739b4d00310"),
+		URL:    b.Dir,
+		Commit: rebase.ToPtr(b.Hash("A").String()),
 	}
 	delta := iter.UpstreamDelta{
-		Commit: rebase.ToPtr("933d8b2975efa50cda4dca6234e5e522b8f58cdc"),
+		Commit: rebase.ToPtr(b.Hash("D").String()),
 	}
 
 	iter, err := iter.NewGitIter(opts, delta)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
+	defer iter.Close()
+
+	iter.Cache = &cache.Cache{Root: t.TempDir()}
+
+	assertNextCommit(t, iter, b.Hash("B").String())
+	assertNextCommit(t, iter, b.Hash("C").String())
+	assertNextCommit(t, iter, b.Hash("D").String())
+}
+
+func TestCheckoutPuller(t *testing.T) {
+	upstreamDir := t.TempDir()
+
+	upstream, err := git.PlainInit(upstreamDir, false)
+	if err != nil {
+		t.Fatalf("failed to init upstream: %v", err)
+	}
+
+	wt, err := upstream.Worktree()
+	if err != nil {
+		t.Fatalf("failed to get worktree: %v", err)
+	}
+
+	files := map[string]string{
+		"README.md":                  "readme",
+		"charts/example/Chart.yaml":  "name: example",
+		"charts/example/values.yaml": "replicas: 1",
+	}
+
+	for name, content := range files {
+		path := filepath.Join(upstreamDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+
+	if err := wt.AddGlob("."); err != nil {
+		t.Fatalf("failed to add files: %v", err)
+	}
+
+	hash, err := wt.Commit("initial commit", &git.CommitOptions{
+		Author: &object.Signature{Name: "chartsutil-test", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+
+	p := &iter.CheckoutPuller{
+		Cache: &cache.Cache{Root: t.TempDir()},
+		Opts: options.UpstreamOptions{
+			URL:          upstreamDir,
+			Commit:       rebase.ToPtr(hash.String()),
+			Subdirectory: rebase.ToPtr("charts/example"),
+		},
+	}
+
+	dst := t.TempDir()
+	fs := osfs.New(dst)
+
+	if err := p.Pull(fs, fs, "charts"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for name, expected := range map[string]string{"Chart.yaml": "name: example", "values.yaml": "replicas: 1"} {
+		data, err := os.ReadFile(filepath.Join(dst, "charts", name))
+		if err != nil {
+			t.Fatalf("failed to read pulled file: %v", err)
+		}
+
+		if string(data) != expected {
+			t.Errorf("expected '%s' to contain '%s' but found '%s'", name, expected, data)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dst, "charts", "README.md")); !os.IsNotExist(err) {
+		t.Errorf("expected files outside of the subdirectory to not be pulled")
+	}
+}
+
+func TestGitIterFirstParent(t *testing.T) {
+	b := fixture.NewUpstream(t)
+
+	// main:    A - B - M - C
+	// feature:  \- F1 -/
+	b.Commit("A", "charts/example/values.yaml")
+	b.Commit("F1", "charts/example/values.yaml", "A")
+	b.Commit("B", "README.md", "A")
+	b.Commit("M", "charts/example/values.yaml", "B", "F1")
+	b.Commit("C", "charts/example/Chart.yaml", "M")
+	b.Commit("X", "charts/example/Chart.yaml", "F1")
+	b.Branch("main", "C")
+	b.Branch("feature", "X")
+
+	type testCase struct {
+		Name         string
+		From         string
+		To           string
+		Subdirectory *string
+		MergesOnly   bool
+		Expected     []string
+		Err          error
+	}
+
+	cases := []testCase{
+		{
+			Name:     "MainLine",
+			From:     "A",
+			To:       "C",
+			Expected: []string{"B", "M", "C"},
+		},
+		{
+			Name:     "FromSideBranch",
+			From:     "F1",
+			To:       "C",
+			Expected: []string{"M", "C"},
+		},
+		{
+			Name:       "MergesOnly",
+			From:       "A",
+			To:         "C",
+			MergesOnly: true,
+			Expected:   []string{"M", "C"},
+		},
+		{
+			Name:         "Subdirectory",
+			From:         "A",
+			To:           "C",
+			Subdirectory: rebase.ToPtr("charts/example"),
+			Expected:     []string{"M", "C"},
+		},
+		{
+			Name: "NotDescendant",
+			From: "B",
+			To:   "X",
+			Err:  iter.ErrNotDescendant,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			opts := options.UpstreamOptions{
+				URL:          b.Dir,
+				Commit:       rebase.ToPtr(b.Hash(c.From).String()),
+				Subdirectory: c.Subdirectory,
+			}
+			delta := iter.UpstreamDelta{
+				Commit: rebase.ToPtr(b.Hash(c.To).String()),
+			}
+
+			i, err := iter.NewGitIter(opts, delta)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer i.Close()
+
+			i.Cache = &cache.Cache{Root: t.TempDir()}
+			i.MergesOnly = c.MergesOnly
+
+			found := make([]string, 0)
+			err = iter.ForEach(i, func(p puller.Puller) error {
+				if name, ok := b.Name(*p.GetOptions().Commit); ok {
+					found = append(found, name)
+				}
+
+				return nil
+			})
+
+			if c.Err != nil {
+				if !errors.Is(err, c.Err) {
+					t.Fatalf("expected error '%v' but found '%v'", c.Err, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !slices.Equal(found, c.Expected) {
+				t.Errorf("expected steps %v but found %v", c.Expected, found)
+			}
+		})
+	}
+}
+
+func TestGitIterSubdirectoryMove(t *testing.T) {
+	b := fixture.NewUpstream(t)
+
+	b.Commit("A", "charts/foo/Chart.yaml")
+	b.Commit("A2", "charts/foo/values.yaml", "A")
+	b.Commit("A3", "charts/foo/templates/deployment.yaml", "A2")
+	b.Commit("B", "charts/foo/values.yaml", "A3")
+	b.Move("C", "charts/foo", "charts/foo-v2", "B")
+	b.Commit("D", "charts/foo-v2/values.yaml", "C")
+	b.Move("E", "charts/foo-v2", "", "D")
+	b.Branch("main", "D")
+	b.Branch("removed", "E")
+
+	type testCase struct {
+		Name         string
+		To           string
+		Subdirectory *string
+		Expected     []string
+		Err          error
+	}
+
+	cases := []testCase{
+		{
+			Name:     "DetectedMove",
+			To:       "D",
+			Expected: []string{"B:charts/foo", "C:charts/foo-v2", "D:charts/foo-v2"},
+		},
+		{
+			Name:         "ExplicitSubdirectory",
+			To:           "D",
+			Subdirectory: rebase.ToPtr("charts/foo-v2"),
+			Expected:     []string{"B:charts/foo", "C:charts/foo-v2", "D:charts/foo-v2"},
+		},
+		{
+			Name:         "ExplicitSubdirectoryWithoutMove",
+			To:           "B",
+			Subdirectory: rebase.ToPtr("charts/foo/templates"),
+			Expected:     []string{"B:charts/foo/templates"},
+		},
+		{
+			Name: "Removed",
+			To:   "E",
+			Err:  iter.ErrSubdirectoryRemoved,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			opts := options.UpstreamOptions{
+				URL:          b.Dir,
+				Commit:       rebase.ToPtr(b.Hash("A3").String()),
+				Subdirectory: rebase.ToPtr("charts/foo"),
+			}
+			delta := iter.UpstreamDelta{
+				Commit:       rebase.ToPtr(b.Hash(c.To).String()),
+				Subdirectory: c.Subdirectory,
+			}
+
+			i, err := iter.NewGitIter(opts, delta)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer i.Close()
+
+			i.Cache = &cache.Cache{Root: t.TempDir()}
+
+			found := make([]string, 0)
+			err = iter.ForEach(i, func(p puller.Puller) error {
+				if name, ok := b.Name(*p.GetOptions().Commit); ok {
+					found = append(found, name+":"+*p.GetOptions().Subdirectory)
+				}
+
+				return nil
+			})
+
+			if c.Err != nil {
+				if !errors.Is(err, c.Err) {
+					t.Fatalf("expected error '%v' but found '%v'", c.Err, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !slices.Equal(found, c.Expected) {
+				t.Errorf("expected steps %v but found %v", c.Expected, found)
+			}
+		})
+	}
+}
+
+func TestGitIterFilter(t *testing.T) {
+	b := fixture.NewUpstream(t)
+
+	b.CommitContent("A", "charts/example/Chart.yaml", "version: 1.0.0")
+	b.Commit("docs: fix typo", "charts/example/README.md", "A")
+	b.Commit("feat: add values", "charts/example/values.yaml", "docs: fix typo")
+	b.CommitContent("release 1.1.0", "charts/example/Chart.yaml", "version: 1.1.0", "feat: add values")
+	b.Commit("ci: update workflow", "charts/example/ci/test.yaml", "release 1.1.0")
+	b.Commit("feat: add template", "charts/example/templates/deployment.yaml", "ci: update workflow")
+	b.Branch("main", "feat: add template")
+
+	type testCase struct {
+		Name     string
+		Filter   iter.StepFilter
+		Expected []string
+	}
+
+	cases := []testCase{
+		{
+			Name:     "NoFilter",
+			Expected: []string{"docs: fix typo", "feat: add values", "release 1.1.0", "ci: update workflow", "feat: add template"},
+		},
+		{
+			Name: "IncludeMessage",
+			Filter: iter.StepFilter{
+				IncludeMessage: regexp.MustCompile("^feat:"),
+			},
+			Expected: []string{"feat: add values", "feat: add template"},
+		},
+		{
+			Name: "ExcludeMessage",
+			Filter: iter.StepFilter{
+				ExcludeMessage: regexp.MustCompile("^(docs|ci):"),
+			},
+			Expected: []string{"feat: add values", "release 1.1.0", "feat: add template"},
+		},
+		{
+			Name: "ExcludeAuthor",
+			Filter: iter.StepFilter{
+				ExcludeAuthor: regexp.MustCompile("chartsutil-test"),
+			},
+			Expected: []string{"feat: add template"},
+		},
+		{
+			Name: "Paths",
+			Filter: iter.StepFilter{
+				IncludePaths: []string{"*.yaml", "templates/**"},
+				ExcludePaths: []string{"ci/**"},
+			},
+			Expected: []string{"feat: add values", "release 1.1.0", "feat: add template"},
+		},
+		{
+			Name: "ChartVersionOnly",
+			Filter: iter.StepFilter{
+				ChartVersionOnly: true,
+			},
+			Expected: []string{"release 1.1.0", "feat: add template"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			opts := options.UpstreamOptions{
+				URL:          b.Dir,
+				Commit:       rebase.ToPtr(b.Hash("A").String()),
+				Subdirectory: rebase.ToPtr("charts/example"),
+			}
+			delta := iter.UpstreamDelta{
+				Commit: rebase.ToPtr(b.Hash("feat: add template").String()),
+			}
+
+			i, err := iter.NewGitIter(opts, delta)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer i.Close()
+
+			i.Cache = &cache.Cache{Root: t.TempDir()}
+			i.Filter = c.Filter
+
+			plan, err := i.Plan()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			// every commit should either be a step or folded into one
+			steps := make([]string, 0)
+			all := make([]string, 0)
+			for _, step := range plan {
+				for _, folded := range step.Folded {
+					all = append(all, folded.Summary)
+				}
+
+				steps = append(steps, step.Summary)
+				all = append(all, step.Summary)
+			}
+
+			if !slices.Equal(steps, c.Expected) {
+				t.Errorf("expected steps %v but found %v", c.Expected, steps)
+			}
+
+			if !slices.Equal(all, cases[0].Expected) {
+				t.Errorf("expected all commits to be planned in order but found %v", all)
+			}
+		})
+	}
+}
+
+func TestGitIterLog(t *testing.T) {
+	b := fixture.NewUpstream(t)
+
+	b.CommitContent("A", "charts/example/values.yaml", "replicas: 1\n")
+	b.Commit("docs: update readme", "README.md", "A")
+	b.CommitContent("feat: more replicas", "charts/example/values.yaml", "replicas: 2\n", "docs: update readme")
+	b.Commit("ci: update workflow", "charts/example/ci/test.yaml", "feat: more replicas")
+	b.Branch("main", "ci: update workflow")
+
+	opts := options.UpstreamOptions{
+		URL:          b.Dir,
+		Commit:       rebase.ToPtr(b.Hash("A").String()),
+		Subdirectory: rebase.ToPtr("charts/example"),
+	}
+	delta := iter.UpstreamDelta{
+		Commit: rebase.ToPtr(b.Hash("ci: update workflow").String()),
+	}
+
+	i, err := iter.NewGitIter(opts, delta)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer i.Close()
+
+	i.Cache = &cache.Cache{Root: t.TempDir()}
+	i.Filter = iter.StepFilter{
+		ExcludeMessage: regexp.MustCompile("^feat:"),
+	}
+
+	log, err := i.Log(iter.DefaultLogDiffFiles...)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
 
-	assertNextCommit(t, iter, "be3f43b07c7c3f034f6aada9af90a812a0b44aa8")
-	assertNextCommit(t, iter, "553ab27381dbc13c63c92ffb35f5c7634b52dd26")
-	assertNextCommit(t, iter, "933d8b2975efa50cda4dca6234e5e522b8f58cdc")
+	// the readme commit does not touch the chart and should not be listed
+	expectedCommits := []iter.LogEntry{
+		{
+			CommitSummary: iter.CommitSummary{Summary: "feat: more replicas"},
+			Subdirectory:  "charts/example",
+			Files:         []iter.FileStat{{Name: "values.yaml", Additions: 1, Deletions: 1}},
+			Step:          false,
+		},
+		{
+			CommitSummary: iter.CommitSummary{Summary: "ci: update workflow"},
+			Subdirectory:  "charts/example",
+			Files:         []iter.FileStat{{Name: "ci/test.yaml", Additions: 1}},
+			Step:          true,
+		},
+	}
+
+	if len(log.Commits) != len(expectedCommits) {
+		t.Fatalf("expected %d commits but found %d", len(expectedCommits), len(log.Commits))
+	}
+
+	for n, expected := range expectedCommits {
+		actual := log.Commits[n]
+
+		if actual.Summary != expected.Summary || actual.Subdirectory != expected.Subdirectory || actual.Step != expected.Step {
+			t.Errorf("expected commit %d to be %+v but found %+v", n, expected, actual)
+		}
+
+		if !slices.Equal(actual.Files, expected.Files) {
+			t.Errorf("expected commit %d files %v but found %v", n, expected.Files, actual.Files)
+		}
+	}
+
+	expectedFiles := []iter.FileStat{
+		{Name: "ci/test.yaml", Additions: 1},
+		{Name: "values.yaml", Additions: 1, Deletions: 1},
+	}
+
+	files := slices.Clone(log.Files)
+	slices.SortFunc(files, func(a, b iter.FileStat) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	if !slices.Equal(files, expectedFiles) {
+		t.Errorf("expected files %v but found %v", expectedFiles, files)
+	}
+
+	if _, found := log.Diffs["Chart.yaml"]; found {
+		t.Errorf("expected no diff for unchanged Chart.yaml")
+	}
+
+	if diff := log.Diffs["values.yaml"]; !strings.Contains(diff, "-replicas: 1") || !strings.Contains(diff, "+replicas: 2") {
+		t.Errorf("expected values.yaml diff to change replicas but found:\n%s", diff)
+	}
+}
+
+func TestGitIterBatch(t *testing.T) {
+	b := fixture.NewUpstream(t)
+
+	// one commit a day starting on Thursday 1970-01-01, weeks start on Monday 1970-01-05
+	b.Interval = time.Hour * 24
+
+	b.Commit("A", "charts/example/Chart.yaml")
+	b.Commit("B", "charts/example/b.yaml", "A")
+	b.Commit("C", "charts/example/c.yaml", "B")
+	b.Commit("D", "charts/example/d.yaml", "C")
+	b.Commit("E", "charts/example/e.yaml", "D")
+	b.Commit("F", "charts/example/f.yaml", "E")
+	b.Commit("G", "charts/example/g.yaml", "F")
+	b.Commit("H", "charts/example/h.yaml", "G")
+	b.Commit("I", "charts/example/i.yaml", "H")
+	b.Commit("J", "charts/example/j.yaml", "I")
+	b.Commit("K", "charts/example/k.yaml", "J")
+	b.Commit("L", "charts/example/l.yaml", "K")
+	b.Commit("M", "charts/example/m.yaml", "L")
+	b.Branch("main", "M")
+
+	type testCase struct {
+		Name     string
+		Batch    iter.Batch
+		Filter   iter.StepFilter
+		Expected []string
+	}
+
+	cases := []testCase{
+		{
+			Name:     "NoBatch",
+			Expected: []string{"B", "C", "D", "E", "F", "G", "H", "I", "J", "K", "L", "M"},
+		},
+		{
+			Name:     "Step",
+			Batch:    iter.Batch{Step: 5},
+			Expected: []string{"F", "K", "M"},
+		},
+		{
+			Name:  "StepFiltered",
+			Batch: iter.Batch{Step: 2},
+			Filter: iter.StepFilter{
+				ExcludeMessage: regexp.MustCompile("^[BCD]$"),
+			},
+			Expected: []string{"F", "H", "J", "L", "M"},
+		},
+		{
+			Name:     "Window",
+			Batch:    iter.Batch{Window: display.Duration{Weeks: 1}},
+			Expected: []string{"C", "J", "M"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			opts := options.UpstreamOptions{
+				URL:          b.Dir,
+				Commit:       rebase.ToPtr(b.Hash("A").String()),
+				Subdirectory: rebase.ToPtr("charts/example"),
+			}
+			delta := iter.UpstreamDelta{
+				Commit: rebase.ToPtr(b.Hash("M").String()),
+			}
+
+			i, err := iter.IterForUpstream(&iter.CheckoutPuller{Opts: opts}, delta, iter.IterOptions{
+				Cache:  &cache.Cache{Root: t.TempDir()},
+				Filter: c.Filter,
+				Batch:  c.Batch,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			gitIter := i.(*iter.GitIter)
+			defer gitIter.Close()
+
+			plan, err := gitIter.Plan()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			steps := make([]string, 0)
+			all := make([]string, 0)
+			for _, step := range plan {
+				for _, folded := range step.Folded {
+					all = append(all, folded.Summary)
+				}
+
+				steps = append(steps, step.Summary)
+				all = append(all, step.Summary)
+			}
+
+			if !slices.Equal(steps, c.Expected) {
+				t.Errorf("expected steps %v but found %v", c.Expected, steps)
+			}
+
+			if !slices.Equal(all, cases[0].Expected) {
+				t.Errorf("expected all commits to be planned in order but found %v", all)
+			}
+		})
+	}
 }
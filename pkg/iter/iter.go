@@ -6,7 +6,8 @@ import (
 	"io"
 	"strings"
 
-	"github.com/rancher/charts-build-scripts/pkg/charts"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/joshmeranda/chartsutil/pkg/cache"
 	"github.com/rancher/charts-build-scripts/pkg/options"
 	"github.com/rancher/charts-build-scripts/pkg/puller"
 )
@@ -20,7 +21,7 @@ func (d *UpstreamDelta) Apply(opts options.UpstreamOptions) (options.UpstreamOpt
 	}
 
 	if d.Commit != nil {
-		if !strings.HasSuffix(opts.URL, ".git") {
+		if !IsGitUpstream(opts.URL) {
 			return opts, fmt.Errorf("cannot apply delta with commit to non-git upstream")
 		}
 
@@ -58,6 +59,49 @@ type UpstreamIter interface {
 	Next() (puller.Puller, error)
 }
 
+// SizedIter is an UpstreamIter which knows how many upstreams it has left.
+type SizedIter interface {
+	UpstreamIter
+
+	// Len returns the number of upstreams remaining in the iterator.
+	Len() (int, error)
+}
+
+// Planner is an UpstreamIter which knows which upstreams it will return ahead of time.
+type Planner interface {
+	UpstreamIter
+
+	// Plan returns the remaining steps in the iterator.
+	Plan() ([]PlannedStep, error)
+}
+
+// CommitSummary identifies an upstream commit.
+type CommitSummary struct {
+	Hash    string `json:"hash"`
+	Summary string `json:"summary"`
+	Author  string `json:"author"`
+}
+
+func summarizeCommit(c *object.Commit) CommitSummary {
+	summary, _, _ := strings.Cut(strings.TrimSpace(c.Message), "\n")
+
+	return CommitSummary{
+		Hash:    c.Hash.String(),
+		Summary: summary,
+		Author:  c.Author.Name,
+	}
+}
+
+// PlannedStep is an upstream commit an iterator will step to.
+type PlannedStep struct {
+	CommitSummary
+
+	Subdirectory *string
+
+	// Folded are the commits changing the chart which were filtered out and are brought in by this step.
+	Folded []CommitSummary
+}
+
 type SingleIter struct {
 	Upstream puller.Puller
 }
@@ -73,17 +117,60 @@ func (i *SingleIter) Next() (puller.Puller, error) {
 	return p, nil
 }
 
-func IterForUpstream(upstream puller.Puller, delta UpstreamDelta) (UpstreamIter, error) {
-	if delta.Subdirectory != nil {
-		return nil, errors.New("incremental rebases do not support subdirectory changes")
+func (i *SingleIter) Len() (int, error) {
+	if i.Upstream == nil {
+		return 0, nil
+	}
+
+	return 1, nil
+}
+
+// IterOptions configures the iterators created by IterForUpstream.
+type IterOptions struct {
+	// Cache holds clones of git upstreams, if nil the cache of the upstream or the default cache is used.
+	Cache *cache.Cache
+
+	// MergesOnly only steps through the merge commits on the first-parent history of git upstreams.
+	MergesOnly bool
+
+	// Filter selects which commits of git upstreams are stepped through.
+	Filter StepFilter
+
+	// Batch groups the commits of git upstreams into fewer steps.
+	Batch Batch
+}
+
+func IterForUpstream(upstream puller.Puller, delta UpstreamDelta, opts IterOptions) (UpstreamIter, error) {
+	var i *GitIter
+	var err error
+
+	// the delta may point a git upstream at a local directory or archive
+	if delta.URL != "" && !IsGitUpstream(delta.URL) {
+		return NewSingleIter(upstream, delta)
 	}
 
 	switch u := upstream.(type) {
 	case puller.GithubRepository:
-		return NewGitIter(u.GetOptions(), delta)
+		i, err = NewGitIter(u.GetOptions(), delta)
+	case *CheckoutPuller:
+		i, err = NewGitIter(u.GetOptions(), delta)
+		if opts.Cache == nil {
+			opts.Cache = u.Cache
+		}
 	default:
 		return NewSingleIter(upstream, delta)
 	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	i.Cache = opts.Cache
+	i.MergesOnly = opts.MergesOnly
+	i.Filter = opts.Filter
+	i.Batch = opts.Batch
+
+	return i, nil
 }
 
 func NewSingleIter(upstream puller.Puller, delta UpstreamDelta) (UpstreamIter, error) {
@@ -92,7 +179,7 @@ func NewSingleIter(upstream puller.Puller, delta UpstreamDelta) (UpstreamIter, e
 		return nil, fmt.Errorf("failed to apply upstream delta: %w", err)
 	}
 
-	p, err := charts.GetUpstream(opts)
+	p, err := GetUpstream(opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get upstream: %w", err)
 	}
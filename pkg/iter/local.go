@@ -0,0 +1,155 @@
+package iter
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-git/v5"
+	"github.com/rancher/charts-build-scripts/pkg/charts"
+	"github.com/rancher/charts-build-scripts/pkg/options"
+	chartspath "github.com/rancher/charts-build-scripts/pkg/path"
+	"github.com/rancher/charts-build-scripts/pkg/puller"
+)
+
+const fileScheme = "file://"
+
+// IsLocalUpstream returns true if the url is a file:// url or an absolute path to a local directory or git repository.
+func IsLocalUpstream(url string) bool {
+	return strings.HasPrefix(url, fileScheme) || filepath.IsAbs(url)
+}
+
+// LocalPath returns the path on disk of a local upstream url.
+func LocalPath(url string) string {
+	return strings.TrimPrefix(url, fileScheme)
+}
+
+// IsGitUpstream returns true if the url points to a git repository, either a url ending in '.git' or a local git repository.
+func IsGitUpstream(url string) bool {
+	if strings.HasSuffix(url, ".git") {
+		return true
+	}
+
+	if !IsLocalUpstream(url) {
+		return false
+	}
+
+	_, err := git.PlainOpen(LocalPath(url))
+	return err == nil
+}
+
+// GetUpstream returns the puller for the upstream options. Unlike charts.GetUpstream, local directories are supported and git upstreams keep their url rather than being rewritten to GitHub.
+func GetUpstream(opts options.UpstreamOptions) (puller.Puller, error) {
+	switch {
+	case IsGitUpstream(opts.URL):
+		return &CheckoutPuller{Opts: opts}, nil
+	case IsLocalUpstream(opts.URL):
+		if info, err := os.Stat(LocalPath(opts.URL)); err != nil {
+			return nil, fmt.Errorf("failed to find local upstream: %w", err)
+		} else if !info.IsDir() {
+			return nil, fmt.Errorf("local upstream '%s' is not a directory", opts.URL)
+		}
+
+		return &LocalPuller{Opts: opts}, nil
+	default:
+		return charts.GetUpstream(opts)
+	}
+}
+
+// PackageUpstream returns the upstream of the main chart of the package as written in its package.yaml.
+func PackageUpstream(pkgFs billy.Filesystem) (puller.Puller, error) {
+	pkgOpts, err := options.LoadPackageOptionsFromFile(pkgFs, chartspath.PackageOptionsFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load package options: %w", err)
+	}
+
+	return GetUpstream(pkgOpts.MainChartOptions.UpstreamOptions)
+}
+
+// LocalPuller pulls an upstream from a local directory which is not a git repository.
+type LocalPuller struct {
+	Opts options.UpstreamOptions
+}
+
+// Pull copies the files in the local directory (or its subdirectory) to the destination, ignoring any '.git' directories.
+func (p *LocalPuller) Pull(rootFs billy.Filesystem, fs billy.Filesystem, path string) error {
+	src := LocalPath(p.Opts.URL)
+	if p.Opts.Subdirectory != nil {
+		src = filepath.Join(src, *p.Opts.Subdirectory)
+	}
+
+	if info, err := os.Stat(src); err != nil {
+		return fmt.Errorf("failed to find upstream: %w", err)
+	} else if !info.IsDir() {
+		return fmt.Errorf("upstream '%s' is not a directory", src)
+	}
+
+	if err := fs.MkdirAll(path, os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create destination: %w", err)
+	}
+
+	err := filepath.WalkDir(src, func(name string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+
+		rel, err := filepath.Rel(src, name)
+		if err != nil {
+			return err
+		}
+
+		if err := copyLocalFile(fs, name, filepath.Join(path, rel), d); err != nil {
+			return fmt.Errorf("failed to copy '%s': %w", rel, err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to copy files: %w", err)
+	}
+
+	return nil
+}
+
+func copyLocalFile(fs billy.Filesystem, src string, dst string, d os.DirEntry) error {
+	switch {
+	case d.IsDir():
+		return fs.MkdirAll(dst, os.ModePerm)
+	case d.Type()&os.ModeSymlink != 0:
+		target, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+
+		if err := fs.Remove(dst); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+
+		return fs.Symlink(target, dst)
+	case d.Type().IsRegular():
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		return copyIntoFs(fs, src, dst, info.Mode().Perm())
+	default:
+		// sockets, devices, etc. have no place in a chart
+		return nil
+	}
+}
+
+func (p *LocalPuller) GetOptions() options.UpstreamOptions {
+	return p.Opts
+}
+
+func (p *LocalPuller) IsWithinPackage() bool {
+	return false
+}
@@ -0,0 +1,212 @@
+package iter_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/joshmeranda/chartsutil/pkg/cache"
+	"github.com/joshmeranda/chartsutil/pkg/fixture"
+	"github.com/joshmeranda/chartsutil/pkg/iter"
+	"github.com/joshmeranda/chartsutil/pkg/rebase"
+	"github.com/rancher/charts-build-scripts/pkg/options"
+	"github.com/rancher/charts-build-scripts/pkg/puller"
+)
+
+func TestGetUpstream(t *testing.T) {
+	b := fixture.NewUpstream(t)
+	b.Commit("A", "charts/example/Chart.yaml")
+
+	plainDir := t.TempDir()
+
+	type testCase struct {
+		Name     string
+		URL      string
+		Expected puller.Puller
+	}
+
+	cases := []testCase{
+		{
+			Name:     "LocalGitRepository",
+			URL:      b.Dir,
+			Expected: &iter.CheckoutPuller{},
+		},
+		{
+			Name:     "FileUrl",
+			URL:      "file://" + b.Dir,
+			Expected: &iter.CheckoutPuller{},
+		},
+		{
+			Name:     "LocalDirectory",
+			URL:      plainDir,
+			Expected: &iter.LocalPuller{},
+		},
+		{
+			Name:     "RemoteGit",
+			URL:      "git@example.com:owner/charts.git",
+			Expected: &iter.CheckoutPuller{},
+		},
+		{
+			Name:     "Archive",
+			URL:      "https://example.com/charts/example-1.0.0.tgz",
+			Expected: puller.Archive{},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			p, err := iter.GetUpstream(options.UpstreamOptions{URL: c.URL})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			switch c.Expected.(type) {
+			case *iter.CheckoutPuller:
+				if _, ok := p.(*iter.CheckoutPuller); !ok {
+					t.Fatalf("expected a checkout puller but found %T", p)
+				}
+			case *iter.LocalPuller:
+				if _, ok := p.(*iter.LocalPuller); !ok {
+					t.Fatalf("expected a local puller but found %T", p)
+				}
+			case puller.Archive:
+				if _, ok := p.(puller.Archive); !ok {
+					t.Fatalf("expected an archive but found %T", p)
+				}
+			}
+
+			// the url should never be rewritten
+			if url := p.GetOptions().URL; url != c.URL {
+				t.Errorf("expected url '%s' but found '%s'", c.URL, url)
+			}
+		})
+	}
+
+	if _, err := iter.GetUpstream(options.UpstreamOptions{URL: filepath.Join(plainDir, "missing")}); err == nil {
+		t.Errorf("expected error for missing local upstream")
+	}
+}
+
+func TestLocalPuller(t *testing.T) {
+	src := t.TempDir()
+
+	files := map[string]string{
+		"README.md":                            "readme",
+		"charts/example/Chart.yaml":            "name: example",
+		"charts/example/templates/deploy.yaml": "kind: Deployment",
+		"charts/example/.git/HEAD":             "ref: refs/heads/main",
+	}
+
+	for name, content := range files {
+		path := filepath.Join(src, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+
+	if err := os.Symlink("Chart.yaml", filepath.Join(src, "charts/example/Chart.yml")); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+
+	p := &iter.LocalPuller{
+		Opts: options.UpstreamOptions{
+			URL:          "file://" + src,
+			Subdirectory: rebase.ToPtr("charts/example"),
+		},
+	}
+
+	dst := t.TempDir()
+	fs := osfs.New(dst)
+
+	if err := p.Pull(fs, fs, "charts"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for name, expected := range map[string]string{"Chart.yaml": "name: example", "Chart.yml": "name: example", "templates/deploy.yaml": "kind: Deployment"} {
+		data, err := os.ReadFile(filepath.Join(dst, "charts", name))
+		if err != nil {
+			t.Fatalf("failed to read pulled file: %v", err)
+		}
+
+		if string(data) != expected {
+			t.Errorf("expected '%s' to contain '%s' but found '%s'", name, expected, data)
+		}
+	}
+
+	for _, name := range []string{"README.md", ".git"} {
+		if _, err := os.Stat(filepath.Join(dst, "charts", name)); !os.IsNotExist(err) {
+			t.Errorf("expected '%s' to not be pulled", name)
+		}
+	}
+}
+
+func TestIterForLocalUpstream(t *testing.T) {
+	b := fixture.NewUpstream(t)
+
+	b.Commit("A", "charts/example/Chart.yaml")
+	b.Commit("B", "charts/example/values.yaml", "A")
+	b.Commit("C", "charts/example/templates/deploy.yaml", "B")
+	b.Branch("main", "C")
+
+	upstream := &iter.CheckoutPuller{
+		Cache: &cache.Cache{Root: t.TempDir()},
+		Opts: options.UpstreamOptions{
+			URL:          "file://" + b.Dir,
+			Commit:       rebase.ToPtr(b.Hash("A").String()),
+			Subdirectory: rebase.ToPtr("charts/example"),
+		},
+	}
+
+	t.Run("Git", func(t *testing.T) {
+		delta := iter.UpstreamDelta{
+			Commit: rebase.ToPtr(b.Hash("C").String()),
+		}
+
+		i, err := iter.IterForUpstream(upstream, delta, iter.IterOptions{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		gitIter, ok := i.(*iter.GitIter)
+		if !ok {
+			t.Fatalf("expected a git iterator but found %T", i)
+		}
+		defer gitIter.Close()
+
+		assertNextCommit(t, gitIter, b.Hash("B").String())
+		assertNextCommit(t, gitIter, b.Hash("C").String())
+	})
+
+	t.Run("Directory", func(t *testing.T) {
+		dir := t.TempDir()
+
+		delta := iter.UpstreamDelta{
+			URL:          dir,
+			Subdirectory: rebase.ToPtr(""),
+		}
+
+		i, err := iter.IterForUpstream(upstream, delta, iter.IterOptions{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		p, err := i.Next()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if _, ok := p.(*iter.LocalPuller); !ok {
+			t.Fatalf("expected a local puller but found %T", p)
+		}
+
+		if _, err := i.Next(); err != io.EOF {
+			t.Errorf("expected a single upstream but found more")
+		}
+	})
+}
@@ -0,0 +1,211 @@
+package iter
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/diff"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// DefaultLogDiffFiles are the files whose full diff is most useful when reviewing upstream changes.
+var DefaultLogDiffFiles = []string{"Chart.yaml", "values.yaml"}
+
+// FileStat is the number of lines changed in a file, relative to the upstream subdirectory.
+type FileStat struct {
+	Name      string `json:"name"`
+	Additions int    `json:"additions"`
+	Deletions int    `json:"deletions"`
+}
+
+// LogEntry is a single upstream commit which changed the chart.
+type LogEntry struct {
+	CommitSummary
+
+	Date         time.Time  `json:"date"`
+	Subdirectory string     `json:"subdirectory,omitempty"`
+	Files        []FileStat `json:"files"`
+
+	// Step is true when the commit will be its own step of an incremental rebase, and false if it will be folded into a later step.
+	Step bool `json:"step"`
+}
+
+// UpstreamLog describes the upstream changes to the chart between two commits.
+type UpstreamLog struct {
+	URL     string     `json:"url"`
+	From    string     `json:"from"`
+	To      string     `json:"to"`
+	Commits []LogEntry `json:"commits"`
+
+	// Files are the aggregated changes between the two commits.
+	Files []FileStat `json:"files"`
+
+	// Diffs are the unified diffs of the requested files between the two commits, keyed by file name.
+	Diffs map[string]string `json:"diffs,omitempty"`
+}
+
+// Log describes the remaining upstream commits which change the chart, with full diffs of the given files (relative to the upstream subdirectory).
+func (i *GitIter) Log(diffFiles ...string) (*UpstreamLog, error) {
+	plan, err := i.Plan()
+	if err != nil {
+		return nil, err
+	}
+
+	log := &UpstreamLog{
+		URL:     i.UpstreamOptions.URL,
+		From:    i.fromCommit.String(),
+		To:      i.toCommit.String(),
+		Commits: make([]LogEntry, 0),
+		Diffs:   make(map[string]string),
+	}
+
+	current := normalizeSubdirectory(i.UpstreamOptions.Subdirectory)
+
+	for _, step := range plan {
+		// folded commits come before the step, and so before any move it makes
+		for _, folded := range step.Folded {
+			entry, err := i.logEntry(folded, current, current, false)
+			if err != nil {
+				return nil, err
+			}
+
+			log.Commits = append(log.Commits, entry)
+		}
+
+		previous := current
+		if step.Subdirectory != nil {
+			current = normalizeSubdirectory(step.Subdirectory)
+		}
+
+		entry, err := i.logEntry(step.CommitSummary, previous, current, true)
+		if err != nil {
+			return nil, err
+		}
+
+		log.Commits = append(log.Commits, entry)
+	}
+
+	from, err := i.repo.CommitObject(i.fromCommit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get from commit: %w", err)
+	}
+
+	to, err := i.repo.CommitObject(i.toCommit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get to commit: %w", err)
+	}
+
+	patch, err := subdirectoryPatch(from, normalizeSubdirectory(i.UpstreamOptions.Subdirectory), to, current)
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff upstream commits: %w", err)
+	}
+
+	log.Files = fileStats(patch)
+
+	for _, file := range diffFiles {
+		if d, err := encodeFileDiff(patch, file); err != nil {
+			return nil, fmt.Errorf("failed to diff '%s': %w", file, err)
+		} else if d != "" {
+			log.Diffs[file] = d
+		}
+	}
+
+	return log, nil
+}
+
+// logEntry describes the changes made by a commit to the chart, which was in parentSubdirectory before the commit and subdirectory after.
+func (i *GitIter) logEntry(summary CommitSummary, parentSubdirectory string, subdirectory string, step bool) (LogEntry, error) {
+	c, err := i.repo.CommitObject(plumbing.NewHash(summary.Hash))
+	if err != nil {
+		return LogEntry{}, fmt.Errorf("failed to get commit '%s': %w", summary.Hash, err)
+	}
+
+	var parent *object.Commit
+
+	if c.NumParents() > 0 {
+		if parent, err = c.Parent(0); err != nil {
+			return LogEntry{}, fmt.Errorf("failed to get parent of '%s': %w", summary.Hash, err)
+		}
+	}
+
+	patch, err := subdirectoryPatch(parent, parentSubdirectory, c, subdirectory)
+	if err != nil {
+		return LogEntry{}, fmt.Errorf("failed to diff commit '%s': %w", summary.Hash, err)
+	}
+
+	return LogEntry{
+		CommitSummary: summary,
+		Date:          c.Committer.When,
+		Subdirectory:  subdirectory,
+		Files:         fileStats(patch),
+		Step:          step,
+	}, nil
+}
+
+// subdirectoryPatch diffs the subdirectories of two commits, from may be nil to diff against an empty tree.
+func subdirectoryPatch(from *object.Commit, fromSubdirectory string, to *object.Commit, toSubdirectory string) (*object.Patch, error) {
+	fromTree := &object.Tree{}
+
+	if from != nil {
+		var err error
+		if fromTree, err = subtree(from, fromSubdirectory); err != nil {
+			return nil, err
+		}
+	}
+
+	toTree, err := subtree(to, toSubdirectory)
+	if err != nil {
+		return nil, err
+	}
+
+	return fromTree.Patch(toTree)
+}
+
+func fileStats(patch *object.Patch) []FileStat {
+	stats := patch.Stats()
+
+	files := make([]FileStat, len(stats))
+	for i, s := range stats {
+		files[i] = FileStat{
+			Name:      s.Name,
+			Additions: s.Addition,
+			Deletions: s.Deletion,
+		}
+	}
+
+	return files
+}
+
+// filePatch is a diff.Patch holding a subset of the file patches of another patch.
+type filePatch struct {
+	patches []diff.FilePatch
+}
+
+func (p filePatch) FilePatches() []diff.FilePatch {
+	return p.patches
+}
+
+func (p filePatch) Message() string {
+	return ""
+}
+
+func encodeFileDiff(patch *object.Patch, file string) (string, error) {
+	matching := slices.DeleteFunc(slices.Clone(patch.FilePatches()), func(fp diff.FilePatch) bool {
+		from, to := fp.Files()
+		return (from == nil || from.Path() != file) && (to == nil || to.Path() != file)
+	})
+
+	if len(matching) == 0 {
+		return "", nil
+	}
+
+	buf := &bytes.Buffer{}
+	if err := diff.NewUnifiedEncoder(buf, diff.DefaultContextLines).Encode(filePatch{patches: matching}); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
@@ -0,0 +1,139 @@
+package iter
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// ErrSubdirectoryRemoved is returned when an upstream subdirectory is removed and its new location could not be determined.
+var ErrSubdirectoryRemoved = fmt.Errorf("subdirectory was removed")
+
+func normalizeSubdirectory(subdirectory *string) string {
+	if subdirectory == nil {
+		return ""
+	}
+
+	return strings.Trim(*subdirectory, "/")
+}
+
+func hasSubdirectory(c *object.Commit, subdirectory string) (bool, error) {
+	hash, err := subtreeHash(c, subdirectory)
+	if err != nil {
+		return false, err
+	}
+
+	return hash != plumbing.ZeroHash, nil
+}
+
+// nextSubdirectory determines the subdirectory holding the chart after commit c, given it was in current before c. When target is set, the iterator switches to it as soon as it is introduced or current is removed.
+func nextSubdirectory(c *object.Commit, current string, target string) (string, error) {
+	if current == "" || c.NumParents() == 0 {
+		return current, nil
+	}
+
+	parent, err := c.Parent(0)
+	if err != nil {
+		return "", err
+	}
+
+	if target != "" && target != current {
+		inCommit, err := hasSubdirectory(c, target)
+		if err != nil {
+			return "", err
+		}
+
+		inParent, err := hasSubdirectory(parent, target)
+		if err != nil {
+			return "", err
+		}
+
+		if inCommit && !inParent {
+			return target, nil
+		}
+	}
+
+	if exists, err := hasSubdirectory(c, current); err != nil {
+		return "", err
+	} else if exists {
+		return current, nil
+	}
+
+	if existed, err := hasSubdirectory(parent, current); err != nil {
+		return "", err
+	} else if !existed {
+		return current, nil
+	}
+
+	if target != "" && target != current {
+		if exists, err := hasSubdirectory(c, target); err != nil {
+			return "", err
+		} else if exists {
+			return target, nil
+		}
+	}
+
+	moved, err := detectMove(parent, c, current)
+	if err != nil {
+		return "", err
+	}
+
+	if moved == "" {
+		return "", fmt.Errorf("%w: '%s' in upstream commit '%s', use --subdirectory to give its new location", ErrSubdirectoryRemoved, current, c.Hash)
+	}
+
+	return moved, nil
+}
+
+// detectMove uses rename detection to find where the files in subdirectory were moved to between the two commits. Returns an empty string if most of the files were not moved to a single directory.
+func detectMove(from *object.Commit, to *object.Commit, subdirectory string) (string, error) {
+	fromTree, err := from.Tree()
+	if err != nil {
+		return "", err
+	}
+
+	toTree, err := to.Tree()
+	if err != nil {
+		return "", err
+	}
+
+	changes, err := object.DiffTreeWithOptions(context.Background(), fromTree, toTree, object.DefaultDiffTreeOptions)
+	if err != nil {
+		return "", fmt.Errorf("failed to diff commits: %w", err)
+	}
+
+	prefix := subdirectory + "/"
+	votes := make(map[string]int)
+	total := 0
+
+	for _, change := range changes {
+		if !strings.HasPrefix(change.From.Name, prefix) {
+			continue
+		}
+
+		total++
+
+		rel := strings.TrimPrefix(change.From.Name, prefix)
+		if change.To.Name == "" || !strings.HasSuffix(change.To.Name, "/"+rel) {
+			continue
+		}
+
+		votes[strings.TrimSuffix(change.To.Name, "/"+rel)]++
+	}
+
+	best := ""
+	for dir, n := range votes {
+		if n > votes[best] || (n == votes[best] && dir < best) {
+			best = dir
+		}
+	}
+
+	if best == "" || votes[best]*2 <= total {
+		return "", nil
+	}
+
+	return best, nil
+}
@@ -0,0 +1,140 @@
+package iter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/joshmeranda/chartsutil/pkg/cache"
+)
+
+// minShortHashLen is the shortest hash prefix we will try to resolve, matching git's own minimum.
+const minShortHashLen = 4
+
+var (
+	ErrUnknownRef   = errors.New("unknown ref")
+	ErrAmbiguousRef = errors.New("ambiguous ref")
+)
+
+// ResolveRef resolves a full or short commit hash, tag, annotated tag, or branch name to the full hash of the commit it points to.
+func ResolveRef(repo *git.Repository, ref string) (plumbing.Hash, error) {
+	if plumbing.IsHash(ref) {
+		hash := plumbing.NewHash(ref)
+		if _, err := repo.CommitObject(hash); err == nil {
+			return hash, nil
+		}
+	}
+
+	candidates := []plumbing.ReferenceName{
+		plumbing.ReferenceName(ref),
+		plumbing.NewTagReferenceName(ref),
+		plumbing.NewBranchReferenceName(ref),
+		plumbing.NewRemoteReferenceName(git.DefaultRemoteName, ref),
+	}
+
+	for _, name := range candidates {
+		r, err := repo.Reference(name, true)
+		if errors.Is(err, plumbing.ErrReferenceNotFound) {
+			continue
+		} else if err != nil {
+			return plumbing.ZeroHash, fmt.Errorf("failed to read reference '%s': %w", name, err)
+		}
+
+		hash, err := peel(repo, r.Hash())
+		if err != nil {
+			return plumbing.ZeroHash, fmt.Errorf("failed to resolve '%s' to a commit: %w", name, err)
+		}
+
+		return hash, nil
+	}
+
+	if isHex(ref) && len(ref) >= minShortHashLen {
+		return resolveShortHash(repo, strings.ToLower(ref))
+	}
+
+	return plumbing.ZeroHash, fmt.Errorf("%w: '%s'", ErrUnknownRef, ref)
+}
+
+// ResolveUpstreamRef resolves ref against the cached clone of the upstream at url.
+func ResolveUpstreamRef(ctx context.Context, c *cache.Cache, url string, ref string) (plumbing.Hash, error) {
+	c, err := cache.OrDefault(c)
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("failed to get upstream cache: %w", err)
+	}
+
+	repo, err := c.Repo(ctx, url, nil)
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("failed to get cached repository: %w", err)
+	}
+	defer repo.Close()
+
+	return ResolveRef(repo.Repository, ref)
+}
+
+// peel follows annotated tags until reaching a commit.
+func peel(repo *git.Repository, hash plumbing.Hash) (plumbing.Hash, error) {
+	for {
+		tag, err := repo.TagObject(hash)
+		if errors.Is(err, plumbing.ErrObjectNotFound) {
+			break
+		} else if err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		hash = tag.Target
+	}
+
+	if _, err := repo.CommitObject(hash); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return hash, nil
+}
+
+func resolveShortHash(repo *git.Repository, prefix string) (plumbing.Hash, error) {
+	commits, err := repo.CommitObjects()
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("failed to list commits: %w", err)
+	}
+
+	matches := make([]plumbing.Hash, 0, 1)
+
+	err = commits.ForEach(func(c *object.Commit) error {
+		if strings.HasPrefix(c.Hash.String(), prefix) {
+			matches = append(matches, c.Hash)
+		}
+
+		if len(matches) > 1 {
+			return storer.ErrStop
+		}
+
+		return nil
+	})
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("failed to search commits: %w", err)
+	}
+
+	switch len(matches) {
+	case 0:
+		return plumbing.ZeroHash, fmt.Errorf("%w: '%s'", ErrUnknownRef, prefix)
+	case 1:
+		return matches[0], nil
+	default:
+		return plumbing.ZeroHash, fmt.Errorf("%w: '%s' matches multiple commits", ErrAmbiguousRef, prefix)
+	}
+}
+
+func isHex(s string) bool {
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return false
+		}
+	}
+
+	return s != ""
+}
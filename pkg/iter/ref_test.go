@@ -0,0 +1,128 @@
+package iter_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/joshmeranda/chartsutil/pkg/iter"
+)
+
+func TestResolveRef(t *testing.T) {
+	dir := t.TempDir()
+
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatalf("failed to init repo: %v", err)
+	}
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("failed to get worktree: %v", err)
+	}
+
+	sig := &object.Signature{Name: "chartsutil-test", When: time.Now()}
+
+	commits := make([]plumbing.Hash, 2)
+	for i := range commits {
+		if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte{byte('a' + i)}, 0644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+
+		if _, err := wt.Add("README.md"); err != nil {
+			t.Fatalf("failed to add file: %v", err)
+		}
+
+		if commits[i], err = wt.Commit("commit", &git.CommitOptions{Author: sig}); err != nil {
+			t.Fatalf("failed to commit: %v", err)
+		}
+	}
+
+	if _, err := repo.CreateTag("v1.0.0", commits[0], nil); err != nil {
+		t.Fatalf("failed to create tag: %v", err)
+	}
+
+	if _, err := repo.CreateTag("v2.0.0", commits[1], &git.CreateTagOptions{Tagger: sig, Message: "v2.0.0"}); err != nil {
+		t.Fatalf("failed to create annotated tag: %v", err)
+	}
+
+	if err := repo.Storer.SetReference(plumbing.NewHashReference(plumbing.NewBranchReferenceName("release-v1"), commits[0])); err != nil {
+		t.Fatalf("failed to create branch: %v", err)
+	}
+
+	type testCase struct {
+		Name     string
+		Ref      string
+		Expected plumbing.Hash
+		Err      error
+	}
+
+	cases := []testCase{
+		{
+			Name:     "FullHash",
+			Ref:      commits[0].String(),
+			Expected: commits[0],
+		},
+		{
+			Name:     "ShortHash",
+			Ref:      commits[1].String()[:7],
+			Expected: commits[1],
+		},
+		{
+			Name:     "LightweightTag",
+			Ref:      "v1.0.0",
+			Expected: commits[0],
+		},
+		{
+			Name:     "AnnotatedTag",
+			Ref:      "v2.0.0",
+			Expected: commits[1],
+		},
+		{
+			Name:     "Branch",
+			Ref:      "release-v1",
+			Expected: commits[0],
+		},
+		{
+			Name:     "FullRefName",
+			Ref:      "refs/tags/v2.0.0",
+			Expected: commits[1],
+		},
+		{
+			Name: "Unknown",
+			Ref:  "v3.0.0",
+			Err:  iter.ErrUnknownRef,
+		},
+		{
+			Name: "UnknownHash",
+			Ref:  "0000000000000000000000000000000000000001",
+			Err:  iter.ErrUnknownRef,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			hash, err := iter.ResolveRef(repo, c.Ref)
+			if c.Err != nil {
+				if !errors.Is(err, c.Err) {
+					t.Fatalf("expected error '%v' but found '%v'", c.Err, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if hash != c.Expected {
+				t.Errorf("expected '%s' but found '%s'", c.Expected, hash)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package rebase
+
+import (
+	"fmt"
+
+	"github.com/go-git/go-billy/v5"
+	chartspath "github.com/rancher/charts-build-scripts/pkg/path"
+	"gopkg.in/yaml.v2"
+)
+
+// PackageConfig is the chartsutil specific configuration of a package, found under the 'chartsutil' key of its package.yaml. Since charts-build-scripts ignores any keys it does not know about, this can live alongside the normal package options.
+type PackageConfig struct {
+	Merge MergeOptions `yaml:"merge,omitempty"`
+}
+
+// LoadPackageConfig reads the chartsutil configuration from the package.yaml in pkgFs, returning an empty config if none is present.
+func LoadPackageConfig(pkgFs billy.Filesystem) (PackageConfig, error) {
+	f, err := pkgFs.Open(chartspath.PackageOptionsFile)
+	if err != nil {
+		return PackageConfig{}, fmt.Errorf("failed to open package options: %w", err)
+	}
+	defer f.Close()
+
+	var options struct {
+		Config PackageConfig `yaml:"chartsutil"`
+	}
+
+	if err := yaml.NewDecoder(f).Decode(&options); err != nil {
+		return PackageConfig{}, fmt.Errorf("failed to decode package options: %w", err)
+	}
+
+	return options.Config, nil
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
 	"github.com/go-git/go-git/v5/plumbing/object"
 )
 
@@ -119,6 +120,34 @@ func DoOnBranch(r *git.Repository, wt *git.Worktree, branch string, f WorktreeFu
 	return nil
 }
 
+// ClearConflicts removes the conflict stages a merge left in the index. go-git only updates the first stage of a conflicted file when it is added, so they would otherwise all be committed as duplicate entries.
+func ClearConflicts(r *git.Repository) error {
+	idx, err := r.Storer.Index()
+	if err != nil {
+		return fmt.Errorf("failed to read index: %w", err)
+	}
+
+	n := len(idx.Entries)
+
+	// merged entries have stage 0, despite index.Merged being 1
+	idx.Entries = slices.DeleteFunc(idx.Entries, func(e *index.Entry) bool {
+		return e.Stage != 0
+	})
+
+	if len(idx.Entries) == n {
+		return nil
+	}
+
+	// the cached trees no longer match the entries
+	idx.Cache = nil
+
+	if err := r.Storer.SetIndex(idx); err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+
+	return nil
+}
+
 func isPathAllowed(path string) bool {
 	return slices.ContainsFunc(AllowedDirectories, func(allowed string) bool {
 		return strings.HasPrefix(path, allowed)
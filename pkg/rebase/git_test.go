@@ -0,0 +1,95 @@
+package rebase_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/joshmeranda/chartsutil/pkg/rebase"
+)
+
+func runGit(t *testing.T, dir string, args ...string) {
+	t.Helper()
+
+	cmd := exec.Command("git", append([]string{"-c", "user.name=chartsutil-test", "-c", "user.email=test@example.com"}, args...)...)
+	cmd.Dir = dir
+
+	if err := cmd.Run(); err != nil {
+		if _, ok := err.(*exec.ExitError); !ok {
+			t.Fatalf("failed to run git %v: %v", args, err)
+		}
+	}
+}
+
+func TestClearConflicts(t *testing.T) {
+	dir := t.TempDir()
+
+	write := func(content string) {
+		if err := os.WriteFile(filepath.Join(dir, "Chart.yaml"), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+
+	runGit(t, dir, "init", "--initial-branch", "main")
+
+	write("apiVersion: v2\nversion: 1.0.0\n")
+	runGit(t, dir, "add", "Chart.yaml")
+	runGit(t, dir, "commit", "-m", "initial commit")
+
+	runGit(t, dir, "checkout", "-b", "upstream")
+	write("apiVersion: v2\nversion: 1.1.0\n")
+	runGit(t, dir, "commit", "-am", "upstream change")
+
+	runGit(t, dir, "checkout", "main")
+	write("apiVersion: v2\nversion: 1.0.1\n")
+	runGit(t, dir, "commit", "-am", "local change")
+
+	runGit(t, dir, "merge", "--squash", "--no-commit", "upstream")
+
+	// resolved without staging, as a user might from the shell
+	write("apiVersion: v2\nversion: 1.1.1\n")
+
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		t.Fatalf("failed to open repo: %v", err)
+	}
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("failed to get worktree: %v", err)
+	}
+
+	if err := rebase.ClearConflicts(repo); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	hash, err := rebase.Commit(wt, false, "resolve conflicts", "Chart.yaml")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	commit, err := repo.CommitObject(hash)
+	if err != nil {
+		t.Fatalf("failed to get commit: %v", err)
+	}
+
+	tree, err := commit.Tree()
+	if err != nil {
+		t.Fatalf("failed to get tree: %v", err)
+	}
+
+	if len(tree.Entries) != 1 {
+		t.Fatalf("expected a single tree entry for the conflicted file but found %d", len(tree.Entries))
+	}
+
+	file, err := tree.File("Chart.yaml")
+	if err != nil {
+		t.Fatalf("failed to get file: %v", err)
+	}
+
+	if content, _ := file.Contents(); content != "apiVersion: v2\nversion: 1.1.1\n" {
+		t.Errorf("expected resolved content to be committed but found:\n%s", content)
+	}
+}
@@ -0,0 +1,191 @@
+package rebase
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/joshmeranda/chartsutil/pkg/resolve"
+)
+
+var (
+	// MergeStrategies are the strategies which may be used to merge upstream changes.
+	MergeStrategies = []string{"ort", "recursive", "resolve"}
+
+	// MergeStrategyOptions are the options which may be passed to the merge strategy, options ending in '=' expect a value.
+	MergeStrategyOptions = []string{
+		"ours", "theirs", "patience", "histogram", "diff-algorithm=",
+		"ignore-space-change", "ignore-all-space", "ignore-space-at-eol", "ignore-cr-at-eol",
+		"renormalize", "no-renormalize", "find-renames", "find-renames=", "no-renames",
+	}
+
+	// ConflictStyles are the values allowed for git's merge.conflictStyle.
+	ConflictStyles = []string{"merge", "diff3", "zdiff3"}
+)
+
+// MergeOptions configures how each upstream is squash merged into the charts.
+type MergeOptions struct {
+	// Strategy is the merge strategy to use, or git's default if empty.
+	Strategy string `yaml:"strategy,omitempty"`
+
+	// StrategyOptions are passed to the strategy via '-X' (ex. 'patience' or 'find-renames=50').
+	StrategyOptions []string `yaml:"strategyOptions,omitempty"`
+
+	// ConflictStyle is the style of conflict markers written to conflicting files, or git's configured style if empty.
+	ConflictStyle string `yaml:"conflictStyle,omitempty"`
+}
+
+func isStrategyOptionAllowed(option string) bool {
+	return slices.ContainsFunc(MergeStrategyOptions, func(allowed string) bool {
+		if strings.HasSuffix(allowed, "=") {
+			return strings.HasPrefix(option, allowed) && len(option) > len(allowed)
+		}
+
+		return option == allowed
+	})
+}
+
+// Validate checks that all options are understood by git.
+func (o MergeOptions) Validate() error {
+	if o.Strategy != "" && !slices.Contains(MergeStrategies, o.Strategy) {
+		return fmt.Errorf("unsupported merge strategy '%s', expected one of %v", o.Strategy, MergeStrategies)
+	}
+
+	for _, option := range o.StrategyOptions {
+		if !isStrategyOptionAllowed(option) {
+			return fmt.Errorf("unsupported merge strategy option '%s'", option)
+		}
+	}
+
+	if o.ConflictStyle != "" && !slices.Contains(ConflictStyles, o.ConflictStyle) {
+		return fmt.Errorf("unsupported conflict style '%s', expected one of %v", o.ConflictStyle, ConflictStyles)
+	}
+
+	return nil
+}
+
+// Override returns a copy of o with any non-empty fields of other replacing its own.
+func (o MergeOptions) Override(other MergeOptions) MergeOptions {
+	if other.Strategy != "" {
+		o.Strategy = other.Strategy
+	}
+
+	if len(other.StrategyOptions) > 0 {
+		o.StrategyOptions = other.StrategyOptions
+	}
+
+	if other.ConflictStyle != "" {
+		o.ConflictStyle = other.ConflictStyle
+	}
+
+	return o
+}
+
+// Args returns the arguments to git to squash merge the given branch.
+func (o MergeOptions) Args(branch string) []string {
+	args := make([]string, 0)
+
+	if o.ConflictStyle != "" {
+		args = append(args, "-c", "merge.conflictStyle="+o.ConflictStyle)
+	}
+
+	args = append(args, "merge", "--squash", "--no-commit")
+
+	if o.Strategy != "" {
+		args = append(args, "--strategy", o.Strategy)
+	}
+
+	for _, option := range o.StrategyOptions {
+		args = append(args, "--strategy-option", option)
+	}
+
+	return append(args, branch)
+}
+
+// ParseMergeOutput parses the output of 'git merge' into the files which were merged automatically and those with conflicts.
+func ParseMergeOutput(output string) resolve.MergeResult {
+	result := resolve.MergeResult{}
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case strings.HasPrefix(line, "Auto-merging "):
+			result.AutoMerged = append(result.AutoMerged, strings.TrimPrefix(line, "Auto-merging "))
+		case strings.HasPrefix(line, "CONFLICT ("):
+			kind, message, found := strings.Cut(strings.TrimPrefix(line, "CONFLICT ("), "): ")
+			if !found {
+				continue
+			}
+
+			var path string
+			if _, after, found := strings.Cut(message, "Merge conflict in "); found {
+				path = after
+			} else {
+				path, _, _ = strings.Cut(message, " ")
+			}
+
+			result.Conflicts = append(result.Conflicts, resolve.MergeConflict{
+				Kind:    kind,
+				Path:    path,
+				Message: message,
+			})
+		}
+	}
+
+	return result
+}
+
+// parseRenames parses renames from the output of 'git diff --name-status'.
+func parseRenames(output string) []resolve.Rename {
+	renames := make([]resolve.Rename, 0)
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) == 3 && strings.HasPrefix(fields[0], "R") {
+			renames = append(renames, resolve.Rename{From: fields[1], To: fields[2]})
+		}
+	}
+
+	return renames
+}
+
+// Merge squash merges branch into the current branch of the repository at dir.
+func Merge(dir string, branch string, opts MergeOptions) (resolve.MergeResult, error) {
+	// need to run as subprocess since go-git Pull only supports fast-forward merges
+	cmd := exec.Command("git", opts.Args(branch)...)
+	cmd.Dir = dir
+	// the output is parsed for conflicts, so it must not be translated
+	cmd.Env = append(os.Environ(), "LC_ALL=C")
+
+	output, err := cmd.CombinedOutput()
+	result := ParseMergeOutput(string(output))
+
+	if err != nil {
+		switch err.(type) {
+		case *exec.ExitError:
+			if !result.HasConflicts() {
+				return result, fmt.Errorf("merge command '%s' failed: %s", cmd.String(), strings.TrimSpace(string(output)))
+			}
+		default:
+			return result, fmt.Errorf("could not run merge command '%s': %w", cmd.String(), err)
+		}
+	}
+
+	cmd = exec.Command("git", "diff", "--cached", "--name-status", "--find-renames")
+	cmd.Dir = dir
+
+	output, err = cmd.Output()
+	if err != nil {
+		return result, fmt.Errorf("could not list renamed files: %w", err)
+	}
+
+	result.Renamed = parseRenames(string(output))
+
+	return result, nil
+}
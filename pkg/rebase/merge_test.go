@@ -0,0 +1,156 @@
+package rebase_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/joshmeranda/chartsutil/pkg/rebase"
+	"github.com/joshmeranda/chartsutil/pkg/resolve"
+	"github.com/rancher/charts-build-scripts/pkg/filesystem"
+)
+
+func TestParseMergeOutput(t *testing.T) {
+	output := `Auto-merging packages/example/charts/values.yaml
+CONFLICT (content): Merge conflict in packages/example/charts/values.yaml
+CONFLICT (modify/delete): packages/example/charts/README.md deleted in charts-staging and modified in HEAD.  Version HEAD of packages/example/charts/README.md left in tree.
+Squash commit -- not updating HEAD
+Automatic merge failed; fix conflicts and then commit the result.
+`
+
+	expected := resolve.MergeResult{
+		AutoMerged: []string{"packages/example/charts/values.yaml"},
+		Conflicts: []resolve.MergeConflict{
+			{
+				Kind:    "content",
+				Path:    "packages/example/charts/values.yaml",
+				Message: "Merge conflict in packages/example/charts/values.yaml",
+			},
+			{
+				Kind:    "modify/delete",
+				Path:    "packages/example/charts/README.md",
+				Message: "packages/example/charts/README.md deleted in charts-staging and modified in HEAD.  Version HEAD of packages/example/charts/README.md left in tree.",
+			},
+		},
+	}
+
+	actual := rebase.ParseMergeOutput(output)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("merge result does not match expected value:\nExpected: %+v\n   Found: %+v", expected, actual)
+	}
+}
+
+func TestMergeOptionsArgs(t *testing.T) {
+	opts := rebase.MergeOptions{
+		Strategy:        "recursive",
+		StrategyOptions: []string{"patience", "histogram"},
+		ConflictStyle:   "diff3",
+	}
+
+	if err := opts.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{
+		"-c", "merge.conflictStyle=diff3",
+		"merge", "--squash", "--no-commit",
+		"--strategy", "recursive",
+		"--strategy-option", "patience",
+		"--strategy-option", "histogram",
+		"charts-staging",
+	}
+
+	if actual := opts.Args("charts-staging"); !slices.Equal(actual, expected) {
+		t.Errorf("args do not match expected value:\nExpected: %v\n   Found: %v", expected, actual)
+	}
+}
+
+func TestMergeOptionsValidate(t *testing.T) {
+	type Case struct {
+		Name       string
+		Opts       rebase.MergeOptions
+		ExpectsErr bool
+	}
+
+	cases := []Case{
+		{
+			Name: "Empty",
+			Opts: rebase.MergeOptions{},
+		},
+		{
+			Name: "FindRenames",
+			Opts: rebase.MergeOptions{StrategyOptions: []string{"find-renames=50"}},
+		},
+		{
+			Name:       "UnknownStrategy",
+			Opts:       rebase.MergeOptions{Strategy: "octopus"},
+			ExpectsErr: true,
+		},
+		{
+			Name:       "UnknownOption",
+			Opts:       rebase.MergeOptions{StrategyOptions: []string{"subtree=charts"}},
+			ExpectsErr: true,
+		},
+		{
+			Name:       "MissingOptionValue",
+			Opts:       rebase.MergeOptions{StrategyOptions: []string{"diff-algorithm="}},
+			ExpectsErr: true,
+		},
+		{
+			Name:       "UnknownConflictStyle",
+			Opts:       rebase.MergeOptions{ConflictStyle: "diff4"},
+			ExpectsErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			err := c.Opts.Validate()
+			if c.ExpectsErr && err == nil {
+				t.Fatalf("expected err but received none")
+			} else if !c.ExpectsErr && err != nil {
+				t.Fatalf("does not expect err but received '%s'", err)
+			}
+		})
+	}
+}
+
+func TestLoadPackageConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	content := `url: https://github.com/joshmeranda/chartsutil-example-upstream.git
+commit: 933d8b2975efa50cda4dca6234e5e522b8f58cdc
+chartsutil:
+  merge:
+    strategy: ort
+    strategyOptions:
+      - patience
+    conflictStyle: zdiff3
+`
+
+	if err := os.WriteFile(filepath.Join(dir, "package.yaml"), []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write package.yaml: %v", err)
+	}
+
+	config, err := rebase.LoadPackageConfig(filesystem.GetFilesystem(dir))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := rebase.MergeOptions{
+		Strategy:        "ort",
+		StrategyOptions: []string{"patience"},
+		ConflictStyle:   "zdiff3",
+	}
+
+	if !reflect.DeepEqual(config.Merge, expected) {
+		t.Errorf("merge options do not match expected value:\nExpected: %+v\n   Found: %+v", expected, config.Merge)
+	}
+
+	overridden := config.Merge.Override(rebase.MergeOptions{ConflictStyle: "diff3"})
+	if overridden.ConflictStyle != "diff3" || overridden.Strategy != "ort" {
+		t.Errorf("unexpected overridden merge options: %+v", overridden)
+	}
+}
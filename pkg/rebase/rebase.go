@@ -3,18 +3,22 @@ package rebase
 import (
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"os/exec"
 	"path"
 	"path/filepath"
 	"slices"
+	"strings"
 	"time"
 
 	"github.com/go-git/go-billy/v5"
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/joshmeranda/chartsutil/pkg/cache"
 	"github.com/joshmeranda/chartsutil/pkg/iter"
 	"github.com/joshmeranda/chartsutil/pkg/resolve"
 	"github.com/mikefarah/yq/v4/pkg/yqlib"
@@ -52,6 +56,12 @@ type Options struct {
 	EnableBackup      bool
 	DisableValidators bool
 	ImageNamespace    string
+
+	// Merge configures how upstreams are merged into the charts, any fields set here override those from the package config.
+	Merge MergeOptions
+
+	// Cache holds upstream clones and archives between runs, if nil the default cache is used.
+	Cache *cache.Cache
 }
 
 type Rebase struct {
@@ -66,10 +76,16 @@ type Rebase struct {
 	chartsWt     *git.Worktree
 	startingHead plumbing.Hash
 
+	// step is the upstream step currently being handled
+	step resolve.Step
+
+	// skipped are the upstreams the resolver chose to skip
+	skipped []puller.Puller
+
 	validators []PackageValidateFunc
 }
 
-func NewRebase(pkg *charts.Package, rootFs billy.Filesystem, pkgFs billy.Filesystem, iter iter.UpstreamIter, opts Options) (*Rebase, error) {
+func NewRebase(pkg *charts.Package, rootFs billy.Filesystem, pkgFs billy.Filesystem, upstreamIter iter.UpstreamIter, opts Options) (*Rebase, error) {
 	if opts.Logger == nil {
 		opts.Logger = slog.New(slog.NewTextHandler(os.Stdout, nil))
 	}
@@ -81,6 +97,27 @@ func NewRebase(pkg *charts.Package, rootFs billy.Filesystem, pkgFs billy.Filesys
 		}
 	}
 
+	config, err := LoadPackageConfig(pkgFs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load package config: %w", err)
+	}
+
+	if opts.Cache, err = cache.OrDefault(opts.Cache); err != nil {
+		return nil, fmt.Errorf("failed to get upstream cache: %w", err)
+	}
+
+	// charts-build-scripts rewrites git urls to GitHub, which breaks private and local upstreams
+	if pkg.Chart.Upstream, err = iter.PackageUpstream(pkgFs); err != nil {
+		return nil, fmt.Errorf("failed to get package upstream: %w", err)
+	}
+
+	pkg.Chart.Upstream = iter.CachedPuller(pkg.Chart.Upstream, opts.Cache)
+
+	opts.Merge = config.Merge.Override(opts.Merge)
+	if err := opts.Merge.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid merge options: %w", err)
+	}
+
 	chartsRepo, err := git.PlainOpen(rootFs.Root())
 	if err != nil {
 		return nil, fmt.Errorf("failed to open charts repository: %w", err)
@@ -100,15 +137,7 @@ func NewRebase(pkg *charts.Package, rootFs billy.Filesystem, pkgFs billy.Filesys
 	if opts.DisableValidators {
 		validators = []PackageValidateFunc{}
 	} else {
-		validators = []PackageValidateFunc{
-			ValidateWorktree,
-			ValidatePatternNotFoundFactory("<<<<<<< HEAD"),
-			ValidateHelmLint,
-		}
-
-		if opts.ImageNamespace != "" {
-			validators = append(validators, ValidateImagesInNamespaceFactory(opts.ImageNamespace))
-		}
+		validators = DefaultValidators(opts.ImageNamespace)
 	}
 
 	return &Rebase{
@@ -117,7 +146,7 @@ func NewRebase(pkg *charts.Package, rootFs billy.Filesystem, pkgFs billy.Filesys
 		Package: pkg,
 		RootFs:  rootFs,
 		PkgFs:   pkgFs,
-		Iter:    iter,
+		Iter:    upstreamIter,
 
 		chartsRepo:   chartsRepo,
 		chartsWt:     chartsWorktree,
@@ -137,12 +166,24 @@ func (r *Rebase) commitCharts(msg string) (plumbing.Hash, error) {
 		chartPaths[i+1] = filepath.Join(pkgDir, chart.WorkingDir)
 	}
 
+	// conflicted files are staged again from the worktree by Commit
+	if err := ClearConflicts(r.chartsRepo); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
 	return Commit(r.chartsWt, false, msg, chartPaths...)
 }
 
 func (r *Rebase) resolve() error {
+	r.step.ValidationError = nil
+
 resolveLoop:
 	for {
+		// resolvers are told why the worktree failed validation, since there may be no conflicts left to resolve
+		if sr, ok := r.Resolver.(resolve.StepResolver); ok {
+			sr.SetStep(r.step)
+		}
+
 		err := r.Resolver.Resolve(r.chartsWt)
 
 		if errors.Is(err, resolve.ErrAbort) {
@@ -153,6 +194,19 @@ resolveLoop:
 			return err
 		}
 
+		if errors.Is(err, resolve.ErrSkip) {
+			if !r.step.Skippable {
+				r.Logger.Error("the current upstream cannot be skipped", "upstream", r.step.Upstream)
+				continue
+			}
+
+			if err := r.chartsWt.Reset(&git.ResetOptions{Mode: git.HardReset}); err != nil {
+				return fmt.Errorf("failed to reset worktree after skip: %w", err)
+			}
+
+			return err
+		}
+
 		if err != nil {
 			return fmt.Errorf("received error from resolver: %w", err)
 		}
@@ -161,6 +215,7 @@ resolveLoop:
 			err := validator(r.Package, r.chartsWt, r.PkgFs)
 			if errors.Is(err, ValidateError{}) {
 				r.Logger.Error("failed validation", "err", err)
+				r.step.ValidationError = err
 				continue resolveLoop
 			} else if err != nil {
 				return fmt.Errorf("could not verify chart: %w", err)
@@ -177,49 +232,86 @@ resolveLoop:
 	return nil
 }
 
-func (r *Rebase) handleUpstream(upstream puller.Puller) error {
-	r.Logger.Info("bringing charts to next upstream", "upstream", UpstreamRef(upstream.GetOptions()))
-
+// snapshotUpstream creates the staging branch and commits the unmodified upstream charts to it.
+func (r *Rebase) snapshotUpstream(upstream puller.Puller) (plumbing.Hash, error) {
 	if err := CreateBranch(r.chartsRepo, ChartsStagingBranchName, r.startingHead); err != nil {
-		return fmt.Errorf("failed to create staging branch: %w", err)
+		return plumbing.ZeroHash, fmt.Errorf("failed to create staging branch: %w", err)
 	}
-	defer DeleteBranch(r.chartsRepo, ChartsStagingBranchName)
+
+	var hash plumbing.Hash
 
 	err := DoOnBranch(r.chartsRepo, r.chartsWt, ChartsStagingBranchName, func(wt *git.Worktree) error {
 		if err := upstream.Pull(r.RootFs, r.PkgFs, r.Package.WorkingDir); err != nil {
 			return fmt.Errorf("failed to pull upstream changes: %w", err)
 		}
 
-		if _, err := r.commitCharts("saving copied upstream charts"); err != nil {
+		var err error
+		if hash, err = r.commitCharts("saving copied upstream charts"); err != nil {
 			return fmt.Errorf("failed to commit original chart: %w", err)
 		}
 
 		return nil
 	})
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return hash, nil
+}
+
+func (r *Rebase) handleUpstream(upstream puller.Puller) error {
+	upstream = iter.CachedPuller(upstream, r.Cache)
+
+	r.Logger.Info("bringing charts to next upstream", "upstream", UpstreamRef(upstream.GetOptions()))
+
+	hash, err := r.snapshotUpstream(upstream)
+	defer DeleteBranch(r.chartsRepo, ChartsStagingBranchName)
 	if err != nil {
 		return err
 	}
 
-	// need to run as subprocess since go-git Pull only supports fast-forward merges
-	cmd := exec.Command("git", "merge", "--squash", "--no-commit", ChartsStagingBranchName)
-	cmd.Dir = r.RootFs.Root()
+	r.step.Index++
+	r.step.Upstream = UpstreamRef(upstream.GetOptions())
+	r.step.PreviousUpstreamCommit = r.step.UpstreamCommit
+	r.step.UpstreamCommit = hash
+	r.step.Skippable = r.hasRemainingUpstreams()
 
-	r.Logger.Info("merging branch", "dir", cmd.Dir, "cmd", cmd.String())
-	output, err := cmd.CombinedOutput()
+	r.Logger.Info("merging branch", "branch", ChartsStagingBranchName, "strategy", r.Merge.Strategy, "options", r.Merge.StrategyOptions)
 
+	result, err := Merge(r.RootFs.Root(), ChartsStagingBranchName, r.Merge)
 	if err != nil {
-		switch err.(type) {
-		case *exec.ExitError:
-			fmt.Println(string(output))
-		default:
-			return fmt.Errorf("could not run merge command '%s': %w", cmd.String(), err)
-		}
+		return err
+	}
+
+	r.step.Merge = result
 
+	for _, file := range result.AutoMerged {
+		r.Logger.Debug("merged automatically", "file", file)
 	}
 
-	r.Logger.Info("could not merge automatically, running resolver")
+	for _, rename := range result.Renamed {
+		r.Logger.Info("upstream renamed file", "from", rename.From, "to", rename.To)
+	}
 
-	if err := r.resolve(); err != nil {
+	for _, conflict := range result.Conflicts {
+		r.Logger.Warn("found conflict", "kind", conflict.Kind, "file", conflict.Path)
+	}
+
+	if result.HasConflicts() {
+		r.Logger.Info("could not merge automatically, running resolver", "conflicts", len(result.Conflicts))
+	} else {
+		r.Logger.Info("merged automatically, running resolver to review changes", "auto-merged", len(result.AutoMerged))
+	}
+
+	if err := r.resolve(); errors.Is(err, resolve.ErrSkip) {
+		r.Logger.Info("skipping upstream", "upstream", r.step.Upstream)
+		r.skipped = append(r.skipped, upstream)
+
+		// the next upstream is diffed against the last one actually merged, so the skipped changes are not hidden from it
+		r.step.UpstreamCommit = r.step.PreviousUpstreamCommit
+
+		return nil
+	} else if err != nil {
 		return fmt.Errorf("failed to resolve conflicts: %w", err)
 	}
 
@@ -230,6 +322,38 @@ func (r *Rebase) handleUpstream(upstream puller.Puller) error {
 	return nil
 }
 
+// hasRemainingUpstreams returns true if the iterator is known to have more upstreams after the current one. The last upstream can never be skipped since it is the target of the rebase.
+func (r *Rebase) hasRemainingUpstreams() bool {
+	sized, ok := r.Iter.(iter.SizedIter)
+	if !ok {
+		return false
+	}
+
+	remaining, err := sized.Len()
+	if err != nil {
+		r.Logger.Warn("failed to determine number of remaining upstreams", "err", err)
+		return false
+	}
+
+	return remaining > 0
+}
+
+// skippedSummary returns the list of skipped upstreams to include in commit messages, or an empty string if none were skipped.
+func (r *Rebase) skippedSummary() string {
+	if len(r.skipped) == 0 {
+		return ""
+	}
+
+	b := strings.Builder{}
+	b.WriteString("\n\nSkipped upstreams:\n")
+
+	for _, upstream := range r.skipped {
+		b.WriteString(fmt.Sprintf("- %s\n", GetRelaventUpstreamChange(upstream)))
+	}
+
+	return b.String()
+}
+
 func (r *Rebase) updatePatches(upstream puller.Puller) (plumbing.Hash, error) {
 	r.Logger.Info("generating patch")
 
@@ -239,7 +363,7 @@ func (r *Rebase) updatePatches(upstream puller.Puller) (plumbing.Hash, error) {
 
 	patchDir := path.Join("packages", r.Package.Name, "generated-changes")
 
-	hash, err := Commit(r.chartsWt, true, fmt.Sprintf("Updating %s to new base %s", r.Package.Name, GetRelaventUpstreamChange(upstream)), patchDir)
+	hash, err := Commit(r.chartsWt, true, fmt.Sprintf("Updating %s to new base %s%s", r.Package.Name, GetRelaventUpstreamChange(upstream), r.skippedSummary()), patchDir)
 	if err != nil {
 		return plumbing.ZeroHash, fmt.Errorf("failed to commit patch changes: %w", err)
 	}
@@ -289,15 +413,65 @@ func (r *Rebase) updatePackageYaml(upstream puller.Puller) (plumbing.Hash, error
 	return hash, nil
 }
 
-func (r *Rebase) reloadPackage() error {
-	pkg, err := charts.GetPackage(r.RootFs, r.Package.Name)
+// reloadPackage points the package at the upstream written to the package.yaml. The package is not reloaded from disk since charts-build-scripts can not parse local upstreams.
+func (r *Rebase) reloadPackage(upstream puller.Puller) {
+	r.Package.Chart.Upstream = iter.CachedPuller(upstream, r.Cache)
+}
+
+// logPlan logs each of the planned upstream steps along with any commits folded into them.
+func (r *Rebase) logPlan(planner iter.Planner) {
+	plan, err := planner.Plan()
 	if err != nil {
-		return fmt.Errorf("failed to get package: %w", err)
+		r.Logger.Warn("failed to plan upstream steps", "err", err)
+		return
 	}
 
-	r.Package = pkg
+	for i, step := range plan {
+		folded := make([]string, len(step.Folded))
+		for j, c := range step.Folded {
+			folded[j] = c.Hash
+		}
 
-	return nil
+		r.Logger.Info("planned upstream step", "step", i+1, "commit", step.Hash, "summary", step.Summary, "folded", folded)
+	}
+}
+
+// prepareSteps records the original upstream and the expected number of steps so resolvers can report on the rebase progress.
+func (r *Rebase) prepareSteps() {
+	r.step = resolve.Step{}
+
+	if sized, ok := r.Iter.(iter.SizedIter); ok {
+		if total, err := sized.Len(); err != nil {
+			r.Logger.Warn("failed to determine number of upstreams", "err", err)
+		} else {
+			r.step.Total = total
+		}
+	}
+
+	if planner, ok := r.Iter.(iter.Planner); ok {
+		r.logPlan(planner)
+	}
+
+	// the iterator may still hold the cached clone of the same upstream open, which the cache reuses rather than waiting on
+	r.step.UpstreamCommit = r.snapshotOriginal()
+}
+
+// snapshotOriginal commits the original upstream to the staging branch, returning the zero hash if it could not be saved.
+func (r *Rebase) snapshotOriginal() plumbing.Hash {
+	if r.Package.Chart.Upstream.IsWithinPackage() {
+		return plumbing.ZeroHash
+	}
+
+	hash, err := r.snapshotUpstream(r.Package.Chart.Upstream)
+	if err := DeleteBranch(r.chartsRepo, ChartsStagingBranchName); err != nil {
+		r.Logger.Warn("failed to delete staging branch", "err", err)
+	}
+	if err != nil {
+		r.Logger.Warn("failed to save original upstream, upstream diffs will not be available for the first upstream", "err", err)
+		return plumbing.ZeroHash
+	}
+
+	return hash
 }
 
 func (r *Rebase) Rebase() error {
@@ -310,8 +484,16 @@ func (r *Rebase) Rebase() error {
 		return fmt.Errorf("charts worktree is not clean")
 	}
 
+	if closer, ok := r.Iter.(io.Closer); ok {
+		defer func() {
+			if err := closer.Close(); err != nil {
+				r.Logger.Warn("failed to close upstream iterator", "err", err)
+			}
+		}()
+	}
+
 	cherryPickCommits := []string{}
-	rebaseStart := time.Now()
+	r.skipped = nil
 
 	if err := CreateBranch(r.chartsRepo, ChartsQuarantineBranchName, plumbing.ZeroHash); err != nil {
 		return fmt.Errorf("failed to create quarantine branch: %w", err)
@@ -351,6 +533,8 @@ func (r *Rebase) Rebase() error {
 			return fmt.Errorf("failed to save charts before pulling new upstream: %w", err)
 		}
 
+		r.prepareSteps()
+
 		var last puller.Puller
 
 		err := iter.ForEach(r.Iter, func(p puller.Puller) error {
@@ -380,20 +564,23 @@ func (r *Rebase) Rebase() error {
 			return fmt.Errorf("failed to update package.yaml: %w", err)
 		}
 
-		if err := r.reloadPackage(); err != nil {
-			return fmt.Errorf("failed to update package: %w", err)
-		}
+		r.reloadPackage(last)
 
 		if _, err = r.updatePatches(last); err != nil {
 			return fmt.Errorf("failed to generate patch: %w", err)
 		}
 
-		commitIter, err := r.chartsRepo.Log(&git.LogOptions{Since: &rebaseStart})
+		commitIter, err := r.chartsRepo.Log(&git.LogOptions{})
 		if err != nil {
 			return fmt.Errorf("failed to get commit iterator: %w", err)
 		}
 
+		// commit times only have second precision, so the quarantined commits are found by walking back to where the rebase started rather than by time
 		commitIter.ForEach(func(c *object.Commit) error {
+			if c.Hash == r.startingHead {
+				return storer.ErrStop
+			}
+
 			if c.Author.Name != "chartsutil-rebase" {
 				cherryPickCommits = append(cherryPickCommits, c.Hash.String())
 			}
@@ -433,5 +620,12 @@ func (r *Rebase) Rebase() error {
 
 	}
 
+	skipped := make([]string, len(r.skipped))
+	for i, upstream := range r.skipped {
+		skipped[i] = GetRelaventUpstreamChange(upstream)
+	}
+
+	r.Logger.Info("rebase complete", "upstreams", r.step.Index, "skipped", skipped)
+
 	return nil
 }
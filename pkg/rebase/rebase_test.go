@@ -1,118 +1,59 @@
 package rebase_test
 
 import (
-	"errors"
-	"fmt"
 	"log/slog"
 	"os"
-	"path/filepath"
 	"testing"
 
 	"github.com/go-git/go-billy/v5"
 	"github.com/go-git/go-git/v5"
-	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/joshmeranda/chartsutil/pkg/fixture"
 	"github.com/joshmeranda/chartsutil/pkg/iter"
 	"github.com/joshmeranda/chartsutil/pkg/rebase"
 	"github.com/joshmeranda/chartsutil/pkg/resolve"
-	cp "github.com/otiai10/copy"
 	"github.com/rancher/charts-build-scripts/pkg/charts"
-	"github.com/rancher/charts-build-scripts/pkg/filesystem"
-	chartspath "github.com/rancher/charts-build-scripts/pkg/path"
-)
-
-const (
-	RebaseExampleUrl = "https://github.com/joshmeranda/chartsutil-example"
-
-	RebaseExampleUpstreamUrl = "https://github.com/joshmeranda/chartsutil-example-upstream"
-
-	CacheDir = ".test-cache"
-
-	RebaseExampleCommit = "101ce93f3a15c8122e3bb582e07b9f6648fbafc3"
+	"github.com/rancher/charts-build-scripts/pkg/options"
 )
 
 var logger *slog.Logger
 
 func init() {
-	if err := os.MkdirAll(CacheDir, 0755); err != nil {
-		panic(fmt.Sprintf("failed to create cache dir: %v", err))
-	}
-
 	// logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
 	logger = slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{}))
 }
 
-func cloneChartsTo(path string) (*git.Repository, error) {
-	cloneDir := filepath.Join(CacheDir, "chartsutil-example")
-
-	if _, err := os.Stat(cloneDir); errors.Is(err, os.ErrNotExist) {
-		cloneOpts := &git.CloneOptions{
-			URL: RebaseExampleUrl,
-		}
-
-		if _, err := git.PlainClone(cloneDir, false, cloneOpts); err != nil {
-			return nil, err
-		}
-	}
+// newUpstream creates an upstream with a chart at its root which is changed by each tagged commit.
+func newUpstream(t *testing.T) *fixture.Upstream {
+	upstream := fixture.NewUpstream(t)
 
-	if err := cp.Copy(cloneDir, path); err != nil {
-		return nil, fmt.Errorf("failed to copy charts dir")
-	}
+	upstream.CommitFiles("v0.0.1", fixture.Chart("", "example", "0.0.1"))
+	upstream.CommitContent("v0.0.2", "values.yaml", "replicas: 2\nimage:\n  repository: nginx\n  tag: latest\n", "v0.0.1")
+	upstream.CommitContent("v0.0.3", "templates/configmap.yaml", "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: {{ .Release.Name }}\n", "v0.0.2")
+	upstream.CommitContent("v0.1.0", "Chart.yaml", "apiVersion: v2\nname: example\nversion: 0.1.0\nappVersion: 0.1.0\n", "v0.0.3")
 
-	repo, err := git.PlainOpen(path)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open git repo: %v", err)
+	for _, name := range []string{"v0.0.1", "v0.0.2", "v0.0.3", "v0.1.0"} {
+		upstream.Tag(name, name)
 	}
+	upstream.Branch("main", "v0.1.0")
 
-	wt, err := repo.Worktree()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get worktree: %v", err)
-	}
-
-	opts := git.CheckoutOptions{
-		Hash: plumbing.NewHash(RebaseExampleCommit),
-	}
-
-	if err := wt.Checkout(&opts); err != nil {
-		return nil, fmt.Errorf("failed to checkout commit: %v", err)
-	}
-
-	return repo, nil
+	return upstream
 }
 
-func setupRebase(t *testing.T, pkgName string) (string, *slog.Logger, *git.Repository, *charts.Package, billy.Filesystem, billy.Filesystem) {
-	chartsDir, err := os.MkdirTemp(".", "chartsutil-test-"+t.Name()+"-*")
-	if err != nil {
-		t.Fatalf("failed to create temp dir: %v", err)
-	}
+// setupRebase creates a charts repository with a package tracking the upstream at v0.0.1, whose changes to the values conflict with v0.0.2.
+func setupRebase(t *testing.T, pkgName string, upstream options.UpstreamOptions) (*fixture.Charts, *slog.Logger, *charts.Package, billy.Filesystem) {
+	c := fixture.NewCharts(t)
 
-	t.Cleanup(func() {
-		if !t.Failed() {
-			if err := os.RemoveAll(chartsDir); err != nil {
-				t.Fatalf("failed to remove temp dir: %v", err)
-			}
-		}
+	c.AddPackage(pkgName, upstream, map[string]string{
+		"values.yaml": "replicas: 3\nimage:\n  repository: rancher/mirrored-nginx\n  tag: latest\n",
 	})
 
-	repo, err := cloneChartsTo(chartsDir)
-	if err != nil {
-		t.Fatalf("failed to clone charts: %v", err)
-	}
+	pkg, pkgFs := c.Package(pkgName)
 
-	rootFs := filesystem.GetFilesystem(chartsDir)
-	pkgFs, err := rootFs.Chroot(filepath.Join(chartspath.RepositoryPackagesDir, pkgName))
-	if err != nil {
-		t.Fatalf("failed to chroot to package dir: %v", err)
-	}
-
-	pkg, err := charts.GetPackage(rootFs, pkgName)
-	if err != nil {
-		t.Fatalf("failed to get package: %v", err)
-	}
-
-	return chartsDir, logger.WithGroup(t.Name()), repo, pkg, rootFs, pkgFs
+	return c, logger.WithGroup(t.Name()), pkg, pkgFs
 }
 
-func assertPackageMessage(t *testing.T, repo *git.Repository, message string) {
+// assertRebaseMessage checks the message of the commit updating the package patches, which is made after the package.yaml is updated.
+func assertRebaseMessage(t *testing.T, repo *git.Repository, message string) {
 	t.Helper()
 
 	head, err := repo.Head()
@@ -130,7 +71,8 @@ func assertPackageMessage(t *testing.T, repo *git.Repository, message string) {
 	}
 }
 
-func assertRebaseMessage(t *testing.T, repo *git.Repository, message string) {
+// assertPackageMessage checks the message of the commit updating the package.yaml.
+func assertPackageMessage(t *testing.T, repo *git.Repository, message string) {
 	t.Helper()
 
 	head, err := repo.Head()
@@ -156,11 +98,14 @@ func assertRebaseMessage(t *testing.T, repo *git.Repository, message string) {
 }
 
 func TestArchive(t *testing.T) {
-	chartsDir, logger, repo, pkg, rootFs, pkgFs := setupRebase(t, "chartsutil-example-archive")
-	_ = chartsDir
+	upstream := newUpstream(t)
+
+	c, logger, pkg, pkgFs := setupRebase(t, "example-archive", options.UpstreamOptions{
+		URL: upstream.ArchiveURL("v0.0.1"),
+	})
 
 	delta := iter.UpstreamDelta{
-		URL: "https://github.com/joshmeranda/chartsutil-example-upstream/archive/refs/tags/v0.0.1.tar.gz",
+		URL: upstream.ArchiveURL("v0.1.0"),
 	}
 
 	iter, err := iter.NewSingleIter(pkg.Chart.Upstream, delta)
@@ -172,9 +117,10 @@ func TestArchive(t *testing.T) {
 		Logger:            logger,
 		Resolver:          &resolve.MergeResolver{Strategy: resolve.StrategyTheirs},
 		DisableValidators: true,
+		Cache:             c.Cache,
 	}
 
-	rb, err := rebase.NewRebase(pkg, rootFs, pkgFs, iter, opts)
+	rb, err := rebase.NewRebase(pkg, c.RootFs, pkgFs, iter, opts)
 	if err != nil {
 		t.Fatalf("failed to create rebase: %v", err)
 	}
@@ -183,13 +129,10 @@ func TestArchive(t *testing.T) {
 		t.Fatalf("failed to rebase: %v", err)
 	}
 
-	assertPackageMessage(t, repo, "Update package.yaml")
-	assertRebaseMessage(t, repo, "Updating chartsutil-example-archive to new base "+delta.URL)
+	assertPackageMessage(t, c.Repo, "Update package.yaml")
+	assertRebaseMessage(t, c.Repo, "Updating example-archive to new base "+delta.URL)
 
-	pkg, err = charts.GetPackage(rootFs, pkg.Name)
-	if err != nil {
-		t.Fatalf("failed to get package: %v", err)
-	}
+	pkg, _ = c.Package(pkg.Name)
 
 	if pkg.Chart.Upstream.GetOptions().URL != delta.URL {
 		t.Errorf("commit does not match expected value:\nExpected: '%s'\n   Found: '%s'", delta.URL, pkg.Chart.Upstream.GetOptions().URL)
@@ -197,26 +140,33 @@ func TestArchive(t *testing.T) {
 }
 
 func TestGitIncremental(t *testing.T) {
-	chartsDir, logger, repo, pkg, rootFs, pkgFs := setupRebase(t, "chartsutil-example")
-	_ = chartsDir
+	upstream := newUpstream(t)
 
-	newCommit := "933d8b2975efa50cda4dca6234e5e522b8f58cdc"
+	c, logger, pkg, pkgFs := setupRebase(t, "example", options.UpstreamOptions{
+		URL:    upstream.Dir,
+		Commit: rebase.ToPtr(upstream.Hash("v0.0.1").String()),
+	})
+
+	newCommit := upstream.Hash("v0.1.0").String()
 	delta := iter.UpstreamDelta{
 		Commit: &newCommit,
 	}
 
-	iter, err := iter.NewGitIter(pkg.Chart.Upstream.GetOptions(), delta)
+	gitIter, err := iter.NewGitIter(pkg.Chart.Upstream.GetOptions(), delta)
 	if err != nil {
 		t.Fatalf("failed to create git iterator: %v", err)
 	}
 
+	gitIter.Cache = c.Cache
+
 	opts := rebase.Options{
 		Logger:            logger,
-		Resolver:          &resolve.Blind{},
+		Resolver:          &resolve.MergeResolver{Strategy: resolve.StrategyTheirs},
 		DisableValidators: true,
+		Cache:             c.Cache,
 	}
 
-	rb, err := rebase.NewRebase(pkg, rootFs, pkgFs, iter, opts)
+	rb, err := rebase.NewRebase(pkg, c.RootFs, pkgFs, gitIter, opts)
 	if err != nil {
 		t.Fatalf("failed to create rebase: %v", err)
 	}
@@ -225,24 +175,101 @@ func TestGitIncremental(t *testing.T) {
 		t.Fatalf("failed to rebase: %v", err)
 	}
 
-	assertPackageMessage(t, repo, "Update package.yaml")
-	assertRebaseMessage(t, repo, "Updating chartsutil-example to new base 933d8b2975efa50cda4dca6234e5e522b8f58cdc")
+	assertPackageMessage(t, c.Repo, "Update package.yaml")
+	assertRebaseMessage(t, c.Repo, "Updating example to new base "+newCommit)
 
-	pkg, err = charts.GetPackage(rootFs, pkg.Name)
-	if err != nil {
-		t.Fatalf("failed to get package: %v", err)
-	}
+	pkg, _ = c.Package(pkg.Name)
 
 	if *pkg.Chart.Upstream.GetOptions().Commit != *delta.Commit {
 		t.Errorf("commit does not match expected value:\nExpected: '%s'\n   Found: '%s'", *delta.Commit, *pkg.Chart.Upstream.GetOptions().Commit)
 	}
+
+	if url := pkg.Chart.Upstream.GetOptions().URL; url != upstream.Dir {
+		t.Errorf("url does not match expected value:\nExpected: '%s'\n   Found: '%s'", upstream.Dir, url)
+	}
+}
+
+// skippingResolver skips the first upstream and resolves the rest with theirs, recording each step it was given.
+type skippingResolver struct {
+	resolve.MergeResolver
+
+	steps map[int]resolve.Step
+	step  resolve.Step
+}
+
+func (s *skippingResolver) SetStep(step resolve.Step) {
+	s.step = step
+	s.steps[step.Index] = step
+}
+
+func (s *skippingResolver) Resolve(wt *git.Worktree) error {
+	if s.step.Index == 1 {
+		return resolve.ErrSkip
+	}
+
+	return s.MergeResolver.Resolve(wt)
+}
+
+func TestGitIncrementalSkip(t *testing.T) {
+	upstream := newUpstream(t)
+
+	c, logger, pkg, pkgFs := setupRebase(t, "example", options.UpstreamOptions{
+		URL:    upstream.Dir,
+		Commit: rebase.ToPtr(upstream.Hash("v0.0.1").String()),
+	})
+
+	gitIter, err := iter.NewGitIter(pkg.Chart.Upstream.GetOptions(), iter.UpstreamDelta{
+		Commit: rebase.ToPtr(upstream.Hash("v0.1.0").String()),
+	})
+	if err != nil {
+		t.Fatalf("failed to create git iterator: %v", err)
+	}
+
+	gitIter.Cache = c.Cache
+
+	resolver := &skippingResolver{
+		MergeResolver: resolve.MergeResolver{Strategy: resolve.StrategyTheirs},
+		steps:         make(map[int]resolve.Step),
+	}
+
+	rb, err := rebase.NewRebase(pkg, c.RootFs, pkgFs, gitIter, rebase.Options{
+		Logger:            logger,
+		Resolver:          resolver,
+		DisableValidators: true,
+		Cache:             c.Cache,
+	})
+	if err != nil {
+		t.Fatalf("failed to create rebase: %v", err)
+	}
+
+	if err := rb.Rebase(); err != nil {
+		t.Fatalf("failed to rebase: %v", err)
+	}
+
+	skipped, next := resolver.steps[1], resolver.steps[2]
+
+	if !skipped.Skippable {
+		t.Errorf("expected first upstream to be skippable")
+	}
+
+	if next.PreviousUpstreamCommit != skipped.PreviousUpstreamCommit {
+		t.Errorf("expected upstream after skip to be diffed against '%s' but found '%s'", skipped.PreviousUpstreamCommit, next.PreviousUpstreamCommit)
+	}
+
+	if last := resolver.steps[len(resolver.steps)]; last.Skippable {
+		t.Errorf("expected last upstream to not be skippable")
+	}
 }
 
 func TestGitNonIncremental(t *testing.T) {
-	chartsDir, logger, repo, pkg, rootFs, pkgFs := setupRebase(t, "chartsutil-example")
-	_ = chartsDir
+	upstream := newUpstream(t)
 
-	newCommit := "933d8b2975efa50cda4dca6234e5e522b8f58cdc"
+	c, logger, pkg, pkgFs := setupRebase(t, "example", options.UpstreamOptions{
+		URL:    upstream.Dir,
+		Commit: rebase.ToPtr(upstream.Hash("v0.0.1").String()),
+	})
+
+	newCommit := upstream.Hash("v0.1.0").String()
 	delta := iter.UpstreamDelta{
 		Commit: &newCommit,
 	}
@@ -254,11 +281,12 @@ func TestGitNonIncremental(t *testing.T) {
 
 	opts := rebase.Options{
 		Logger:            logger,
-		Resolver:          &resolve.Blind{},
+		Resolver:          &resolve.MergeResolver{Strategy: resolve.StrategyTheirs},
 		DisableValidators: true,
+		Cache:             c.Cache,
 	}
 
-	rb, err := rebase.NewRebase(pkg, rootFs, pkgFs, iter, opts)
+	rb, err := rebase.NewRebase(pkg, c.RootFs, pkgFs, iter, opts)
 	if err != nil {
 		t.Fatalf("failed to create rebase: %v", err)
 	}
@@ -267,13 +295,10 @@ func TestGitNonIncremental(t *testing.T) {
 		t.Fatalf("failed to rebase: %v", err)
 	}
 
-	assertPackageMessage(t, repo, "Update package.yaml")
-	assertRebaseMessage(t, repo, "Updating chartsutil-example to new base 933d8b2975efa50cda4dca6234e5e522b8f58cdc")
+	assertPackageMessage(t, c.Repo, "Update package.yaml")
+	assertRebaseMessage(t, c.Repo, "Updating example to new base "+newCommit)
 
-	pkg, err = charts.GetPackage(rootFs, pkg.Name)
-	if err != nil {
-		t.Fatalf("failed to get package: %v", err)
-	}
+	pkg, _ = c.Package(pkg.Name)
 
 	if *pkg.Chart.Upstream.GetOptions().Commit != *delta.Commit {
 		t.Errorf("commit does not match expected value:\nExpected: '%s'\n   Found: '%s'", *delta.Commit, *pkg.Chart.Upstream.GetOptions().Commit)
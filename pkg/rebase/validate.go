@@ -37,6 +37,21 @@ type PackageValidateFunc func(*charts.Package, *git.Worktree, billy.Filesystem)
 // ChartValidateFunc is a function that verifies a chart using the provided filesystem.
 type ChartValidateFunc func(string) error
 
+// DefaultValidators returns the validators run against the worktree after each upstream is resolved. If imageNamespace is empty, chart images are not checked.
+func DefaultValidators(imageNamespace string) []PackageValidateFunc {
+	validators := []PackageValidateFunc{
+		ValidateWorktree,
+		ValidatePatternNotFoundFactory("<<<<<<< HEAD"),
+		ValidateHelmLint,
+	}
+
+	if imageNamespace != "" {
+		validators = append(validators, ValidateImagesInNamespaceFactory(imageNamespace))
+	}
+
+	return validators
+}
+
 func ForEachChart(pkg *charts.Package, pkgFs billy.Filesystem, fn ChartValidateFunc) error {
 	if err := fn(filepath.Join(pkgFs.Root(), pkg.WorkingDir)); err != nil {
 		return err
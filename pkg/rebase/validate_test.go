@@ -3,17 +3,16 @@ package rebase_test
 import (
 	"bytes"
 	"errors"
-	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
 
 	"github.com/go-git/go-billy/v5"
 	"github.com/go-git/go-git/v5"
-	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/joshmeranda/chartsutil/pkg/fixture"
 	"github.com/joshmeranda/chartsutil/pkg/rebase"
 	"github.com/rancher/charts-build-scripts/pkg/charts"
-	"github.com/rancher/charts-build-scripts/pkg/filesystem"
+	"github.com/rancher/charts-build-scripts/pkg/options"
 	chartspath "github.com/rancher/charts-build-scripts/pkg/path"
 )
 
@@ -22,47 +21,20 @@ const (
 )
 
 func setupVerify(t *testing.T, pkgName string) (*charts.Package, *git.Worktree, billy.Filesystem) {
-	chartsDir := fmt.Sprintf("%s-charts", t.Name())
-	t.Cleanup(func() {
-		if err := os.RemoveAll(chartsDir); err != nil {
-			t.Fatalf("failed to remove temp dir: %v", err)
-		}
-	})
-
-	_, err := cloneChartsTo(chartsDir)
-	if err != nil {
-		t.Fatalf("failed to clone charts: %v", err)
-	}
+	upstream := fixture.NewUpstream(t)
+	upstream.CommitFiles("v0.0.1", fixture.Chart("", "example", "0.0.1"))
+	upstream.Branch("main", "v0.0.1")
 
-	rootFs := filesystem.GetFilesystem(chartsDir)
-	pkgFs, err := rootFs.Chroot(filepath.Join(chartspath.RepositoryPackagesDir, pkgName))
-	if err != nil {
-		t.Fatalf("failed to chroot to package dir: %v", err)
-	}
+	c := fixture.NewCharts(t)
+	c.AddPackage(pkgName, options.UpstreamOptions{URL: upstream.ArchiveURL("v0.0.1")}, nil)
 
-	pkg, err := charts.GetPackage(rootFs, pkgName)
-	if err != nil {
-		t.Fatalf("failed to get package: %v", err)
-	}
+	pkg, pkgFs := c.Package(pkgName)
 
-	repo, err := git.PlainOpen(chartsDir)
-	if err != nil {
-		t.Fatalf("failed to open git repo: %v", err)
-	}
-
-	wt, err := repo.Worktree()
+	wt, err := c.Repo.Worktree()
 	if err != nil {
 		t.Fatalf("failed to get worktree: %v", err)
 	}
 
-	opts := git.CheckoutOptions{
-		Hash: plumbing.NewHash(RebaseExampleCommit),
-	}
-
-	if err := wt.Checkout(&opts); err != nil {
-		t.Fatalf("failed to checkout commit: %v", err)
-	}
-
 	if err := pkg.Prepare(); err != nil {
 		t.Fatalf("failed to prepare package: %v", err)
 	}
@@ -95,7 +67,7 @@ func replaceInValues(chartPath string, oldValue string, newValue string) error {
 }
 
 func TestValidateHelmLint(t *testing.T) {
-	pkg, wt, pkgFs := setupVerify(t, "chartsutil-example-archive")
+	pkg, wt, pkgFs := setupVerify(t, "example-archive")
 
 	if err := rebase.ValidateHelmLint(pkg, wt, pkgFs); err != nil {
 		t.Fatalf("failed to verify helm template: %v", err)
@@ -115,7 +87,7 @@ func TestValidateHelmLint(t *testing.T) {
 }
 
 func TestValidatePatternNotFound(t *testing.T) {
-	pkg, wt, pkgFs := setupVerify(t, "chartsutil-example-archive")
+	pkg, wt, pkgFs := setupVerify(t, "example-archive")
 
 	validateFunc := rebase.ValidatePatternNotFoundFactory(".something.bad")
 
@@ -135,7 +107,7 @@ func TestValidatePatternNotFound(t *testing.T) {
 }
 
 func TestValidateImagesInNamespaceFactory(t *testing.T) {
-	pkg, wt, pkgFs := setupVerify(t, "chartsutil-example-archive")
+	pkg, wt, pkgFs := setupVerify(t, "example-archive")
 
 	validateFunc := rebase.ValidateImagesInNamespaceFactory("rancher")
 
@@ -155,7 +127,7 @@ func TestValidateImagesInNamespaceFactory(t *testing.T) {
 }
 
 func TestValidateWorktree(t *testing.T) {
-	pkg, wt, pkgFs := setupVerify(t, "chartsutil-example-archive")
+	pkg, wt, pkgFs := setupVerify(t, "example-archive")
 	// chartPath := filepath.Join(pkgFs.Root(), pkg.WorkingDir)
 
 	if _, err := wt.Add(chartspath.RepositoryPackagesDir); err != nil {
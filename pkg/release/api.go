@@ -0,0 +1,73 @@
+package release
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+var (
+	// errNotFound is returned by get for 404 responses.
+	errNotFound = errors.New("not found")
+
+	// errUnauthorized is returned by get for 401 responses.
+	errUnauthorized = errors.New("unauthorized")
+)
+
+// get returns the body of the response to a GET request for url, and the response headers.
+func get(ctx context.Context, client *http.Client, url string, header http.Header) ([]byte, http.Header, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return nil, resp.Header, fmt.Errorf("%w: '%s'", errNotFound, url)
+	case http.StatusUnauthorized:
+		return nil, resp.Header, fmt.Errorf("%w: '%s'", errUnauthorized, url)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, resp.Header, fmt.Errorf("unexpected status '%s' from '%s': %s", resp.Status, url, body)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, resp.Header, fmt.Errorf("failed to read response from '%s': %w", url, err)
+	}
+
+	return body, resp.Header, nil
+}
+
+// getJSON decodes the json response to a GET request for url into v, returning the response headers.
+func getJSON(ctx context.Context, client *http.Client, url string, v any) (http.Header, error) {
+	body, header, err := get(ctx, client, url, http.Header{"Accept": {"application/json"}})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(body, v); err != nil {
+		return nil, fmt.Errorf("failed to decode response from '%s': %w", url, err)
+	}
+
+	return header, nil
+}
@@ -0,0 +1,277 @@
+package release
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/joshmeranda/chartsutil/pkg/cache"
+	"github.com/joshmeranda/chartsutil/pkg/iter"
+)
+
+// GitSource treats the tags of any git upstream as its releases. Tags are listed from the remote (like 'git ls-remote --tags') so tags deleted from the upstream are not reported from a stale clone, and dated using the cached clone.
+type GitSource struct {
+	URL string
+
+	// Repo is an open clone of the upstream, if nil the upstream is opened from Cache.
+	Repo *git.Repository
+
+	// Cache holds the clone of the upstream when Repo is nil, if nil the default cache is used.
+	Cache *cache.Cache
+}
+
+// withRepo calls f with the clone of the upstream.
+func (s *GitSource) withRepo(ctx context.Context, f func(repo *git.Repository) error) error {
+	if s.Repo != nil {
+		return f(s.Repo)
+	}
+
+	c, err := cache.OrDefault(s.Cache)
+	if err != nil {
+		return fmt.Errorf("failed to get upstream cache: %w", err)
+	}
+
+	cached, err := c.Repo(ctx, s.URL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get cached repository: %w", err)
+	}
+	defer cached.Close()
+
+	return f(cached.Repository)
+}
+
+// lsRemoteTags lists the names of the tags on the upstream.
+func (s *GitSource) lsRemoteTags(ctx context.Context) ([]string, error) {
+	remote := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: "origin",
+		URLs: []string{s.URL},
+	})
+
+	listOpts := &git.ListOptions{}
+
+	if s.Cache != nil && s.Cache.Auth != nil {
+		auth, err := s.Cache.Auth(s.URL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get auth for '%s': %w", s.URL, err)
+		}
+
+		listOpts.Auth = auth
+	}
+
+	refs, err := remote.ListContext(ctx, listOpts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list upstream refs: %w", err)
+	}
+
+	tags := make([]string, 0)
+	for _, ref := range refs {
+		if ref.Name().IsTag() {
+			tags = append(tags, ref.Name().Short())
+		}
+	}
+
+	return tags, nil
+}
+
+func (s *GitSource) ListReleases(ctx context.Context) ([]Release, error) {
+	tags, err := s.lsRemoteTags(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	releases := make([]Release, 0, len(tags))
+
+	err = s.withRepo(ctx, func(repo *git.Repository) error {
+		for _, tag := range tags {
+			release, err := tagRelease(repo, tag)
+			if errors.Is(err, errNotCommit) {
+				continue
+			} else if err != nil {
+				return err
+			}
+
+			releases = append(releases, release)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortStableFunc(releases, func(a, b Release) int {
+		return b.Date.Compare(a.Date)
+	})
+
+	if len(releases) > MaxAvailableReleases {
+		releases = releases[:MaxAvailableReleases]
+	}
+
+	return releases, nil
+}
+
+func (s *GitSource) GetRelease(ctx context.Context, tag string) (Release, error) {
+	var release Release
+
+	err := s.withRepo(ctx, func(repo *git.Repository) (err error) {
+		release, err = tagRelease(repo, tag)
+		return err
+	})
+
+	return release, err
+}
+
+// errNotCommit is returned for tags which do not point to a commit.
+var errNotCommit = errors.New("tag does not point to a commit")
+
+// tagRelease creates a release for the tag, dated by the tagger of annotated tags or the committer of lightweight tags.
+func tagRelease(repo *git.Repository, tag string) (Release, error) {
+	ref, err := repo.Tag(tag)
+	if err != nil {
+		return Release{}, fmt.Errorf("failed to find tag '%s': %w", tag, err)
+	}
+
+	commit, err := peelTag(repo, ref)
+	if err != nil {
+		return Release{}, err
+	}
+
+	release := Release{
+		Name: tag,
+		Tag:  tag,
+		Date: commit.Committer.When,
+		Hash: commit.Hash.String(),
+	}
+
+	if tagObj, err := repo.TagObject(ref.Hash()); err == nil {
+		release.Date = tagObj.Tagger.When
+		release.Body = tagObj.Message
+	}
+
+	return release, nil
+}
+
+// peelTag returns the commit pointed to by a lightweight or annotated tag.
+func peelTag(repo *git.Repository, ref *plumbing.Reference) (*object.Commit, error) {
+	tag := ref.Name().Short()
+
+	if tagObj, err := repo.TagObject(ref.Hash()); err == nil {
+		commit, err := tagObj.Commit()
+		if errors.Is(err, object.ErrUnsupportedObject) {
+			return nil, fmt.Errorf("%w: %s", errNotCommit, tag)
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to get commit for tag '%s': %w", tag, err)
+		}
+
+		return commit, nil
+	} else if !errors.Is(err, plumbing.ErrObjectNotFound) {
+		return nil, fmt.Errorf("failed to read tag '%s': %w", tag, err)
+	}
+
+	commit, err := repo.CommitObject(ref.Hash())
+	if errors.Is(err, plumbing.ErrObjectNotFound) {
+		return nil, fmt.Errorf("%w: %s", errNotCommit, tag)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get commit for tag '%s': %w", tag, err)
+	}
+
+	return commit, nil
+}
+
+// TagsAt returns the names of the lightweight and annotated tags pointing to the commit.
+func TagsAt(repo *git.Repository, hash plumbing.Hash) ([]string, error) {
+	refs, err := repo.Tags()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+	defer refs.Close()
+
+	tags := make([]string, 0)
+
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		target := ref.Hash()
+
+		if tagObj, err := repo.TagObject(target); err == nil {
+			if tagObj.TargetType != plumbing.CommitObject {
+				return nil
+			}
+
+			target = tagObj.Target
+		}
+
+		if target == hash {
+			tags = append(tags, ref.Name().Short())
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find tags for '%s': %w", hash, err)
+	}
+
+	return tags, nil
+}
+
+// VersionAt returns the version of the upstream at ref (ex. a tag, branch or commit), from the highest version among the tags matching pattern which point to its commit, or else the version of the chart in subdirectory. Returns nil if neither has a version.
+func VersionAt(repo *git.Repository, ref string, subdirectory string, pattern *regexp.Regexp) (*semver.Version, error) {
+	hash, err := iter.ResolveRef(repo, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := TagsAt(repo, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	if tag := LatestTag(tags, pattern); tag != "" {
+		return ParseVersion(tag), nil
+	}
+
+	commit, err := repo.CommitObject(hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find commit for '%s': %w", hash, err)
+	}
+
+	chart, err := iter.ReadChartMetadata(commit, subdirectory)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chart at '%s': %w", hash, err)
+	}
+
+	return ParseVersion(chart.Version), nil
+}
+
+// ExcludeAncestors removes the releases whose commit is the commit at hash or one of its ancestors, which the upstream at hash already has. Releases are compared by commit rather than date since they may be tagged long after the commit they were cut from. Releases without a commit are kept if they were published after since.
+func ExcludeAncestors(repo *git.Repository, hash plumbing.Hash, since time.Time, releases []Release) ([]Release, error) {
+	commits, err := repo.Log(&git.LogOptions{From: hash})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk history of '%s': %w", hash, err)
+	}
+	defer commits.Close()
+
+	ancestors := make(map[string]bool)
+
+	if err := commits.ForEach(func(c *object.Commit) error {
+		ancestors[c.Hash.String()] = true
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed to walk history of '%s': %w", hash, err)
+	}
+
+	return slices.DeleteFunc(releases, func(r Release) bool {
+		if !plumbing.IsHash(r.Hash) {
+			return !r.Date.After(since)
+		}
+
+		return ancestors[r.Hash]
+	}), nil
+}
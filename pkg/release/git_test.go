@@ -0,0 +1,196 @@
+package release_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/joshmeranda/chartsutil/pkg/cache"
+	"github.com/joshmeranda/chartsutil/pkg/fixture"
+	"github.com/joshmeranda/chartsutil/pkg/iter"
+	"github.com/joshmeranda/chartsutil/pkg/release"
+)
+
+func TestGitSource(t *testing.T) {
+	upstream := fixture.NewUpstream(t)
+	upstream.Interval = time.Hour
+
+	upstream.Commit("A", "Chart.yaml")
+	upstream.Commit("B", "Chart.yaml", "A")
+	upstream.Commit("C", "Chart.yaml", "B")
+	upstream.Branch("main", "C")
+
+	upstream.Tag("v1.0.0", "A")
+	upstream.Tag("not-a-release", "B")
+	upstream.AnnotatedTag("v1.1.0", "B", "release v1.1.0")
+
+	source := &release.GitSource{
+		URL:   upstream.Dir,
+		Cache: &cache.Cache{Root: t.TempDir()},
+	}
+
+	query := release.ReleaseQuery{
+		NamePattern: regexp.MustCompile("^" + release.DefaultReleaseNamePattern + "$"),
+	}
+
+	releases, err := release.ReleasesForUpstream(context.Background(), source, query)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the annotated tag is dated by its tagger, which is after every commit
+	expected := []release.Release{
+		{Name: "v1.1.0", Tag: "v1.1.0", Date: time.Unix(0, 0).Add(3 * time.Hour), Hash: upstream.Hash("B").String()},
+		{Name: "v1.0.0", Tag: "v1.0.0", Date: time.Unix(0, 0).Add(time.Hour), Hash: upstream.Hash("A").String()},
+	}
+
+	assertReleases(t, expected, releases)
+
+	current, err := source.GetRelease(context.Background(), "v1.0.0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	assertReleases(t, expected[1:], []release.Release{current})
+
+	if _, err := source.GetRelease(context.Background(), "v2.0.0"); err == nil {
+		t.Errorf("expected error for missing tag")
+	}
+}
+
+func TestTagsAt(t *testing.T) {
+	upstream := fixture.NewUpstream(t)
+
+	upstream.Commit("A", "Chart.yaml")
+	upstream.Commit("B", "Chart.yaml", "A")
+
+	upstream.Tag("v1.0.0", "A")
+	upstream.AnnotatedTag("chart-1.0.0", "A", "release chart-1.0.0")
+	upstream.Tag("v1.1.0", "B")
+
+	tags, err := release.TagsAt(upstream.Repo, upstream.Hash("A"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	slices.Sort(tags)
+
+	if expected := []string{"chart-1.0.0", "v1.0.0"}; !slices.Equal(expected, tags) {
+		t.Errorf("expected tags %v but found %v", expected, tags)
+	}
+}
+
+func TestVersionAt(t *testing.T) {
+	upstream := fixture.NewUpstream(t)
+
+	upstream.CommitFiles("A", fixture.Chart("charts/example", "example", "1.0.0"))
+	upstream.CommitContent("B", "charts/example/values.yaml", "replicas: 2\n", "A")
+	upstream.CommitContent("C", "README.md", "example\n", "B")
+
+	upstream.Tag("v1.0.0", "A")
+	upstream.Tag("release-42", "B")
+	upstream.Tag("v1.1.0", "B")
+	upstream.Branch("main", "C")
+
+	pattern := regexp.MustCompile("^" + release.DefaultReleaseNamePattern + "$")
+
+	type Case struct {
+		Name     string
+		Ref      string
+		Expected string
+	}
+
+	cases := []Case{
+		{
+			Name:     "VersionTag",
+			Ref:      "v1.1.0",
+			Expected: "1.1.0",
+		},
+		{
+			Name:     "NonVersionTag",
+			Ref:      "release-42",
+			Expected: "1.1.0",
+		},
+		{
+			Name:     "Commit",
+			Ref:      upstream.Hash("B").String(),
+			Expected: "1.1.0",
+		},
+		{
+			Name:     "BranchChartVersion",
+			Ref:      "main",
+			Expected: "1.0.0",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			actual, err := release.VersionAt(upstream.Repo, c.Ref, "charts/example", pattern)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if actual == nil || actual.String() != c.Expected {
+				t.Errorf("expected version %s but found %v", c.Expected, actual)
+			}
+		})
+	}
+
+	t.Run("NoVersion", func(t *testing.T) {
+		actual, err := release.VersionAt(upstream.Repo, "main", "charts/missing", pattern)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if actual != nil {
+			t.Errorf("expected no version but found %s", actual)
+		}
+	})
+
+	t.Run("UnknownRef", func(t *testing.T) {
+		if _, err := release.VersionAt(upstream.Repo, "1.2.0", "charts/example", pattern); !errors.Is(err, iter.ErrUnknownRef) {
+			t.Errorf("expected unknown ref error but found: %v", err)
+		}
+	})
+}
+
+func TestExcludeAncestors(t *testing.T) {
+	upstream := fixture.NewUpstream(t)
+
+	upstream.Commit("A", "Chart.yaml")
+	upstream.Commit("B", "Chart.yaml", "A")
+	upstream.Commit("C", "Chart.yaml", "B")
+	upstream.Commit("D", "Chart.yaml", "C")
+
+	// a patch release cut from an older commit on another branch
+	upstream.Commit("A1", "Chart.yaml", "A")
+
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	releases := []release.Release{
+		{Name: "v1.0.0", Hash: upstream.Hash("A").String()},
+		{Name: "v1.1.0", Hash: upstream.Hash("B").String()},
+		{Name: "v1.2.0", Hash: upstream.Hash("C").String()},
+		{Name: "v1.3.0", Hash: upstream.Hash("D").String()},
+		{Name: "v1.0.1", Hash: upstream.Hash("A1").String()},
+		{Name: "v0.9.0", Date: since.Add(-time.Hour)},
+		{Name: "v2.0.0", Date: since.Add(time.Hour)},
+	}
+
+	actual, err := release.ExcludeAncestors(upstream.Repo, upstream.Hash("C"), since, releases)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	names := make([]string, 0, len(actual))
+	for _, r := range actual {
+		names = append(names, r.Name)
+	}
+
+	if expected := []string{"v1.3.0", "v1.0.1", "v2.0.0"}; !slices.Equal(expected, names) {
+		t.Errorf("expected releases %v but found %v", expected, names)
+	}
+}
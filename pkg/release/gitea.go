@@ -0,0 +1,106 @@
+package release
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/joshmeranda/chartsutil/pkg/auth"
+)
+
+// giteaPerPage is the default maximum page size of the Gitea api.
+const giteaPerPage = 50
+
+// GiteaSource lists the releases of a Gitea or Forgejo repository.
+type GiteaSource struct {
+	// Client is used for requests to the api, if nil http.DefaultClient is used.
+	Client *http.Client
+
+	// BaseURL is the url of the v1 api (ex. https://codeberg.org/api/v1).
+	BaseURL string
+
+	Ref RepoRef
+}
+
+type giteaRelease struct {
+	Name            string    `json:"name"`
+	TagName         string    `json:"tag_name"`
+	TargetCommitish string    `json:"target_commitish"`
+	CreatedAt       time.Time `json:"created_at"`
+	PublishedAt     time.Time `json:"published_at"`
+	Body            string    `json:"body"`
+	Draft           bool      `json:"draft"`
+	Prerelease      bool      `json:"prerelease"`
+}
+
+func (r giteaRelease) release() Release {
+	release := Release{
+		Name: r.Name,
+		Tag:  r.TagName,
+		Date: r.PublishedAt,
+		Hash: r.TargetCommitish,
+		Body: r.Body,
+
+		Draft:      r.Draft,
+		Prerelease: r.Prerelease,
+	}
+
+	if release.Name == "" {
+		release.Name = release.Tag
+	}
+
+	if release.Date.IsZero() {
+		release.Date = r.CreatedAt
+	}
+
+	return release
+}
+
+func (s *GiteaSource) repoUrl() string {
+	return fmt.Sprintf("%s/repos/%s/%s", strings.TrimSuffix(s.BaseURL, "/"), url.PathEscape(s.Ref.Owner), url.PathEscape(s.Ref.Name))
+}
+
+// authContext has any credentials for the api sent as a token, since the Gitea api expects tokens in a token Authorization header.
+func (s *GiteaSource) authContext(ctx context.Context) context.Context {
+	return auth.WithScheme(ctx, auth.SchemeToken)
+}
+
+func (s *GiteaSource) ListReleases(ctx context.Context) ([]Release, error) {
+	ctx = s.authContext(ctx)
+
+	releases := make([]Release, 0)
+
+	// the api does not report the number of pages, so stop at the first page which is not full
+	for page := 1; len(releases) < MaxAvailableReleases; page++ {
+		var batch []giteaRelease
+
+		if _, err := getJSON(ctx, s.Client, fmt.Sprintf("%s/releases?limit=%d&page=%d", s.repoUrl(), giteaPerPage, page), &batch); err != nil {
+			return nil, err
+		}
+
+		for _, r := range batch {
+			releases = append(releases, r.release())
+		}
+
+		if len(batch) < giteaPerPage {
+			break
+		}
+	}
+
+	return releases, nil
+}
+
+func (s *GiteaSource) GetRelease(ctx context.Context, tag string) (Release, error) {
+	ctx = s.authContext(ctx)
+
+	var r giteaRelease
+
+	if _, err := getJSON(ctx, s.Client, fmt.Sprintf("%s/releases/tags/%s", s.repoUrl(), url.PathEscape(tag)), &r); err != nil {
+		return Release{}, err
+	}
+
+	return r.release(), nil
+}
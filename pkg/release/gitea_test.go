@@ -0,0 +1,85 @@
+package release_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/joshmeranda/chartsutil/pkg/release"
+)
+
+func TestGiteaSource(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// a full first page of 50 releases followed by a partial page
+	all := make([]map[string]string, 52)
+	for i := range all {
+		all[i] = map[string]string{
+			"name":             fmt.Sprintf("v1.0.%d", len(all)-i-1),
+			"tag_name":         fmt.Sprintf("v1.0.%d", len(all)-i-1),
+			"target_commitish": "main",
+			"published_at":     start.AddDate(0, 0, len(all)-i-1).Format(time.RFC3339),
+		}
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/repos/joshmeranda/chartsutil/releases":
+			if r.URL.Query().Get("limit") != "50" {
+				http.Error(w, "bad limit", http.StatusBadRequest)
+				return
+			}
+
+			var page []map[string]string
+			switch r.URL.Query().Get("page") {
+			case "1":
+				page = all[:50]
+			case "2":
+				page = all[50:]
+			default:
+				page = all[:0]
+			}
+
+			json.NewEncoder(w).Encode(page)
+		case "/api/v1/repos/joshmeranda/chartsutil/releases/tags/v1.0.0":
+			json.NewEncoder(w).Encode(all[51])
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	source := &release.GiteaSource{
+		Client:  server.Client(),
+		BaseURL: server.URL + "/api/v1/",
+		Ref: release.RepoRef{
+			Host:  "codeberg.org",
+			Owner: "joshmeranda",
+			Name:  "chartsutil",
+		},
+	}
+
+	releases, err := source.ListReleases(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(releases) != len(all) {
+		t.Fatalf("expected %d releases but found %d", len(all), len(releases))
+	}
+
+	first := release.Release{Name: "v1.0.0", Tag: "v1.0.0", Date: start, Hash: "main"}
+
+	assertReleases(t, []release.Release{first}, releases[len(releases)-1:])
+
+	current, err := source.GetRelease(context.Background(), "v1.0.0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	assertReleases(t, []release.Release{first}, []release.Release{current})
+}
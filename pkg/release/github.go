@@ -0,0 +1,92 @@
+package release
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/go-github/github"
+)
+
+// GitHubSource lists the releases of a GitHub repository.
+type GitHubSource struct {
+	client *github.Client
+	ref    RepoRef
+}
+
+// NewGitHubSource creates a source using the GitHub api at baseUrl, or api.github.com if empty.
+func NewGitHubSource(httpClient *http.Client, baseUrl string, ref RepoRef) (*GitHubSource, error) {
+	client := github.NewClient(httpClient)
+
+	if baseUrl != "" {
+		if !strings.HasSuffix(baseUrl, "/") {
+			baseUrl += "/"
+		}
+
+		u, err := url.Parse(baseUrl)
+		if err != nil {
+			return nil, fmt.Errorf("invalid GitHub api url '%s': %w", baseUrl, err)
+		}
+
+		client.BaseURL = u
+	}
+
+	return &GitHubSource{client: client, ref: ref}, nil
+}
+
+func (s *GitHubSource) ListReleases(ctx context.Context) ([]Release, error) {
+	releases := make([]Release, 0)
+
+	listOpts := &github.ListOptions{
+		PerPage: 100,
+	}
+
+	for i := 1; i*listOpts.PerPage <= MaxAvailableReleases+1; i++ {
+		page, response, err := s.client.Repositories.ListReleases(ctx, s.ref.Owner, s.ref.Name, listOpts)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, release := range page {
+			releases = append(releases, githubRelease(release))
+		}
+
+		if response.NextPage == 0 {
+			break
+		}
+
+		listOpts.Page = response.NextPage
+	}
+
+	return releases, nil
+}
+
+func (s *GitHubSource) GetRelease(ctx context.Context, tag string) (Release, error) {
+	release, _, err := s.client.Repositories.GetReleaseByTag(ctx, s.ref.Owner, s.ref.Name, tag)
+	if err != nil {
+		return Release{}, err
+	}
+
+	return githubRelease(release), nil
+}
+
+func githubRelease(release *github.RepositoryRelease) Release {
+	r := Release{
+		Name: release.GetName(),
+		Tag:  release.GetTagName(),
+		Date: release.GetCreatedAt().Time,
+		Hash: release.GetTargetCommitish(),
+		Body: release.GetBody(),
+
+		Draft:      release.GetDraft(),
+		Prerelease: release.GetPrerelease(),
+	}
+
+	if r.Name == "" {
+		r.Name = r.Tag
+	}
+
+	return r
+}
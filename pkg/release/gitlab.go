@@ -0,0 +1,110 @@
+package release
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/joshmeranda/chartsutil/pkg/auth"
+)
+
+// gitlabPerPage is the maximum page size allowed by the GitLab api.
+const gitlabPerPage = 100
+
+// GitLabSource lists the releases of a GitLab project.
+type GitLabSource struct {
+	// Client is used for requests to the api, if nil http.DefaultClient is used.
+	Client *http.Client
+
+	// BaseURL is the url of the v4 api (ex. https://gitlab.com/api/v4).
+	BaseURL string
+
+	Ref RepoRef
+}
+
+type gitlabRelease struct {
+	Name        string    `json:"name"`
+	TagName     string    `json:"tag_name"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	ReleasedAt  time.Time `json:"released_at"`
+
+	// UpcomingRelease is set for releases scheduled to be published in the future.
+	UpcomingRelease bool `json:"upcoming_release"`
+
+	Commit struct {
+		ID string `json:"id"`
+	} `json:"commit"`
+}
+
+func (r gitlabRelease) release() Release {
+	release := Release{
+		Name: r.Name,
+		Tag:  r.TagName,
+		Date: r.ReleasedAt,
+		Hash: r.Commit.ID,
+		Body: r.Description,
+
+		// like drafts, upcoming releases have not been published yet
+		Draft: r.UpcomingRelease,
+	}
+
+	if release.Name == "" {
+		release.Name = release.Tag
+	}
+
+	if release.Date.IsZero() {
+		release.Date = r.CreatedAt
+	}
+
+	return release
+}
+
+// projectUrl returns the api url of the project, which is identified by its url encoded path.
+func (s *GitLabSource) projectUrl() string {
+	id := strings.ReplaceAll(url.PathEscape(s.Ref.Path()), "/", "%2F")
+	return fmt.Sprintf("%s/projects/%s", strings.TrimSuffix(s.BaseURL, "/"), id)
+}
+
+// authContext has any credentials for the api sent as a bearer token, since the GitLab api does not accept tokens with basic auth.
+func (s *GitLabSource) authContext(ctx context.Context) context.Context {
+	return auth.WithScheme(ctx, auth.SchemeBearer)
+}
+
+func (s *GitLabSource) ListReleases(ctx context.Context) ([]Release, error) {
+	ctx = s.authContext(ctx)
+
+	releases := make([]Release, 0)
+
+	for page := "1"; page != "" && len(releases) < MaxAvailableReleases; {
+		var batch []gitlabRelease
+
+		header, err := getJSON(ctx, s.Client, fmt.Sprintf("%s/releases?per_page=%d&page=%s", s.projectUrl(), gitlabPerPage, page), &batch)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, r := range batch {
+			releases = append(releases, r.release())
+		}
+
+		page = header.Get("X-Next-Page")
+	}
+
+	return releases, nil
+}
+
+func (s *GitLabSource) GetRelease(ctx context.Context, tag string) (Release, error) {
+	ctx = s.authContext(ctx)
+
+	var r gitlabRelease
+
+	if _, err := getJSON(ctx, s.Client, fmt.Sprintf("%s/releases/%s", s.projectUrl(), url.PathEscape(tag)), &r); err != nil {
+		return Release{}, err
+	}
+
+	return r.release(), nil
+}
@@ -0,0 +1,68 @@
+package release_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/joshmeranda/chartsutil/pkg/release"
+)
+
+func TestGitLabSource(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.EscapedPath() {
+		case "/api/v4/projects/group%2Fsubgroup%2Fchartsutil/releases":
+			switch r.URL.Query().Get("page") {
+			case "1":
+				w.Header().Set("X-Next-Page", "2")
+				fmt.Fprint(w, `[{"name": "Version 1.1.0", "tag_name": "v1.1.0", "created_at": "2024-03-02T00:00:00Z", "released_at": "2024-03-01T00:00:00Z", "commit": {"id": "bbbb"}}]`)
+			case "2":
+				w.Header().Set("X-Next-Page", "")
+				fmt.Fprint(w, `[{"name": "", "tag_name": "v1.0.0", "created_at": "2024-02-01T00:00:00Z", "commit": {"id": "aaaa"}}]`)
+			default:
+				http.Error(w, "bad page", http.StatusBadRequest)
+			}
+		case "/api/v4/projects/group%2Fsubgroup%2Fchartsutil/releases/v1.0.0":
+			fmt.Fprint(w, `{"name": "v1.0.0", "tag_name": "v1.0.0", "created_at": "2024-02-01T00:00:00Z", "released_at": "2024-02-01T00:00:00Z", "commit": {"id": "aaaa"}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	source := &release.GitLabSource{
+		Client:  server.Client(),
+		BaseURL: server.URL + "/api/v4",
+		Ref: release.RepoRef{
+			Host:  "gitlab.example.com",
+			Owner: "group/subgroup",
+			Name:  "chartsutil",
+		},
+	}
+
+	releases, err := source.ListReleases(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []release.Release{
+		{Name: "Version 1.1.0", Tag: "v1.1.0", Date: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), Hash: "bbbb"},
+		{Name: "v1.0.0", Tag: "v1.0.0", Date: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), Hash: "aaaa"},
+	}
+
+	assertReleases(t, expected, releases)
+
+	current, err := source.GetRelease(context.Background(), "v1.0.0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	assertReleases(t, expected[1:], []release.Release{current})
+
+	if _, err := source.GetRelease(context.Background(), "v0.0.0"); err == nil {
+		t.Errorf("expected error for missing release")
+	}
+}
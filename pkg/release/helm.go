@@ -0,0 +1,174 @@
+package release
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// HelmRepoSource lists the versions of a chart in a Helm repository as its releases.
+type HelmRepoSource struct {
+	// Client is used for requests to the repository, if nil http.DefaultClient is used.
+	Client *http.Client
+
+	// URL is the url of the repository, which serves its index.yaml.
+	URL string
+
+	Chart string
+}
+
+type helmIndex struct {
+	Entries map[string][]helmChartVersion `yaml:"entries"`
+}
+
+type helmChartVersion struct {
+	Name       string   `yaml:"name"`
+	Version    string   `yaml:"version"`
+	AppVersion string   `yaml:"appVersion"`
+	Created    string   `yaml:"created"`
+	Digest     string   `yaml:"digest"`
+	URLs       []string `yaml:"urls"`
+}
+
+func (v helmChartVersion) release() Release {
+	created, _ := time.Parse(time.RFC3339, v.Created)
+
+	return Release{
+		Name:         v.Version,
+		Tag:          v.Version,
+		Date:         created,
+		Hash:         v.Digest,
+		ChartVersion: v.Version,
+		AppVersion:   v.AppVersion,
+	}
+}
+
+// fetchHelmIndex fetches the index.yaml of the repository at repoUrl.
+func fetchHelmIndex(ctx context.Context, client *http.Client, repoUrl string) (helmIndex, error) {
+	var index helmIndex
+
+	body, _, err := get(ctx, client, strings.TrimSuffix(repoUrl, "/")+"/index.yaml", nil)
+	if err != nil {
+		return index, err
+	}
+
+	if err := yaml.Unmarshal(body, &index); err != nil {
+		return index, fmt.Errorf("failed to parse index of '%s': %w", repoUrl, err)
+	}
+
+	return index, nil
+}
+
+func (s *HelmRepoSource) versions(ctx context.Context) ([]helmChartVersion, error) {
+	index, err := fetchHelmIndex(ctx, s.Client, s.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	versions, ok := index.Entries[s.Chart]
+	if !ok {
+		return nil, fmt.Errorf("chart '%s' not found in repository '%s'", s.Chart, s.URL)
+	}
+
+	return versions, nil
+}
+
+func (s *HelmRepoSource) ListReleases(ctx context.Context) ([]Release, error) {
+	versions, err := s.versions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	releases := make([]Release, 0, len(versions))
+	for _, v := range versions {
+		releases = append(releases, v.release())
+	}
+
+	SortReleases(releases)
+
+	if len(releases) > MaxAvailableReleases {
+		releases = releases[:MaxAvailableReleases]
+	}
+
+	return releases, nil
+}
+
+func (s *HelmRepoSource) GetRelease(ctx context.Context, tag string) (Release, error) {
+	versions, err := s.versions(ctx)
+	if err != nil {
+		return Release{}, err
+	}
+
+	for _, v := range versions {
+		if v.Version == tag {
+			return v.release(), nil
+		}
+	}
+
+	return Release{}, fmt.Errorf("version '%s' of chart '%s' not found in repository '%s'", tag, s.Chart, s.URL)
+}
+
+// HelmRepoCandidates returns the urls of the Helm repositories which may serve the chart archive at archiveUrl, from the most to the least likely. Charts released to GitHub releases by chart-releaser are indexed on the repository's GitHub pages, otherwise the index is looked for in each parent directory of the archive.
+func HelmRepoCandidates(archiveUrl string) ([]string, error) {
+	u, err := url.Parse(archiveUrl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid archive url '%s': %w", archiveUrl, err)
+	}
+
+	candidates := make([]string, 0)
+
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if u.Host == "github.com" && len(parts) > 3 && parts[2] == "releases" && parts[3] == "download" {
+		candidates = append(candidates, fmt.Sprintf("https://%s.github.io/%s", parts[0], parts[1]))
+	}
+
+	for dir := path.Dir(u.Path); ; dir = path.Dir(dir) {
+		candidates = append(candidates, fmt.Sprintf("%s://%s%s", u.Scheme, u.Host, strings.TrimSuffix(dir, "/")))
+
+		if dir == "/" || dir == "." {
+			break
+		}
+	}
+
+	return candidates, nil
+}
+
+// FindHelmRepo finds the first of the candidate repositories whose index has the chart archive at archiveUrl, returning a source for the chart and the version of the archive. Archives are matched by file name, so mirrors of a repository are found as well.
+func FindHelmRepo(ctx context.Context, client *http.Client, archiveUrl string, candidates []string) (*HelmRepoSource, string, error) {
+	archive := path.Base(archiveUrl)
+
+	var errs []error
+
+	for _, candidate := range candidates {
+		index, err := fetchHelmIndex(ctx, client, candidate)
+		if errors.Is(err, errNotFound) {
+			continue
+		} else if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		for chart, versions := range index.Entries {
+			for _, v := range versions {
+				for _, u := range v.URLs {
+					if path.Base(u) == archive {
+						return &HelmRepoSource{Client: client, URL: candidate, Chart: chart}, v.Version, nil
+					}
+				}
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, "", fmt.Errorf("no helm repository found for '%s': %w", archiveUrl, errors.Join(errs...))
+	}
+
+	return nil, "", fmt.Errorf("no helm repository found for '%s' in %v", archiveUrl, candidates)
+}
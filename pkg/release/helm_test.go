@@ -0,0 +1,136 @@
+package release_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/joshmeranda/chartsutil/pkg/release"
+)
+
+func TestHelmRepoCandidates(t *testing.T) {
+	type testCase struct {
+		Name     string
+		URL      string
+		Expected []string
+	}
+
+	cases := []testCase{
+		{
+			Name:     "Nested",
+			URL:      "https://charts.example.com/stable/example-1.0.0.tgz",
+			Expected: []string{"https://charts.example.com/stable", "https://charts.example.com"},
+		},
+		{
+			Name: "GitHubRelease",
+			URL:  "https://github.com/example/charts/releases/download/example-1.0.0/example-1.0.0.tgz",
+			Expected: []string{
+				"https://example.github.io/charts",
+				"https://github.com/example/charts/releases/download/example-1.0.0",
+				"https://github.com/example/charts/releases/download",
+				"https://github.com/example/charts/releases",
+				"https://github.com/example/charts",
+				"https://github.com/example",
+				"https://github.com",
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			actual, err := release.HelmRepoCandidates(c.URL)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !slices.Equal(c.Expected, actual) {
+				t.Errorf("expected candidates %v but found %v", c.Expected, actual)
+			}
+		})
+	}
+}
+
+func TestHelmRepoSource(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/charts/index.yaml" {
+			http.NotFound(w, r)
+			return
+		}
+
+		fmt.Fprintf(w, `apiVersion: v1
+entries:
+  example:
+  - name: example
+    version: 1.1.0
+    appVersion: v2.1.0
+    created: "2024-03-01T00:00:00Z"
+    digest: sha256:b
+    urls: [https://mirror.example.com/example-1.1.0.tgz]
+  - name: example
+    version: 2.0.0-rc.1
+    appVersion: v3.0.0-rc.1
+    created: "2024-04-01T00:00:00Z"
+    digest: sha256:c
+    urls: [example-2.0.0-rc.1.tgz]
+  - name: example
+    version: 1.0.0
+    appVersion: v2.0.0
+    created: "2024-01-01T00:00:00Z"
+    digest: sha256:a
+    urls: [example-1.0.0.tgz]
+  other:
+  - name: other
+    version: 9.0.0
+    created: "2024-01-01T00:00:00Z"
+    urls: [other-9.0.0.tgz]
+`)
+	}))
+	defer server.Close()
+
+	archiveUrl := server.URL + "/charts/archives/example-1.0.0.tgz"
+
+	candidates, err := release.HelmRepoCandidates(archiveUrl)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	source, version, err := release.FindHelmRepo(context.Background(), server.Client(), archiveUrl, candidates)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if source.URL != server.URL+"/charts" || source.Chart != "example" || version != "1.0.0" {
+		t.Errorf("expected chart 'example' at version 1.0.0 in '%s/charts' but found '%s' at %s in '%s'", server.URL, source.Chart, version, source.URL)
+	}
+
+	query := release.ReleaseQuery{
+		NewerThan: semver.MustParse(version),
+	}
+
+	actual, err := release.ReleasesForUpstream(context.Background(), source, query)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []release.Release{
+		{
+			Name:         "1.1.0",
+			Tag:          "1.1.0",
+			Date:         time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+			Hash:         "sha256:b",
+			ChartVersion: "1.1.0",
+			AppVersion:   "v2.1.0",
+		},
+	}
+
+	assertReleases(t, expected, actual)
+
+	if _, _, err := release.FindHelmRepo(context.Background(), server.Client(), server.URL+"/charts/missing-1.0.0.tgz", candidates); err == nil {
+		t.Errorf("expected error for archive missing from the index")
+	}
+}
@@ -0,0 +1,99 @@
+package release
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+const (
+	ChangelogFileName = "CHANGELOG.md"
+
+	DefaultBreakingChangePattern = `(?i)(breaking|\bremoved\b|\brenamed\b|\bdeprecated\b)`
+)
+
+// markdownHeading matches a markdown heading, capturing its level and text.
+var markdownHeading = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*$`)
+
+// ChangelogSection is the section of a changelog describing a single version.
+type ChangelogSection struct {
+	Version *semver.Version
+	Heading string
+	Body    string
+}
+
+// ParseChangelog splits a markdown changelog into a section for each heading with a version (ex. '## [1.2.3] - 2024-05-01'). Any subheadings belong to the section they are in.
+func ParseChangelog(changelog string) []ChangelogSection {
+	sections := make([]ChangelogSection, 0)
+
+	var current *ChangelogSection
+	var level int
+	var body []string
+
+	end := func() {
+		if current != nil {
+			current.Body = strings.TrimSpace(strings.Join(body, "\n"))
+			sections = append(sections, *current)
+		}
+
+		current, body = nil, nil
+	}
+
+	for _, line := range strings.Split(changelog, "\n") {
+		if m := markdownHeading.FindStringSubmatch(line); m != nil {
+			if current == nil || len(m[1]) <= level {
+				end()
+
+				if v := ParseVersion(m[2]); v != nil {
+					current = &ChangelogSection{Version: v, Heading: m[2]}
+					level = len(m[1])
+				}
+
+				continue
+			}
+		}
+
+		if current != nil {
+			body = append(body, line)
+		}
+	}
+
+	end()
+
+	return sections
+}
+
+// ReadChangelog reads the changelog in subdirectory at the commit, returning an empty string if there is none.
+func ReadChangelog(commit *object.Commit, subdirectory string) (string, error) {
+	f, err := commit.File(path.Join(subdirectory, ChangelogFileName))
+	if errors.Is(err, object.ErrFileNotFound) {
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("failed to find changelog: %w", err)
+	}
+
+	contents, err := f.Contents()
+	if err != nil {
+		return "", fmt.Errorf("failed to read changelog: %w", err)
+	}
+
+	return contents, nil
+}
+
+// BreakingChanges returns the lines of the notes matching the pattern.
+func BreakingChanges(notes string, pattern *regexp.Regexp) []string {
+	lines := make([]string, 0)
+
+	for _, line := range strings.Split(notes, "\n") {
+		if pattern.MatchString(line) {
+			lines = append(lines, strings.TrimSpace(line))
+		}
+	}
+
+	return lines
+}
@@ -0,0 +1,71 @@
+package release_test
+
+import (
+	"regexp"
+	"slices"
+	"testing"
+
+	"github.com/joshmeranda/chartsutil/pkg/release"
+)
+
+func TestParseChangelog(t *testing.T) {
+	changelog := `# Changelog
+
+## Unreleased
+
+- not yet released
+
+## [1.1.0] - 2024-05-01
+
+### Changed
+
+- renamed the foo value
+
+## v1.0.0
+
+- initial release
+`
+
+	type section struct {
+		Version string
+		Body    string
+	}
+
+	expected := []section{
+		{Version: "1.1.0", Body: "### Changed\n\n- renamed the foo value"},
+		{Version: "1.0.0", Body: "- initial release"},
+	}
+
+	actual := release.ParseChangelog(changelog)
+
+	if len(actual) != len(expected) {
+		t.Fatalf("expected %d sections but found %d: %+v", len(expected), len(actual), actual)
+	}
+
+	for i, s := range actual {
+		if s.Version.String() != expected[i].Version || s.Body != expected[i].Body {
+			t.Errorf("expected section %s with body %q but found %s with body %q", expected[i].Version, expected[i].Body, s.Version, s.Body)
+		}
+	}
+}
+
+func TestBreakingChanges(t *testing.T) {
+	notes := `## What's Changed
+
+- BREAKING: the chart now requires kubernetes 1.25
+- add support for foo
+- Removed the deprecated bar value
+- fix the unremovedable typo
+`
+
+	expected := []string{
+		"- BREAKING: the chart now requires kubernetes 1.25",
+		"- Removed the deprecated bar value",
+	}
+
+	actual := release.BreakingChanges(notes, regexp.MustCompile(release.DefaultBreakingChangePattern))
+
+	if !slices.Equal(expected, actual) {
+		t.Errorf("expected %v but found %v", expected, actual)
+	}
+}
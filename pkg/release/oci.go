@@ -0,0 +1,223 @@
+package release
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	ociScheme = "oci://"
+
+	ociManifestMediaType = "application/vnd.oci.image.manifest.v1+json"
+
+	// ociCreatedAnnotation is the manifest annotation holding when the chart was pushed.
+	ociCreatedAnnotation = "org.opencontainers.image.created"
+)
+
+// IsOCIUpstream returns true if the url points to a chart in an OCI registry (ex. oci://ghcr.io/org/charts/example:1.2.3).
+func IsOCIUpstream(url string) bool {
+	return strings.HasPrefix(url, ociScheme)
+}
+
+// ParseOCIRef splits an OCI chart url into its registry host, repository and tag, which is empty if the url has none.
+func ParseOCIRef(ref string) (registry string, repository string, tag string, err error) {
+	if !IsOCIUpstream(ref) {
+		return "", "", "", fmt.Errorf("'%s' is not an oci url", ref)
+	}
+
+	registry, repository, ok := strings.Cut(strings.TrimPrefix(ref, ociScheme), "/")
+	if !ok || registry == "" || repository == "" {
+		return "", "", "", fmt.Errorf("oci url '%s' has no repository", ref)
+	}
+
+	if i := strings.LastIndex(repository, ":"); i > strings.LastIndex(repository, "/") {
+		repository, tag = repository[:i], repository[i+1:]
+	}
+
+	return registry, repository, tag, nil
+}
+
+// OCISource lists the tags of a chart pushed to an OCI registry as its releases. Tags are listed without dates, which are only fetched for single releases.
+type OCISource struct {
+	// Client is used for requests to the registry, if nil http.DefaultClient is used.
+	Client *http.Client
+
+	// Registry is the host of the registry (ex. ghcr.io).
+	Registry string
+
+	// Repository is the path of the chart in the registry (ex. org/charts/example).
+	Repository string
+
+	// token is the bearer token used for requests once the registry has asked for one.
+	token string
+}
+
+// challengeParam matches the parameters of a WWW-Authenticate challenge (ex. realm="https://ghcr.io/token").
+var challengeParam = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// authorize fetches a bearer token from the token service in the registry's challenge, authenticating with the client's credentials for the service if it has any.
+func (s *OCISource) authorize(ctx context.Context, challenge string) error {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return fmt.Errorf("unsupported registry auth challenge '%s'", challenge)
+	}
+
+	values := url.Values{}
+	var realm string
+
+	for _, m := range challengeParam.FindAllStringSubmatch(params, -1) {
+		if m[1] == "realm" {
+			realm = m[2]
+		} else {
+			values.Set(m[1], m[2])
+		}
+	}
+
+	if realm == "" {
+		return fmt.Errorf("registry auth challenge has no realm: '%s'", challenge)
+	}
+
+	if !values.Has("scope") {
+		values.Set("scope", fmt.Sprintf("repository:%s:pull", s.Repository))
+	}
+
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+
+	if _, err := getJSON(ctx, s.Client, realm+"?"+values.Encode(), &token); err != nil {
+		return fmt.Errorf("failed to get registry token: %w", err)
+	}
+
+	s.token = token.Token
+	if s.token == "" {
+		s.token = token.AccessToken
+	}
+
+	return nil
+}
+
+// get makes a request to the registry api, authorizing with the registry if asked to.
+func (s *OCISource) get(ctx context.Context, path string, accept string) ([]byte, http.Header, error) {
+	u := fmt.Sprintf("https://%s/v2/%s", s.Registry, path)
+
+	for {
+		header := http.Header{"Accept": {accept}}
+		if s.token != "" {
+			header.Set("Authorization", "Bearer "+s.token)
+		}
+
+		body, respHeader, err := get(ctx, s.Client, u, header)
+		if errors.Is(err, errUnauthorized) && s.token == "" {
+			if err := s.authorize(ctx, respHeader.Get("WWW-Authenticate")); err != nil {
+				return nil, nil, err
+			}
+
+			continue
+		}
+
+		return body, respHeader, err
+	}
+}
+
+// nextLink matches the url of the next page in a Link header.
+var nextLink = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+func (s *OCISource) ListReleases(ctx context.Context) ([]Release, error) {
+	releases := make([]Release, 0)
+
+	for page := fmt.Sprintf("%s/tags/list", s.Repository); page != "" && len(releases) < MaxAvailableReleases; {
+		var tags struct {
+			Tags []string `json:"tags"`
+		}
+
+		body, header, err := s.get(ctx, page, "application/json")
+		if err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal(body, &tags); err != nil {
+			return nil, fmt.Errorf("failed to decode tags of '%s': %w", s.Repository, err)
+		}
+
+		for _, tag := range tags.Tags {
+			releases = append(releases, ociRelease(tag))
+		}
+
+		page = ""
+		if m := nextLink.FindStringSubmatch(header.Get("Link")); m != nil {
+			page = strings.TrimPrefix(m[1], "/v2/")
+		}
+	}
+
+	SortReleases(releases)
+
+	if len(releases) > MaxAvailableReleases {
+		releases = releases[:MaxAvailableReleases]
+	}
+
+	return releases, nil
+}
+
+// GetRelease reads the manifest and chart metadata of the tag for its digest, date and versions.
+func (s *OCISource) GetRelease(ctx context.Context, tag string) (Release, error) {
+	release := ociRelease(tag)
+
+	body, header, err := s.get(ctx, fmt.Sprintf("%s/manifests/%s", s.Repository, tag), ociManifestMediaType)
+	if err != nil {
+		return Release{}, err
+	}
+
+	var manifest struct {
+		Config struct {
+			Digest string `json:"digest"`
+		} `json:"config"`
+		Annotations map[string]string `json:"annotations"`
+	}
+
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		return Release{}, fmt.Errorf("failed to decode manifest of '%s:%s': %w", s.Repository, tag, err)
+	}
+
+	release.Hash = header.Get("Docker-Content-Digest")
+	release.Date, _ = time.Parse(time.RFC3339, manifest.Annotations[ociCreatedAnnotation])
+
+	// the config of a chart is its Chart.yaml as json
+	body, _, err = s.get(ctx, fmt.Sprintf("%s/blobs/%s", s.Repository, manifest.Config.Digest), "application/json")
+	if err != nil {
+		return Release{}, err
+	}
+
+	var chart struct {
+		Version    string `json:"version"`
+		AppVersion string `json:"appVersion"`
+	}
+
+	if err := json.Unmarshal(body, &chart); err != nil {
+		return Release{}, fmt.Errorf("failed to decode chart of '%s:%s': %w", s.Repository, tag, err)
+	}
+
+	release.ChartVersion = chart.Version
+	release.AppVersion = chart.AppVersion
+
+	return release, nil
+}
+
+func ociRelease(tag string) Release {
+	// '+' is not allowed in tags so helm pushes versions with build metadata with '_' instead
+	version := strings.ReplaceAll(tag, "_", "+")
+
+	return Release{
+		Name:         version,
+		Tag:          tag,
+		ChartVersion: version,
+	}
+}
@@ -0,0 +1,129 @@
+package release_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/joshmeranda/chartsutil/pkg/release"
+)
+
+func TestParseOCIRef(t *testing.T) {
+	type testCase struct {
+		Name       string
+		URL        string
+		Registry   string
+		Repository string
+		Tag        string
+	}
+
+	cases := []testCase{
+		{
+			Name:       "Tagged",
+			URL:        "oci://ghcr.io/example/charts/example:1.0.0",
+			Registry:   "ghcr.io",
+			Repository: "example/charts/example",
+			Tag:        "1.0.0",
+		},
+		{
+			Name:       "Untagged",
+			URL:        "oci://registry.example.com:5000/example",
+			Registry:   "registry.example.com:5000",
+			Repository: "example",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			registry, repository, tag, err := release.ParseOCIRef(c.URL)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if registry != c.Registry || repository != c.Repository || tag != c.Tag {
+				t.Errorf("expected '%s', '%s', '%s' but found '%s', '%s', '%s'", c.Registry, c.Repository, c.Tag, registry, repository, tag)
+			}
+		})
+	}
+
+	if _, _, _, err := release.ParseOCIRef("oci://ghcr.io"); err == nil {
+		t.Errorf("expected error for url without a repository")
+	}
+}
+
+func TestOCISource(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			if r.URL.Query().Get("scope") != "repository:charts/example:pull" {
+				http.Error(w, "bad scope", http.StatusBadRequest)
+				return
+			}
+
+			fmt.Fprint(w, `{"token": "registry-token"}`)
+			return
+		}
+
+		if r.Header.Get("Authorization") != "Bearer registry-token" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch {
+		case r.URL.Path == "/v2/charts/example/tags/list" && r.URL.Query().Get("last") == "":
+			w.Header().Set("Link", `</v2/charts/example/tags/list?last=1.0.0&n=2>; rel="next"`)
+			fmt.Fprint(w, `{"name": "charts/example", "tags": ["0.9.0", "1.0.0"]}`)
+		case r.URL.Path == "/v2/charts/example/tags/list":
+			fmt.Fprint(w, `{"name": "charts/example", "tags": ["1.1.0_build.1", "latest"]}`)
+		case strings.HasPrefix(r.URL.Path, "/v2/charts/example/manifests/"):
+			tag := strings.TrimPrefix(r.URL.Path, "/v2/charts/example/manifests/")
+
+			w.Header().Set("Docker-Content-Digest", "sha256:manifest-"+tag)
+			json.NewEncoder(w).Encode(map[string]any{
+				"config":      map[string]string{"digest": "sha256:config-" + tag},
+				"annotations": map[string]string{"org.opencontainers.image.created": "2024-03-01T00:00:00Z"},
+			})
+		case r.URL.Path == "/v2/charts/example/blobs/sha256:config-1.1.0_build.1":
+			fmt.Fprint(w, `{"name": "example", "version": "1.1.0+build.1", "appVersion": "v2.1.0"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	source := &release.OCISource{
+		Client:     server.Client(),
+		Registry:   strings.TrimPrefix(server.URL, "https://"),
+		Repository: "charts/example",
+	}
+
+	query := release.ReleaseQuery{
+		NewerThan: semver.MustParse("1.0.0"),
+	}
+
+	actual, err := release.ReleasesForUpstream(context.Background(), source, query)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// only the newer release has its details fetched
+	expected := []release.Release{
+		{
+			Name:         "1.1.0+build.1",
+			Tag:          "1.1.0_build.1",
+			Date:         time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+			Hash:         "sha256:manifest-1.1.0_build.1",
+			ChartVersion: "1.1.0+build.1",
+			AppVersion:   "v2.1.0",
+		},
+	}
+
+	assertReleases(t, expected, actual)
+}
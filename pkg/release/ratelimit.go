@@ -0,0 +1,196 @@
+package release
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DefaultMaxRetries = 3
+	DefaultMaxWait    = time.Minute
+	DefaultBackoff    = time.Second
+)
+
+// RateLimitTransport retries requests rejected by a provider's rate limit, waiting as long as the provider asks, and logs the remaining quota reported by the provider.
+type RateLimitTransport struct {
+	// Base is used to make requests, if nil http.DefaultTransport is used.
+	Base http.RoundTripper
+
+	// Logger reports the remaining quota, if nil slog.Default is used.
+	Logger *slog.Logger
+
+	// MaxRetries is the number of times a request is retried, if zero DefaultMaxRetries is used.
+	MaxRetries int
+
+	// MaxWait is the longest time to wait before a retry, if the provider asks for longer the failed response is returned. If zero DefaultMaxWait is used.
+	MaxWait time.Duration
+
+	// Backoff is the wait before the first retry when the provider does not say how long to wait, doubling with each retry. If zero DefaultBackoff is used.
+	Backoff time.Duration
+
+	warned atomic.Bool
+}
+
+// rateLimit is the quota reported by the provider in the headers of a response. GitHub and Gitea use X-RateLimit-* headers while GitLab uses RateLimit-*.
+type rateLimit struct {
+	limit     int
+	remaining int
+	reset     time.Time
+}
+
+func parseRateLimit(header http.Header) (rateLimit, bool) {
+	get := func(name string) string {
+		if v := header.Get("X-RateLimit-" + name); v != "" {
+			return v
+		}
+
+		return header.Get("RateLimit-" + name)
+	}
+
+	limit, err := strconv.Atoi(get("Limit"))
+	if err != nil {
+		return rateLimit{}, false
+	}
+
+	remaining, err := strconv.Atoi(get("Remaining"))
+	if err != nil {
+		return rateLimit{}, false
+	}
+
+	rl := rateLimit{limit: limit, remaining: remaining}
+
+	if reset, err := strconv.ParseInt(get("Reset"), 10, 64); err == nil {
+		rl.reset = time.Unix(reset, 0)
+	}
+
+	return rl, true
+}
+
+// retryAfter returns the wait requested by the Retry-After header, which is either a number of seconds or a date.
+func retryAfter(header http.Header) (time.Duration, bool) {
+	v := header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(v); err == nil {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(v); err == nil {
+		return max(time.Until(date), 0), true
+	}
+
+	return 0, false
+}
+
+func (t *RateLimitTransport) logger() *slog.Logger {
+	if t.Logger == nil {
+		return slog.Default()
+	}
+
+	return t.Logger
+}
+
+// logQuota reports the remaining quota, warning once when less than a tenth remains.
+func (t *RateLimitTransport) logQuota(req *http.Request, rl rateLimit) {
+	t.logger().Debug("api rate limit", "host", req.URL.Host, "remaining", rl.remaining, "limit", rl.limit, "reset", rl.reset)
+
+	if rl.remaining < rl.limit/10 && t.warned.CompareAndSwap(false, true) {
+		t.logger().Warn("api rate limit is nearly exhausted, consider setting a token", "host", req.URL.Host, "remaining", rl.remaining, "limit", rl.limit, "reset", rl.reset)
+	}
+}
+
+// wait returns how long to wait before retrying the response, or false if it should not be retried.
+func (t *RateLimitTransport) wait(resp *http.Response, attempt int) (time.Duration, bool) {
+	after, hasAfter := retryAfter(resp.Header)
+	rl, hasLimit := parseRateLimit(resp.Header)
+	exhausted := hasLimit && rl.remaining == 0
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+	case http.StatusForbidden:
+		// GitHub rejects requests over its rate limits as forbidden
+		if !exhausted && !hasAfter {
+			return 0, false
+		}
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+	default:
+		return 0, false
+	}
+
+	switch {
+	case hasAfter:
+		return after, true
+	case exhausted && !rl.reset.IsZero():
+		return max(time.Until(rl.reset), 0), true
+	}
+
+	backoff := t.Backoff
+	if backoff == 0 {
+		backoff = DefaultBackoff
+	}
+
+	return backoff << attempt, true
+}
+
+func (t *RateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	maxRetries := t.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = DefaultMaxRetries
+	}
+
+	maxWait := t.MaxWait
+	if maxWait == 0 {
+		maxWait = DefaultMaxWait
+	}
+
+	// requests with a body cannot be replayed
+	retryable := req.Body == nil || req.Body == http.NoBody
+
+	for attempt := 0; ; attempt++ {
+		resp, err := base.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+
+		if rl, ok := parseRateLimit(resp.Header); ok {
+			t.logQuota(req, rl)
+		}
+
+		if !retryable || attempt >= maxRetries {
+			return resp, nil
+		}
+
+		wait, ok := t.wait(resp, attempt)
+		if !ok {
+			return resp, nil
+		}
+
+		if wait > maxWait {
+			t.logger().Warn("api rate limit exceeded, not waiting for it to reset", "host", req.URL.Host, "wait", wait.Round(time.Second))
+			return resp, nil
+		}
+
+		t.logger().Info("request was rate limited, retrying", "host", req.URL.Host, "status", resp.Status, "wait", wait)
+
+		resp.Body.Close()
+
+		timer := time.NewTimer(wait)
+
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
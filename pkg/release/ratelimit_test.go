@@ -0,0 +1,108 @@
+package release_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/joshmeranda/chartsutil/pkg/release"
+)
+
+func TestRateLimitTransport(t *testing.T) {
+	type testCase struct {
+		Name string
+
+		// Limited are the headers and status of the responses before the request succeeds.
+		Limited []http.Header
+		Status  int
+
+		ExpectedStatus   int
+		ExpectedRequests int
+	}
+
+	cases := []testCase{
+		{
+			Name:             "NotLimited",
+			ExpectedStatus:   http.StatusOK,
+			ExpectedRequests: 1,
+		},
+		{
+			Name:             "TooManyRequests",
+			Limited:          []http.Header{{"Retry-After": {"0"}}, {}},
+			Status:           http.StatusTooManyRequests,
+			ExpectedStatus:   http.StatusOK,
+			ExpectedRequests: 3,
+		},
+		{
+			Name: "ForbiddenExhausted",
+			Limited: []http.Header{{
+				"X-Ratelimit-Limit":     {"60"},
+				"X-Ratelimit-Remaining": {"0"},
+				"X-Ratelimit-Reset":     {fmt.Sprint(time.Now().Add(-time.Second).Unix())},
+			}},
+			Status:           http.StatusForbidden,
+			ExpectedStatus:   http.StatusOK,
+			ExpectedRequests: 2,
+		},
+		{
+			Name:             "ForbiddenNotLimited",
+			Limited:          []http.Header{{}},
+			Status:           http.StatusForbidden,
+			ExpectedStatus:   http.StatusForbidden,
+			ExpectedRequests: 1,
+		},
+		{
+			Name:             "WaitTooLong",
+			Limited:          []http.Header{{"Retry-After": {"3600"}}},
+			Status:           http.StatusTooManyRequests,
+			ExpectedStatus:   http.StatusTooManyRequests,
+			ExpectedRequests: 1,
+		},
+		{
+			Name:             "TooManyRetries",
+			Limited:          []http.Header{{}, {}, {}, {}, {}},
+			Status:           http.StatusServiceUnavailable,
+			ExpectedStatus:   http.StatusServiceUnavailable,
+			ExpectedRequests: 4,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+
+				if requests <= len(c.Limited) {
+					for k, v := range c.Limited[requests-1] {
+						w.Header()[k] = v
+					}
+
+					w.WriteHeader(c.Status)
+					return
+				}
+
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer server.Close()
+
+			client := &http.Client{Transport: &release.RateLimitTransport{Backoff: time.Millisecond}}
+
+			resp, err := client.Get(server.URL)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != c.ExpectedStatus {
+				t.Errorf("expected status %d but found %d", c.ExpectedStatus, resp.StatusCode)
+			}
+
+			if requests != c.ExpectedRequests {
+				t.Errorf("expected %d requests but found %d", c.ExpectedRequests, requests)
+			}
+		})
+	}
+}
@@ -3,29 +3,53 @@ package release
 import (
 	"fmt"
 	"net/url"
+	"regexp"
+	"slices"
 	"strings"
 )
 
+// scpLikeUrl matches scp style ssh urls (ex. git@github.com:owner/name.git).
+var scpLikeUrl = regexp.MustCompile(`^(?:[\w.-]+@)?([\w.-]+):([^/].*)$`)
+
 type RepoRef struct {
+	// Host is the host of the upstream (ex. github.com).
+	Host string
+
+	// Owner is the user or organization owning the upstream, for nested groups (ex. GitLab subgroups) this holds every group separated by '/'.
 	Owner string
-	Name  string
+
+	Name string
 }
 
+// Path returns the path to the repository on its host, ex. 'owner/name'.
+func (r RepoRef) Path() string {
+	return r.Owner + "/" + r.Name
+}
+
+// RepoRefFromUrl parses the host, owner, and name of the upstream at the http(s), ssh, or scp style url.
 func RepoRefFromUrl(s string) (RepoRef, error) {
-	u, err := url.ParseRequestURI(s)
-	if err != nil {
-		return RepoRef{}, fmt.Errorf("provided upstream '%s' is not a valid url: %w", s, err)
+	var host, path string
+
+	if m := scpLikeUrl.FindStringSubmatch(s); m != nil && !strings.Contains(s, "://") {
+		host, path = m[1], m[2]
+	} else {
+		u, err := url.ParseRequestURI(s)
+		if err != nil {
+			return RepoRef{}, fmt.Errorf("provided upstream '%s' is not a valid url: %w", s, err)
+		}
+
+		host, path = u.Hostname(), u.Path
 	}
 
-	components := strings.Split(strings.TrimSuffix(u.Path, ".git"), "/")
+	components := strings.Split(strings.Trim(strings.TrimSuffix(path, ".git"), "/"), "/")
 
-	// require 3 paths to take the leading '/'
-	if l := len(components); l != 3 {
-		return RepoRef{}, fmt.Errorf("expected upstream path to have only 2 compoents but found '%d': %v", l, components)
+	if l := len(components); l < 2 || slices.Contains(components, "") {
+		return RepoRef{}, fmt.Errorf("expected upstream path to have an owner and name but found '%d' components: %v", l, components)
 	}
 
 	ref := RepoRef{
-		Owner: components[len(components)-2],
+		Host:  host,
+		Owner: strings.Join(components[:len(components)-1], "/"),
 		Name:  components[len(components)-1],
 	}
 
@@ -20,6 +20,43 @@ func TestRoleRef(t *testing.T) {
 			Name: "Normal Upstream URL",
 			In:   "https://github.com/joshmeranda/chartsutil.git",
 			ExpectedRef: release.RepoRef{
+				Host:  "github.com",
+				Owner: "joshmeranda",
+				Name:  "chartsutil",
+			},
+		},
+		{
+			Name: "No Git Suffix",
+			In:   "https://codeberg.org/joshmeranda/chartsutil",
+			ExpectedRef: release.RepoRef{
+				Host:  "codeberg.org",
+				Owner: "joshmeranda",
+				Name:  "chartsutil",
+			},
+		},
+		{
+			Name: "Nested Groups",
+			In:   "https://gitlab.com/group/subgroup/chartsutil.git",
+			ExpectedRef: release.RepoRef{
+				Host:  "gitlab.com",
+				Owner: "group/subgroup",
+				Name:  "chartsutil",
+			},
+		},
+		{
+			Name: "SSH URL",
+			In:   "ssh://git@gitea.example.com:2222/joshmeranda/chartsutil.git",
+			ExpectedRef: release.RepoRef{
+				Host:  "gitea.example.com",
+				Owner: "joshmeranda",
+				Name:  "chartsutil",
+			},
+		},
+		{
+			Name: "SCP Like URL",
+			In:   "git@github.com:joshmeranda/chartsutil.git",
+			ExpectedRef: release.RepoRef{
+				Host:  "github.com",
 				Owner: "joshmeranda",
 				Name:  "chartsutil",
 			},
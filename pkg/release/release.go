@@ -3,10 +3,17 @@ package release
 import (
 	"context"
 	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
 	"regexp"
+	"strings"
 	"time"
 
-	"github.com/google/go-github/github"
+	"github.com/Masterminds/semver/v3"
+	"github.com/go-git/go-git/v5"
+	"github.com/joshmeranda/chartsutil/pkg/cache"
+	"github.com/joshmeranda/chartsutil/pkg/iter"
 )
 
 const (
@@ -15,50 +22,260 @@ const (
 )
 
 type ReleaseQuery struct {
+	// Since limits the releases to those published after it, if zero releases are not filtered by date.
 	Since       time.Time
 	NamePattern *regexp.Regexp
+
+	// NewerThan limits the releases to versions higher than it, releases without a version never match. If nil releases are not filtered by version.
+	NewerThan *semver.Version
+
+	// Through limits the releases to versions no higher than it, releases without a version never match. If nil releases are not filtered by version.
+	Through *semver.Version
+
+	// Constraint limits the releases to versions matching it (ex. '>=55.0.0 <56'), releases without a version never match. If nil all versions match.
+	Constraint *semver.Constraints
+
+	// IncludePrereleases includes releases marked as prereleases or with prerelease versions, which are matched against Constraint as if they were their final release.
+	IncludePrereleases bool
 }
 
 type Release struct {
 	Name string
-	Age  time.Duration
+	Tag  string
+
+	// Date is when the release was published, or the date of the tag for plain git tags.
+	Date time.Time
+
+	// Hash is the commit of the release, which is only guaranteed to be a commit sha for sources created with a clone of the upstream.
 	Hash string
+
+	// ChartVersion and AppVersion are read from the chart at the release for sources created with a clone of the upstream.
+	ChartVersion string
+	AppVersion   string
+
+	// Body is the description of the release, or the message of an annotated tag.
+	Body string
+
+	Draft      bool
+	Prerelease bool
 }
 
-func ReleasesForUpstream(ctx context.Context, ref RepoRef, query ReleaseQuery) ([]Release, error) {
-	client := github.NewClient(nil)
+// ReleaseSource lists the releases of a single upstream.
+type ReleaseSource interface {
+	// ListReleases lists the releases of the upstream, no more than MaxAvailableReleases are listed.
+	ListReleases(ctx context.Context) ([]Release, error)
 
-	matchingReleases := make([]Release, 0)
-	now := time.Now().UTC()
+	// GetRelease returns the release for the tag.
+	GetRelease(ctx context.Context, tag string) (Release, error)
+}
+
+// Provider is the kind of host serving an upstream, which determines how its releases are found.
+type Provider string
+
+const (
+	ProviderGitHub Provider = "github"
+	ProviderGitLab Provider = "gitlab"
+	ProviderGitea  Provider = "gitea"
+
+	// ProviderGit lists the tags of any git upstream as its releases.
+	ProviderGit Provider = "git"
+)
+
+// Providers are the names of the supported providers.
+var Providers = []string{string(ProviderGitHub), string(ProviderGitLab), string(ProviderGitea), string(ProviderGit)}
+
+// DetectProvider guesses the provider of the host from its name, falling back to ProviderGit for unknown hosts.
+func DetectProvider(host string) Provider {
+	host = strings.ToLower(host)
+
+	switch {
+	case host == "github.com" || strings.HasPrefix(host, "github."):
+		// GitHub Enterprise Server instances are commonly hosted at github.<company domain>
+		return ProviderGitHub
+	case host == "gitlab.com" || strings.Contains(host, "gitlab"):
+		return ProviderGitLab
+	case host == "codeberg.org" || strings.Contains(host, "gitea") || strings.Contains(host, "forgejo"):
+		return ProviderGitea
+	default:
+		return ProviderGit
+	}
+}
+
+// SourceOptions configures the release source for an upstream.
+type SourceOptions struct {
+	// Provider overrides the provider detected from the upstream host.
+	Provider Provider
+
+	// BaseURL overrides the url of the provider's api (ex. https://gitlab.example.com/api/v4).
+	BaseURL string
+
+	// Client is used for requests to the provider's api, if nil http.DefaultClient is used.
+	Client *http.Client
+
+	// Repo is an open clone of the upstream. If set, release tags are resolved to their commits and chart versions, otherwise ProviderGit opens the upstream from Cache.
+	Repo *git.Repository
+
+	// Subdirectory is the path of the chart in the upstream, used to read the chart version of each release.
+	Subdirectory string
+
+	// Cache holds the clone of the upstream used by ProviderGit, if nil the default cache is used.
+	Cache *cache.Cache
+
+	// Logger reports releases which could not be resolved against Repo, if nil slog.Default is used.
+	Logger *slog.Logger
+}
+
+// NewSource returns the release source for the upstream at url. Local upstreams and upstreams on unknown hosts list their git tags.
+func NewSource(upstreamUrl string, opts SourceOptions) (ReleaseSource, error) {
+	source, err := newSource(upstreamUrl, opts)
+	if err != nil || opts.Repo == nil {
+		return source, err
+	}
+
+	return &resolvedSource{ReleaseSource: source, repo: opts.Repo, subdirectory: opts.Subdirectory, logger: opts.Logger}, nil
+}
+
+func newSource(upstreamUrl string, opts SourceOptions) (ReleaseSource, error) {
+	if iter.IsLocalUpstream(upstreamUrl) {
+		if opts.Provider != "" && opts.Provider != ProviderGit {
+			return nil, fmt.Errorf("local upstream '%s' can only use the '%s' provider", upstreamUrl, ProviderGit)
+		}
+
+		return &GitSource{URL: upstreamUrl, Repo: opts.Repo, Cache: opts.Cache}, nil
+	}
+
+	ref, err := RepoRefFromUrl(upstreamUrl)
+	if err != nil {
+		if opts.Provider == "" || opts.Provider == ProviderGit {
+			return &GitSource{URL: upstreamUrl, Repo: opts.Repo, Cache: opts.Cache}, nil
+		}
+
+		return nil, fmt.Errorf("failed to get upstream owner and name from url: %w", err)
+	}
+
+	provider := opts.Provider
+	if provider == "" {
+		provider = DetectProvider(ref.Host)
+	}
+
+	baseUrl := opts.BaseURL
+	if baseUrl == "" {
+		baseUrl = defaultBaseURL(upstreamUrl, ref, provider)
+	}
+
+	switch provider {
+	case ProviderGitHub:
+		return NewGitHubSource(opts.Client, baseUrl, ref)
+	case ProviderGitLab:
+		return &GitLabSource{Client: opts.Client, BaseURL: baseUrl, Ref: ref}, nil
+	case ProviderGitea:
+		return &GiteaSource{Client: opts.Client, BaseURL: baseUrl, Ref: ref}, nil
+	case ProviderGit:
+		return &GitSource{URL: upstreamUrl, Repo: opts.Repo, Cache: opts.Cache}, nil
+	default:
+		return nil, fmt.Errorf("unknown release provider '%s', expected one of %v", provider, Providers)
+	}
+}
+
+// defaultBaseURL returns the api url of the provider on the upstream's host, or an empty string for github.com.
+func defaultBaseURL(upstreamUrl string, ref RepoRef, provider Provider) string {
+	scheme := "https"
+	if u, err := url.Parse(upstreamUrl); err == nil && u.Scheme == "http" {
+		scheme = u.Scheme
+	}
+
+	switch provider {
+	case ProviderGitHub:
+		if ref.Host == "github.com" {
+			return ""
+		}
+
+		return fmt.Sprintf("%s://%s/api/v3/", scheme, ref.Host)
+	case ProviderGitLab:
+		return fmt.Sprintf("%s://%s/api/v4", scheme, ref.Host)
+	case ProviderGitea:
+		return fmt.Sprintf("%s://%s/api/v1", scheme, ref.Host)
+	default:
+		return ""
+	}
+}
+
+// Matches reports whether the release matches the query. Drafts never match.
+func (q ReleaseQuery) Matches(release Release) bool {
+	if release.Draft || (!q.Since.IsZero() && !release.Date.After(q.Since)) {
+		return false
+	}
+
+	if q.NamePattern != nil && !q.NamePattern.MatchString(release.Name) {
+		return false
+	}
+
+	if !q.IncludePrereleases && release.IsPrerelease() {
+		return false
+	}
+
+	if q.Constraint == nil && q.NewerThan == nil && q.Through == nil {
+		return true
+	}
+
+	v := release.Version()
+	if v == nil {
+		return false
+	}
 
-	listOpts := &github.ListOptions{
-		PerPage: 100,
+	if q.NewerThan != nil && !v.GreaterThan(q.NewerThan) {
+		return false
 	}
 
-	for i := 1; i*listOpts.PerPage <= MaxAvailableReleases+1; i++ {
-		releases, response, err := client.Repositories.ListReleases(ctx, ref.Owner, ref.Name, listOpts)
+	if q.Through != nil && v.GreaterThan(q.Through) {
+		return false
+	}
+
+	if q.Constraint == nil {
+		return true
+	}
+
+	// constraints without a prerelease never match prerelease versions
+	if v.Prerelease() != "" {
+		final, err := v.SetPrerelease("")
 		if err != nil {
-			return nil, fmt.Errorf("error fetching releases for chart upstream: %w", err)
+			return false
 		}
 
-		for _, release := range releases {
-			if release.CreatedAt.Time.After(query.Since) && query.NamePattern.MatchString(*release.Name) {
-				entry := Release{
-					Name: *release.Name,
-					Age:  now.Sub(release.CreatedAt.Time),
-					Hash: *release.TargetCommitish,
-				}
+		v = &final
+	}
+
+	return q.Constraint.Check(v)
+}
 
-				matchingReleases = append(matchingReleases, entry)
-			}
+// ReleasesForUpstream lists the releases from the source matching the query, sorted from the highest version to the lowest.
+func ReleasesForUpstream(ctx context.Context, source ReleaseSource, query ReleaseQuery) ([]Release, error) {
+	releases, err := source.ListReleases(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching releases for chart upstream: %w", err)
+	}
+
+	matchingReleases := make([]Release, 0)
+
+	for _, release := range releases {
+		if !query.Matches(release) {
+			continue
 		}
 
-		if response.NextPage == 0 {
-			break
+		// sources like OCI registries list releases without their details, which are fetched for only the matching releases
+		if release.Date.IsZero() {
+			detailed, err := source.GetRelease(ctx, release.Tag)
+			if err != nil {
+				return nil, fmt.Errorf("error fetching release '%s': %w", release.Tag, err)
+			}
+
+			release = detailed
 		}
 
-		listOpts.Page = response.NextPage
+		matchingReleases = append(matchingReleases, release)
 	}
 
+	SortReleases(matchingReleases)
+
 	return matchingReleases, nil
 }
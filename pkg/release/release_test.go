@@ -2,9 +2,19 @@ package release_test
 
 import (
 	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
 	"regexp"
 	"testing"
+	"time"
 
+	"github.com/joshmeranda/chartsutil/pkg/auth"
+	"github.com/joshmeranda/chartsutil/pkg/fixture"
 	"github.com/joshmeranda/chartsutil/pkg/release"
 )
 
@@ -49,42 +59,281 @@ func TestTagPattern(t *testing.T) {
 }
 
 func TestReleasesForUpstream(t *testing.T) {
-	ref := release.RepoRef{
-		Owner: "joshmeranda",
-		Name:  "chartsutil-example-upstream",
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/joshmeranda/chartsutil-example-upstream/releases" {
+			http.NotFound(w, r)
+			return
+		}
+
+		// releases are split across two pages to exercise pagination
+		switch r.URL.Query().Get("page") {
+		case "", "1":
+			w.Header().Set("Link", fmt.Sprintf(`<%s%s?page=2>; rel="next", <%s%s?page=2>; rel="last"`, "http://"+r.Host, r.URL.Path, "http://"+r.Host, r.URL.Path))
+			fmt.Fprint(w, `[
+				{"name": "v0.0.2", "tag_name": "v0.0.2", "target_commitish": "main", "created_at": "2024-03-01T00:00:00Z", "draft": true},
+				{"name": "v0.0.1", "tag_name": "v0.0.1", "target_commitish": "main", "created_at": "2024-02-01T00:00:00Z"},
+				{"name": "nightly", "tag_name": "nightly", "target_commitish": "main", "created_at": "2024-01-20T00:00:00Z"}
+			]`)
+		case "2":
+			fmt.Fprint(w, `[
+				{"name": "", "tag_name": "v0.0.0", "target_commitish": "main", "created_at": "2024-01-01T00:00:00Z"},
+				{"name": "v0.0.0-alpha", "tag_name": "v0.0.0-alpha", "target_commitish": "main", "created_at": "2023-12-01T00:00:00Z"}
+			]`)
+		}
+	}))
+	defer server.Close()
+
+	// the releases report the branch they were cut from, which is resolved to the commit of their tag
+	upstream := fixture.NewUpstream(t)
+	upstream.CommitContent("A", "charts/example/Chart.yaml", "name: example\nversion: 1.0.0\nappVersion: v0.0.0\n")
+	upstream.CommitContent("B", "charts/example/Chart.yaml", "name: example\nversion: 1.1.0\nappVersion: v0.0.1\n", "A")
+	upstream.Tag("v0.0.0", "A")
+	upstream.AnnotatedTag("v0.0.1", "B", "release v0.0.1")
+
+	source, err := release.NewSource("https://github.com/joshmeranda/chartsutil-example-upstream.git", release.SourceOptions{
+		BaseURL:      server.URL,
+		Client:       server.Client(),
+		Repo:         upstream.Repo,
+		Subdirectory: "charts/example",
+	})
+	if err != nil {
+		t.Fatalf("failed to create source: %v", err)
 	}
 
 	query := release.ReleaseQuery{
-		// Since:       time.Time{},
+		Since:       time.Date(2023, 12, 15, 0, 0, 0, 0, time.UTC),
 		NamePattern: regexp.MustCompile(release.DefaultReleaseNamePattern),
 	}
 
-	actual, err := release.ReleasesForUpstream(context.Background(), ref, query)
+	actual, err := release.ReleasesForUpstream(context.Background(), source, query)
 	if err != nil {
 		t.Fatalf("failed to fetch releases: %v", err)
 	}
 
 	expected := []release.Release{
 		{
-			Name: "v0.0.1",
-			Hash: "main",
+			Name:         "v0.0.1",
+			Tag:          "v0.0.1",
+			Date:         time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+			Hash:         upstream.Hash("B").String(),
+			ChartVersion: "1.1.0",
+			AppVersion:   "v0.0.1",
+		},
+		{
+			Name:         "v0.0.0",
+			Tag:          "v0.0.0",
+			Date:         time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			Hash:         upstream.Hash("A").String(),
+			ChartVersion: "1.0.0",
+			AppVersion:   "v0.0.0",
+		},
+	}
+
+	assertReleases(t, expected, actual)
+}
+
+func TestResolvedSourceSkipsBadRelease(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[
+			{"name": "v0.0.1", "tag_name": "v0.0.1", "created_at": "2024-02-01T00:00:00Z"},
+			{"name": "v0.0.0", "tag_name": "v0.0.0", "created_at": "2024-01-01T00:00:00Z"}
+		]`)
+	}))
+	defer server.Close()
+
+	upstream := fixture.NewUpstream(t)
+	upstream.CommitContent("A", "charts/example/Chart.yaml", "name: example\nversion: [\n")
+	upstream.CommitContent("B", "charts/example/Chart.yaml", "name: example\nversion: 1.1.0\n", "A")
+	upstream.Tag("v0.0.0", "A")
+	upstream.Tag("v0.0.1", "B")
+
+	source, err := release.NewSource("https://github.com/joshmeranda/chartsutil-example-upstream.git", release.SourceOptions{
+		BaseURL:      server.URL,
+		Client:       server.Client(),
+		Repo:         upstream.Repo,
+		Subdirectory: "charts/example",
+		Logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	if err != nil {
+		t.Fatalf("failed to create source: %v", err)
+	}
+
+	actual, err := source.ListReleases(context.Background())
+	if err != nil {
+		t.Fatalf("expected release with malformed chart to be skipped but found: %v", err)
+	}
+
+	expected := []release.Release{
+		{
+			Name:         "v0.0.1",
+			Tag:          "v0.0.1",
+			Date:         time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+			Hash:         upstream.Hash("B").String(),
+			ChartVersion: "1.1.0",
+		},
+	}
+
+	assertReleases(t, expected, actual)
+}
+
+func TestNewSource(t *testing.T) {
+	type testCase struct {
+		Name     string
+		URL      string
+		Provider release.Provider
+		Expected release.ReleaseSource
+	}
+
+	cases := []testCase{
+		{
+			Name:     "GitHub",
+			URL:      "https://github.com/joshmeranda/chartsutil.git",
+			Expected: &release.GitHubSource{},
+		},
+		{
+			Name:     "GitHubEnterprise",
+			URL:      "https://github.example.com/joshmeranda/chartsutil.git",
+			Expected: &release.GitHubSource{},
+		},
+		{
+			Name:     "GitLab",
+			URL:      "https://gitlab.com/group/subgroup/chartsutil.git",
+			Expected: &release.GitLabSource{},
+		},
+		{
+			Name:     "SelfHostedGitLab",
+			URL:      "git@gitlab.example.com:group/chartsutil.git",
+			Expected: &release.GitLabSource{},
+		},
+		{
+			Name:     "Codeberg",
+			URL:      "https://codeberg.org/joshmeranda/chartsutil.git",
+			Expected: &release.GiteaSource{},
+		},
+		{
+			Name:     "UnknownHost",
+			URL:      "https://git.example.com/joshmeranda/chartsutil.git",
+			Expected: &release.GitSource{},
+		},
+		{
+			Name:     "ExplicitProvider",
+			URL:      "https://git.example.com/joshmeranda/chartsutil.git",
+			Provider: release.ProviderGitea,
+			Expected: &release.GiteaSource{},
+		},
+		{
+			Name:     "Local",
+			URL:      "file:///tmp/chartsutil.git",
+			Expected: &release.GitSource{},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			source, err := release.NewSource(c.URL, release.SourceOptions{Provider: c.Provider})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if reflect.TypeOf(source) != reflect.TypeOf(c.Expected) {
+				t.Errorf("expected a %T but found %T", c.Expected, source)
+			}
+		})
+	}
+
+	if _, err := release.NewSource("https://github.com/joshmeranda/chartsutil.git", release.SourceOptions{Provider: "unknown"}); err == nil {
+		t.Errorf("expected error for unknown provider")
+	}
+}
+
+func TestSourceAuth(t *testing.T) {
+	t.Setenv("CUSTOM_TOKEN", "secret")
+
+	type testCase struct {
+		Name     string
+		Provider release.Provider
+		Expected string
+	}
+
+	cases := []testCase{
+		{
+			Name:     "GitHub",
+			Provider: release.ProviderGitHub,
+			Expected: "Basic " + base64.StdEncoding.EncodeToString([]byte(auth.DefaultTokenUsername+":secret")),
 		},
 		{
-			Name: "v0.0.0",
-			Hash: "main",
+			Name:     "GitLab",
+			Provider: release.ProviderGitLab,
+			Expected: "Bearer secret",
+		},
+		{
+			Name:     "Gitea",
+			Provider: release.ProviderGitea,
+			Expected: "token secret",
+		},
+	}
+
+	config := &auth.Config{
+		Hosts: map[string]auth.HostConfig{
+			"127.0.0.1": {
+				Sources:  []auth.Source{auth.SourceEnv},
+				TokenEnv: []string{"CUSTOM_TOKEN"},
+			},
 		},
 	}
 
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			var actual string
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				actual = r.Header.Get("Authorization")
+				fmt.Fprint(w, "[]")
+			}))
+			defer server.Close()
+
+			source, err := release.NewSource("https://git.example.com/org/chart.git", release.SourceOptions{
+				Provider: c.Provider,
+				BaseURL:  server.URL,
+				Client:   config.HTTPClient(),
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if _, err := source.ListReleases(context.Background()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if actual != c.Expected {
+				t.Errorf("expected Authorization header '%s' but found '%s'", c.Expected, actual)
+			}
+		})
+	}
+}
+
+func assertReleases(t *testing.T, expected []release.Release, actual []release.Release) {
+	t.Helper()
+
 	if len(actual) != len(expected) {
-		t.Fatalf("expected %d releases but got %d", len(expected), len(actual))
+		t.Fatalf("expected %d releases but got %d: %+v", len(expected), len(actual), actual)
 	}
 
 	for i := 0; i < len(actual); i++ {
 		if actual[i].Name != expected[i].Name {
 			t.Errorf("expected release name %s but got %s", expected[i].Name, actual[i].Name)
 		}
+		if actual[i].Tag != expected[i].Tag {
+			t.Errorf("expected release tag %s but got %s", expected[i].Tag, actual[i].Tag)
+		}
+		if !actual[i].Date.Equal(expected[i].Date) {
+			t.Errorf("expected release date %s but got %s", expected[i].Date, actual[i].Date)
+		}
 		if actual[i].Hash != expected[i].Hash {
 			t.Errorf("expected release hash %s but got %s", expected[i].Hash, actual[i].Hash)
 		}
+		if actual[i].ChartVersion != expected[i].ChartVersion || actual[i].AppVersion != expected[i].AppVersion {
+			t.Errorf("expected chart version %s (app %s) but got %s (app %s)", expected[i].ChartVersion, expected[i].AppVersion, actual[i].ChartVersion, actual[i].AppVersion)
+		}
 	}
 }
@@ -0,0 +1,96 @@
+package release
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/joshmeranda/chartsutil/pkg/iter"
+)
+
+// ResolveRelease sets the hash of the release to the commit its tag points to in the upstream clone, and reads the chart version and appVersion from the Chart.yaml in subdirectory at that commit. Providers often report the branch a release was cut from rather than its commit, so a release whose tag is not in the clone only keeps a hash which is a full commit sha.
+func ResolveRelease(repo *git.Repository, subdirectory string, release *Release) error {
+	ref, err := repo.Tag(release.Tag)
+	if errors.Is(err, git.ErrTagNotFound) {
+		if !plumbing.IsHash(release.Hash) {
+			release.Hash = ""
+		}
+
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to find tag '%s': %w", release.Tag, err)
+	}
+
+	commit, err := peelTag(repo, ref)
+	if err != nil {
+		return err
+	}
+
+	release.Hash = commit.Hash.String()
+
+	chart, err := iter.ReadChartMetadata(commit, subdirectory)
+	if err != nil {
+		return fmt.Errorf("failed to read chart for release '%s': %w", release.Name, err)
+	}
+
+	release.ChartVersion = chart.Version
+	release.AppVersion = chart.AppVersion
+
+	return nil
+}
+
+// resolvedSource resolves the releases of a provider against a clone of the upstream.
+type resolvedSource struct {
+	ReleaseSource
+
+	repo         *git.Repository
+	subdirectory string
+	logger       *slog.Logger
+}
+
+func (s *resolvedSource) log() *slog.Logger {
+	if s.logger == nil {
+		return slog.Default()
+	}
+
+	return s.logger
+}
+
+func (s *resolvedSource) ListReleases(ctx context.Context) ([]Release, error) {
+	releases, err := s.ReleaseSource.ListReleases(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved := make([]Release, 0, len(releases))
+
+	for _, release := range releases {
+		// a single bad release (ex. a malformed Chart.yaml at an old tag) should not hide all the others
+		if err := ResolveRelease(s.repo, s.subdirectory, &release); errors.Is(err, errNotCommit) {
+			continue
+		} else if err != nil {
+			s.log().Warn("skipping release which could not be resolved", "release", release.Name, "err", err)
+			continue
+		}
+
+		resolved = append(resolved, release)
+	}
+
+	return resolved, nil
+}
+
+func (s *resolvedSource) GetRelease(ctx context.Context, tag string) (Release, error) {
+	release, err := s.ReleaseSource.GetRelease(ctx, tag)
+	if err != nil {
+		return Release{}, err
+	}
+
+	if err := ResolveRelease(s.repo, s.subdirectory, &release); err != nil {
+		return Release{}, err
+	}
+
+	return release, nil
+}
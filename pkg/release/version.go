@@ -0,0 +1,114 @@
+package release
+
+import (
+	"cmp"
+	"regexp"
+	"slices"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// versionPattern finds the version in a tag or release name, which may have a prefix like 'v' or the name of the chart.
+var versionPattern = regexp.MustCompile(`[0-9]+\.[0-9]+(\.[0-9]+)?(-[0-9A-Za-z.-]+)?(\+[0-9A-Za-z.-]+)?`)
+
+// ParseVersion parses the semantic version in a tag or release name (ex. 'v1.2.3' or 'chart-1.2.3-rc.1'), returning nil if there is none.
+func ParseVersion(name string) *semver.Version {
+	s := versionPattern.FindString(name)
+	if s == "" {
+		return nil
+	}
+
+	v, err := semver.NewVersion(s)
+	if err != nil {
+		return nil
+	}
+
+	return v
+}
+
+// Version returns the semantic version of the release from its tag, or its name if the tag has none.
+func (r Release) Version() *semver.Version {
+	if v := ParseVersion(r.Tag); v != nil {
+		return v
+	}
+
+	return ParseVersion(r.Name)
+}
+
+// IsPrerelease reports whether the release is marked as a prerelease by its provider or has a prerelease version.
+func (r Release) IsPrerelease() bool {
+	if r.Prerelease {
+		return true
+	}
+
+	v := r.Version()
+
+	return v != nil && v.Prerelease() != ""
+}
+
+// LatestTag returns the tag with the highest version among the tags matching pattern (or all tags if pattern is nil), or an empty string if none have a version.
+func LatestTag(tags []string, pattern *regexp.Regexp) string {
+	var latest string
+	var latestVersion *semver.Version
+
+	for _, tag := range tags {
+		if pattern != nil && !pattern.MatchString(tag) {
+			continue
+		}
+
+		if v := ParseVersion(tag); v != nil && (latestVersion == nil || v.GreaterThan(latestVersion)) {
+			latest, latestVersion = tag, v
+		}
+	}
+
+	return latest
+}
+
+// SortReleases sorts releases from the highest version to the lowest. Releases without a version are sorted after those with one, from newest to oldest.
+func SortReleases(releases []Release) {
+	slices.SortStableFunc(releases, func(a, b Release) int {
+		va, vb := a.Version(), b.Version()
+
+		switch {
+		case va != nil && vb != nil:
+			if c := vb.Compare(va); c != 0 {
+				return c
+			}
+		case va != nil:
+			return -1
+		case vb != nil:
+			return 1
+		}
+
+		return cmp.Compare(b.Date.UnixNano(), a.Date.UnixNano())
+	})
+}
+
+// Bump is the kind of semantic version change between two versions.
+type Bump string
+
+const (
+	BumpMajor      Bump = "major"
+	BumpMinor      Bump = "minor"
+	BumpPatch      Bump = "patch"
+	BumpPrerelease Bump = "prerelease"
+
+	// BumpNone is used when the versions are unknown, or the new version is not higher.
+	BumpNone Bump = ""
+)
+
+// BumpKind returns the kind of change from one version to another.
+func BumpKind(from *semver.Version, to *semver.Version) Bump {
+	switch {
+	case from == nil || to == nil || !to.GreaterThan(from):
+		return BumpNone
+	case to.Major() != from.Major():
+		return BumpMajor
+	case to.Minor() != from.Minor():
+		return BumpMinor
+	case to.Patch() != from.Patch():
+		return BumpPatch
+	default:
+		return BumpPrerelease
+	}
+}
@@ -0,0 +1,203 @@
+package release_test
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/joshmeranda/chartsutil/pkg/release"
+)
+
+func TestParseVersion(t *testing.T) {
+	type testCase struct {
+		Name     string
+		Expected string
+	}
+
+	cases := []testCase{
+		{Name: "v1.2.3", Expected: "1.2.3"},
+		{Name: "1.2.3-rc.1", Expected: "1.2.3-rc.1"},
+		{Name: "kube-prometheus-stack-55.0.0", Expected: "55.0.0"},
+		{Name: "v1.2", Expected: "1.2.0"},
+		{Name: "nightly", Expected: ""},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			v := release.ParseVersion(c.Name)
+
+			actual := ""
+			if v != nil {
+				actual = v.String()
+			}
+
+			if actual != c.Expected {
+				t.Errorf("expected version '%s' but found '%s'", c.Expected, actual)
+			}
+		})
+	}
+}
+
+func TestLatestTag(t *testing.T) {
+	type testCase struct {
+		Name     string
+		Tags     []string
+		Pattern  *regexp.Regexp
+		Expected string
+	}
+
+	cases := []testCase{
+		{Name: "Highest", Tags: []string{"v1.2.0", "v1.10.0", "v1.9.0"}, Expected: "v1.10.0"},
+		{Name: "SkipsUnversioned", Tags: []string{"latest", "v1.0.0"}, Expected: "v1.0.0"},
+		{Name: "NoVersions", Tags: []string{"latest", "stable"}, Expected: ""},
+		{Name: "NoTags", Expected: ""},
+		{
+			Name:     "Pattern",
+			Tags:     []string{"other-chart-2.0.0", "example-1.0.0"},
+			Pattern:  regexp.MustCompile(`^example-`),
+			Expected: "example-1.0.0",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			if actual := release.LatestTag(c.Tags, c.Pattern); actual != c.Expected {
+				t.Errorf("expected tag '%s' but found '%s'", c.Expected, actual)
+			}
+		})
+	}
+}
+
+func TestQueryMatches(t *testing.T) {
+	type testCase struct {
+		Name     string
+		Release  release.Release
+		Query    release.ReleaseQuery
+		Expected bool
+	}
+
+	constraint, err := semver.NewConstraint(">=55.0.0 <56")
+	if err != nil {
+		t.Fatalf("failed to parse constraint: %v", err)
+	}
+
+	date := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	cases := []testCase{
+		{
+			Name:     "Matches",
+			Release:  release.Release{Tag: "v55.1.0", Date: date},
+			Query:    release.ReleaseQuery{Constraint: constraint},
+			Expected: true,
+		},
+		{
+			Name:     "OutsideConstraint",
+			Release:  release.Release{Tag: "v56.0.0", Date: date},
+			Query:    release.ReleaseQuery{Constraint: constraint},
+			Expected: false,
+		},
+		{
+			Name:     "NoVersion",
+			Release:  release.Release{Tag: "nightly", Date: date},
+			Query:    release.ReleaseQuery{Constraint: constraint},
+			Expected: false,
+		},
+		{
+			Name:     "Draft",
+			Release:  release.Release{Tag: "v55.1.0", Date: date, Draft: true},
+			Query:    release.ReleaseQuery{},
+			Expected: false,
+		},
+		{
+			Name:     "Prerelease",
+			Release:  release.Release{Tag: "v55.1.0-rc.1", Date: date},
+			Query:    release.ReleaseQuery{Constraint: constraint},
+			Expected: false,
+		},
+		{
+			Name:     "MarkedPrerelease",
+			Release:  release.Release{Tag: "v55.1.0", Date: date, Prerelease: true},
+			Query:    release.ReleaseQuery{},
+			Expected: false,
+		},
+		{
+			Name:     "IncludePrerelease",
+			Release:  release.Release{Tag: "v55.1.0-rc.1", Date: date},
+			Query:    release.ReleaseQuery{Constraint: constraint, IncludePrereleases: true},
+			Expected: true,
+		},
+		{
+			Name:     "Through",
+			Release:  release.Release{Tag: "v55.1.0", Date: date},
+			Query:    release.ReleaseQuery{NewerThan: semver.MustParse("55.0.0"), Through: semver.MustParse("55.1.0")},
+			Expected: true,
+		},
+		{
+			Name:     "AfterThrough",
+			Release:  release.Release{Tag: "v55.2.0", Date: date},
+			Query:    release.ReleaseQuery{Through: semver.MustParse("55.1.0")},
+			Expected: false,
+		},
+		{
+			Name:     "BeforeSince",
+			Release:  release.Release{Tag: "v55.1.0", Date: date},
+			Query:    release.ReleaseQuery{Since: date},
+			Expected: false,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			if actual := c.Query.Matches(c.Release); actual != c.Expected {
+				t.Errorf("expected %t but found %t", c.Expected, actual)
+			}
+		})
+	}
+}
+
+func TestSortReleases(t *testing.T) {
+	releases := []release.Release{
+		{Tag: "nightly", Date: time.Unix(1, 0)},
+		{Tag: "v1.10.0", Date: time.Unix(2, 0)},
+		{Tag: "v1.2.0", Date: time.Unix(5, 0)},
+		{Tag: "latest", Date: time.Unix(4, 0)},
+		{Tag: "v2.0.0-rc.1", Date: time.Unix(3, 0)},
+	}
+
+	release.SortReleases(releases)
+
+	expected := []string{"v2.0.0-rc.1", "v1.10.0", "v1.2.0", "latest", "nightly"}
+
+	for i, r := range releases {
+		if r.Tag != expected[i] {
+			t.Errorf("expected '%s' at %d but found '%s'", expected[i], i, r.Tag)
+		}
+	}
+}
+
+func TestBumpKind(t *testing.T) {
+	type testCase struct {
+		Name     string
+		From     string
+		To       string
+		Expected release.Bump
+	}
+
+	cases := []testCase{
+		{Name: "Major", From: "1.2.3", To: "2.0.0", Expected: release.BumpMajor},
+		{Name: "Minor", From: "1.2.3", To: "1.3.0", Expected: release.BumpMinor},
+		{Name: "Patch", From: "1.2.3", To: "1.2.4", Expected: release.BumpPatch},
+		{Name: "Prerelease", From: "1.2.3-rc.1", To: "1.2.3", Expected: release.BumpPrerelease},
+		{Name: "Older", From: "1.2.3", To: "1.2.2", Expected: release.BumpNone},
+		{Name: "Unknown", From: "", To: "1.2.2", Expected: release.BumpNone},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			if actual := release.BumpKind(release.ParseVersion(c.From), release.ParseVersion(c.To)); actual != c.Expected {
+				t.Errorf("expected '%s' but found '%s'", c.Expected, actual)
+			}
+		})
+	}
+}
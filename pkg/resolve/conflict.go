@@ -0,0 +1,208 @@
+package resolve
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	markerOurs   = "<<<<<<<"
+	markerBase   = "|||||||"
+	markerSplit  = "======="
+	markerTheirs = ">>>>>>>"
+)
+
+type Choice int
+
+const (
+	ChoiceOurs Choice = iota
+	ChoiceTheirs
+	ChoiceBoth
+)
+
+// Hunk is a single conflicting region of a file.
+type Hunk struct {
+	OursLabel   string
+	TheirsLabel string
+
+	Ours   []string
+	Theirs []string
+
+	// Base is only populated when the conflict was written with the diff3 or zdiff3 conflict styles.
+	Base    []string
+	HasBase bool
+}
+
+// Lines returns the lines resulting from resolving the hunk with the given choice.
+func (h *Hunk) Lines(choice Choice) []string {
+	switch choice {
+	case ChoiceOurs:
+		return h.Ours
+	case ChoiceTheirs:
+		return h.Theirs
+	default:
+		return append(append([]string{}, h.Ours...), h.Theirs...)
+	}
+}
+
+// String returns the hunk with its conflict markers as git would have written it.
+func (h *Hunk) String() string {
+	b := strings.Builder{}
+
+	b.WriteString(strings.TrimSpace(markerOurs+" "+h.OursLabel) + "\n")
+	for _, line := range h.Ours {
+		b.WriteString(line + "\n")
+	}
+
+	if h.HasBase {
+		b.WriteString(markerBase + "\n")
+		for _, line := range h.Base {
+			b.WriteString(line + "\n")
+		}
+	}
+
+	b.WriteString(markerSplit + "\n")
+	for _, line := range h.Theirs {
+		b.WriteString(line + "\n")
+	}
+	b.WriteString(strings.TrimSpace(markerTheirs+" "+h.TheirsLabel) + "\n")
+
+	return b.String()
+}
+
+// Chunk is a section of a file, which is either a list of plain lines or a conflicting hunk.
+type Chunk struct {
+	Lines []string
+	Hunk  *Hunk
+}
+
+// ConflictFile is a file containing conflict markers split into chunks. Lines keep any carriage return of their line ending, so files with CRLF line endings are written back unchanged.
+type ConflictFile struct {
+	Chunks []Chunk
+
+	// FinalNewline is true if the last line of the file ended with a newline.
+	FinalNewline bool
+}
+
+// Hunks returns the conflicting hunks of the file in order.
+func (f ConflictFile) Hunks() []*Hunk {
+	hunks := make([]*Hunk, 0)
+	for _, chunk := range f.Chunks {
+		if chunk.Hunk != nil {
+			hunks = append(hunks, chunk.Hunk)
+		}
+	}
+
+	return hunks
+}
+
+// Resolve joins the file back together using the lines from resolved for each hunk in order.
+func (f ConflictFile) Resolve(resolved [][]string) ([]byte, error) {
+	if expected := len(f.Hunks()); len(resolved) != expected {
+		return nil, fmt.Errorf("expected %d resolved hunks but found %d", expected, len(resolved))
+	}
+
+	lines := make([]string, 0)
+	i := 0
+
+	for _, chunk := range f.Chunks {
+		if chunk.Hunk != nil {
+			lines = append(lines, resolved[i]...)
+			i++
+		} else {
+			lines = append(lines, chunk.Lines...)
+		}
+	}
+
+	data := strings.Join(lines, "\n")
+	if f.FinalNewline && len(lines) > 0 {
+		data += "\n"
+	}
+
+	return []byte(data), nil
+}
+
+type parseState int
+
+const (
+	parseStatePlain parseState = iota
+	parseStateOurs
+	parseStateBase
+	parseStateTheirs
+)
+
+// ParseConflicts splits the data into chunks of plain lines and conflicting hunks.
+func ParseConflicts(data []byte) (ConflictFile, error) {
+	file := ConflictFile{Chunks: make([]Chunk, 0)}
+	plain := make([]string, 0)
+	state := parseStatePlain
+
+	var hunk *Hunk
+
+	text := string(data)
+	if strings.HasSuffix(text, "\n") {
+		file.FinalNewline = true
+		text = strings.TrimSuffix(text, "\n")
+	}
+
+	lineNo := 0
+	for _, line := range strings.Split(text, "\n") {
+		lineNo++
+
+		switch {
+		case state == parseStatePlain && isMarker(line, markerOurs):
+			if len(plain) > 0 {
+				file.Chunks = append(file.Chunks, Chunk{Lines: plain})
+				plain = make([]string, 0)
+			}
+
+			hunk = &Hunk{OursLabel: strings.TrimSpace(strings.TrimPrefix(line, markerOurs))}
+			state = parseStateOurs
+		case state == parseStateOurs && isMarker(line, markerBase):
+			hunk.HasBase = true
+			state = parseStateBase
+		case (state == parseStateOurs || state == parseStateBase) && strings.TrimSuffix(line, "\r") == markerSplit:
+			state = parseStateTheirs
+		case state == parseStateTheirs && isMarker(line, markerTheirs):
+			hunk.TheirsLabel = strings.TrimSpace(strings.TrimPrefix(line, markerTheirs))
+			file.Chunks = append(file.Chunks, Chunk{Hunk: hunk})
+			hunk = nil
+			state = parseStatePlain
+		case state == parseStateOurs:
+			hunk.Ours = append(hunk.Ours, line)
+		case state == parseStateBase:
+			hunk.Base = append(hunk.Base, line)
+		case state == parseStateTheirs:
+			hunk.Theirs = append(hunk.Theirs, line)
+		default:
+			plain = append(plain, line)
+		}
+	}
+
+	if state != parseStatePlain {
+		return ConflictFile{}, fmt.Errorf("found unterminated conflict at end of file (line %d)", lineNo)
+	}
+
+	if len(plain) > 0 {
+		file.Chunks = append(file.Chunks, Chunk{Lines: plain})
+	}
+
+	return file, nil
+}
+
+// isMarker returns true if the line starts with the given marker followed by a space or the end of the line, ignoring any carriage return.
+func isMarker(line string, marker string) bool {
+	line = strings.TrimSuffix(line, "\r")
+	return line == marker || strings.HasPrefix(line, marker+" ")
+}
+
+// HasConflictMarkers returns true if any line of data starts with the opening or closing conflict markers.
+func HasConflictMarkers(data []byte) bool {
+	for _, line := range strings.Split(string(data), "\n") {
+		if isMarker(line, markerOurs) || isMarker(line, markerTheirs) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,172 @@
+package resolve_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/joshmeranda/chartsutil/pkg/resolve"
+)
+
+const conflictContent = `apiVersion: v2
+name: example
+<<<<<<< HEAD
+version: 1.0.0
+||||||| base
+version: 0.9.0
+=======
+version: 1.1.0
+>>>>>>> charts-staging
+description: an example chart
+<<<<<<< HEAD
+appVersion: 1.0.0
+=======
+appVersion: 1.1.0
+>>>>>>> charts-staging
+`
+
+func TestParseConflicts(t *testing.T) {
+	conflicts, err := resolve.ParseConflicts([]byte(conflictContent))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	hunks := conflicts.Hunks()
+	if len(hunks) != 2 {
+		t.Fatalf("expected 2 hunks but found %d", len(hunks))
+	}
+
+	if !hunks[0].HasBase || !slices.Equal(hunks[0].Base, []string{"version: 0.9.0"}) {
+		t.Errorf("expected first hunk to have base, found %+v", hunks[0])
+	}
+
+	if hunks[1].HasBase {
+		t.Errorf("expected second hunk to not have base, found %+v", hunks[1])
+	}
+
+	if hunks[1].OursLabel != "HEAD" || hunks[1].TheirsLabel != "charts-staging" {
+		t.Errorf("unexpected hunk labels: %+v", hunks[1])
+	}
+
+	actual, err := conflicts.Resolve([][]string{hunks[0].Lines(resolve.ChoiceTheirs), hunks[1].Lines(resolve.ChoiceBoth)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := `apiVersion: v2
+name: example
+version: 1.1.0
+description: an example chart
+appVersion: 1.0.0
+appVersion: 1.1.0
+`
+
+	if string(actual) != expected {
+		t.Errorf("resolved file does not match expected value:\nExpected: '%s'\n   Found: '%s'", expected, actual)
+	}
+
+	if resolve.HasConflictMarkers(actual) {
+		t.Errorf("resolved file should not have conflict markers")
+	}
+}
+
+func TestParseConflictsLineEndings(t *testing.T) {
+	type Case struct {
+		Name     string
+		Content  string
+		Expected string
+	}
+
+	cases := []Case{
+		{
+			Name:     "NoFinalNewline",
+			Content:  "name: example\n<<<<<<< HEAD\nversion: 1.0.0\n=======\nversion: 1.1.0\n>>>>>>> charts-staging",
+			Expected: "name: example\nversion: 1.1.0",
+		},
+		{
+			Name:     "CRLF",
+			Content:  "name: example\r\n<<<<<<< HEAD\r\nversion: 1.0.0\r\n=======\r\nversion: 1.1.0\r\n>>>>>>> charts-staging\r\ndescription: an example chart\r\n",
+			Expected: "name: example\r\nversion: 1.1.0\r\ndescription: an example chart\r\n",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			conflicts, err := resolve.ParseConflicts([]byte(c.Content))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			hunks := conflicts.Hunks()
+			if len(hunks) != 1 {
+				t.Fatalf("expected 1 hunk but found %d", len(hunks))
+			}
+
+			if hunks[0].TheirsLabel != "charts-staging" {
+				t.Errorf("unexpected hunk labels: %+v", hunks[0])
+			}
+
+			actual, err := conflicts.Resolve([][]string{hunks[0].Lines(resolve.ChoiceTheirs)})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if string(actual) != c.Expected {
+				t.Errorf("resolved file does not match expected value:\nExpected: %q\n   Found: %q", c.Expected, actual)
+			}
+		})
+	}
+}
+
+func TestParseConflictsUnterminated(t *testing.T) {
+	if _, err := resolve.ParseConflicts([]byte("<<<<<<< HEAD\nversion: 1.0.0\n=======\n")); err == nil {
+		t.Fatalf("expected error but found none")
+	}
+}
+
+func TestHasConflictMarkers(t *testing.T) {
+	type Case struct {
+		Name     string
+		Content  string
+		Expected bool
+	}
+
+	cases := []Case{
+		{
+			Name:     "Conflict",
+			Content:  conflictContent,
+			Expected: true,
+		},
+		{
+			Name:     "MarkdownHeader",
+			Content:  "Title\n=======\n",
+			Expected: false,
+		},
+		{
+			Name:     "NoConflict",
+			Content:  "apiVersion: v2\n",
+			Expected: false,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			if actual := resolve.HasConflictMarkers([]byte(c.Content)); actual != c.Expected {
+				t.Errorf("expected %t but got %t", c.Expected, actual)
+			}
+		})
+	}
+}
+
+func TestRenderSideBySide(t *testing.T) {
+	actual := resolve.RenderSideBySide(23, []string{"ours", "theirs"}, [][]string{{"version: 1.0.0"}, {"version: 1.1.0", "extra"}})
+
+	expected := `ours       | theirs
+---------- | ----------
+version: … | version: …
+           | extra
+`
+
+	if actual != expected {
+		t.Errorf("rendered table does not match expected value:\nExpected: '%s'\n   Found: '%s'", expected, actual)
+	}
+}
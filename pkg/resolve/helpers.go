@@ -0,0 +1,159 @@
+package resolve
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	chartspath "github.com/rancher/charts-build-scripts/pkg/path"
+)
+
+// Environment variables used to pass the current Step to the helper commands of the interactive shell.
+const (
+	EnvStepIndex          = "CHARTSUTIL_STEP"
+	EnvStepTotal          = "CHARTSUTIL_STEP_TOTAL"
+	EnvStepUpstream       = "CHARTSUTIL_UPSTREAM"
+	EnvStepUpstreamCommit = "CHARTSUTIL_UPSTREAM_COMMIT"
+	EnvStepPreviousCommit = "CHARTSUTIL_PREVIOUS_UPSTREAM_COMMIT"
+	EnvStepSkippable      = "CHARTSUTIL_SKIPPABLE"
+)
+
+// emptyTreeHash is the well known hash of git's empty tree, used to diff against when there is no previous upstream.
+const emptyTreeHash = "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
+
+// StepEnv returns the environment variables describing the given step.
+func StepEnv(step Step) []string {
+	return []string{
+		fmt.Sprintf("%s=%d", EnvStepIndex, step.Index),
+		fmt.Sprintf("%s=%d", EnvStepTotal, step.Total),
+		fmt.Sprintf("%s=%s", EnvStepUpstream, step.Upstream),
+		fmt.Sprintf("%s=%s", EnvStepUpstreamCommit, step.UpstreamCommit),
+		fmt.Sprintf("%s=%s", EnvStepPreviousCommit, step.PreviousUpstreamCommit),
+		fmt.Sprintf("%s=%t", EnvStepSkippable, step.Skippable),
+	}
+}
+
+// StepFromEnv reads the step exported by StepEnv from the current environment.
+func StepFromEnv() (Step, error) {
+	upstream, ok := os.LookupEnv(EnvStepUpstream)
+	if !ok {
+		return Step{}, fmt.Errorf("not running in an interactive rebase shell")
+	}
+
+	index, err := strconv.Atoi(os.Getenv(EnvStepIndex))
+	if err != nil {
+		return Step{}, fmt.Errorf("invalid value for %s: %w", EnvStepIndex, err)
+	}
+
+	total, err := strconv.Atoi(os.Getenv(EnvStepTotal))
+	if err != nil {
+		return Step{}, fmt.Errorf("invalid value for %s: %w", EnvStepTotal, err)
+	}
+
+	skippable, _ := strconv.ParseBool(os.Getenv(EnvStepSkippable))
+
+	return Step{
+		Index:                  index,
+		Total:                  total,
+		Upstream:               upstream,
+		UpstreamCommit:         plumbing.NewHash(os.Getenv(EnvStepUpstreamCommit)),
+		PreviousUpstreamCommit: plumbing.NewHash(os.Getenv(EnvStepPreviousCommit)),
+		Skippable:              skippable,
+	}, nil
+}
+
+// String returns a short description of the step like "step 2 of 5: <upstream>".
+func (s Step) String() string {
+	if s.Total == 0 {
+		return fmt.Sprintf("step %d: %s", s.Index, s.Upstream)
+	}
+
+	return fmt.Sprintf("step %d of %d: %s", s.Index, s.Total, s.Upstream)
+}
+
+// Conflicts lists the files in the git repository at dir which still have unresolved merge conflicts.
+func Conflicts(dir string) ([]string, error) {
+	// go-git does not report unmerged index entries, so we need to ask git directly
+	cmd := exec.Command("git", "diff", "--name-only", "--diff-filter=U")
+	cmd.Dir = dir
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("could not list conflicting files: %w", err)
+	}
+
+	files := make([]string, 0)
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			files = append(files, line)
+		}
+	}
+
+	return files, nil
+}
+
+// UpstreamDiff writes the changes made to file between the previous and current upstream of the step to w.
+func UpstreamDiff(w io.Writer, step Step, file string) error {
+	if step.UpstreamCommit.IsZero() {
+		return fmt.Errorf("no upstream commit is available for the current step")
+	}
+
+	from := step.PreviousUpstreamCommit.String()
+	if step.PreviousUpstreamCommit.IsZero() {
+		from = emptyTreeHash
+	}
+
+	cmd := exec.Command("git", "diff", from, step.UpstreamCommit.String(), "--", file)
+	cmd.Stdout = w
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("could not diff upstream changes: %w", err)
+	}
+
+	return nil
+}
+
+// PatchDiff writes the generated changes of the package at pkgDir for the given file in the chart working directory to w.
+func PatchDiff(w io.Writer, pkgDir string, workingDir string, file string) error {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return fmt.Errorf("could not determine absolute path of '%s': %w", file, err)
+	}
+
+	rel, err := filepath.Rel(filepath.Join(pkgDir, workingDir), abs)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return fmt.Errorf("file '%s' is not in the chart working directory '%s'", file, workingDir)
+	}
+
+	changesDir := filepath.Join(pkgDir, chartspath.GeneratedChangesDir)
+
+	patch := filepath.Join(changesDir, chartspath.GeneratedChangesPatchDir, rel+".patch")
+	if data, err := os.ReadFile(patch); err == nil {
+		_, err := w.Write(data)
+		return err
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("could not read patch file: %w", err)
+	}
+
+	overlay := filepath.Join(changesDir, chartspath.GeneratedChangesOverlayDir, rel)
+	if _, err := os.Stat(overlay); err == nil {
+		_, err := fmt.Fprintf(w, "%s is added by overlay %s\n", rel, overlay)
+		return err
+	}
+
+	exclude := filepath.Join(changesDir, chartspath.GeneratedChangesExcludeDir, rel)
+	if _, err := os.Stat(exclude); err == nil {
+		_, err := fmt.Fprintf(w, "%s is removed by exclude %s\n", rel, exclude)
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "no generated changes for %s\n", rel)
+	return err
+}
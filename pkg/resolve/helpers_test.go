@@ -0,0 +1,116 @@
+package resolve_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/joshmeranda/chartsutil/pkg/resolve"
+)
+
+func TestStepEnv(t *testing.T) {
+	expected := resolve.Step{
+		Index:                  2,
+		Total:                  5,
+		Upstream:               "https://github.com/joshmeranda/chartsutil-example-upstream.git@SOME_COMMIT",
+		UpstreamCommit:         plumbing.NewHash("933d8b2975efa50cda4dca6234e5e522b8f58cdc"),
+		PreviousUpstreamCommit: plumbing.NewHash("553ab27381dbc13c63c92ffb35f5c7634b52dd26"),
+		Skippable:              true,
+	}
+
+	for _, env := range resolve.StepEnv(expected) {
+		key, value, _ := strings.Cut(env, "=")
+		t.Setenv(key, value)
+	}
+
+	actual, err := resolve.StepFromEnv()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("step does not match expected value:\nExpected: %+v\n   Found: %+v", expected, actual)
+	}
+
+	if s := actual.String(); !strings.HasPrefix(s, "step 2 of 5: ") {
+		t.Errorf("unexpected step string: %s", s)
+	}
+}
+
+func TestPatchDiff(t *testing.T) {
+	type Case struct {
+		Name     string
+		File     string
+		Expected string
+		Err      bool
+	}
+
+	pkgDir := t.TempDir()
+
+	files := map[string]string{
+		"generated-changes/patch/templates/deployment.yaml.patch": "--- a/templates/deployment.yaml\n+++ b/templates/deployment.yaml\n",
+		"generated-changes/overlay/templates/extra.yaml":          "kind: ConfigMap\n",
+		"generated-changes/exclude/templates/removed.yaml":        "kind: Secret\n",
+	}
+
+	for name, content := range files {
+		path := filepath.Join(pkgDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+
+	cases := []Case{
+		{
+			Name:     "Patch",
+			File:     filepath.Join(pkgDir, "charts", "templates", "deployment.yaml"),
+			Expected: files["generated-changes/patch/templates/deployment.yaml.patch"],
+		},
+		{
+			Name:     "Overlay",
+			File:     filepath.Join(pkgDir, "charts", "templates", "extra.yaml"),
+			Expected: "templates/extra.yaml is added by overlay",
+		},
+		{
+			Name:     "Exclude",
+			File:     filepath.Join(pkgDir, "charts", "templates", "removed.yaml"),
+			Expected: "templates/removed.yaml is removed by exclude",
+		},
+		{
+			Name:     "Unchanged",
+			File:     filepath.Join(pkgDir, "charts", "values.yaml"),
+			Expected: "no generated changes for values.yaml",
+		},
+		{
+			Name: "OutsideWorkingDir",
+			File: filepath.Join(pkgDir, "package.yaml"),
+			Err:  true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			b := strings.Builder{}
+
+			err := resolve.PatchDiff(&b, pkgDir, "charts", c.File)
+			if c.Err {
+				if err == nil {
+					t.Fatalf("expected error but found none")
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !strings.HasPrefix(b.String(), c.Expected) {
+				t.Errorf("expected output to start with %q, got %q", c.Expected, b.String())
+			}
+		})
+	}
+}
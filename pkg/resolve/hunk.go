@@ -0,0 +1,397 @@
+package resolve
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-git/go-git/v5"
+	"golang.org/x/term"
+)
+
+const (
+	// DefaultTerminalWidth is used when the width of the terminal cannot be determined.
+	DefaultTerminalWidth = 120
+
+	// hunkContextLines is the number of unconflicted lines shown before each hunk.
+	hunkContextLines = 3
+
+	columnSeparator = " | "
+)
+
+// HunkResolver walks the user through each conflicting hunk in the terminal, showing each side of the conflict next to each other and letting them pick which to keep.
+type HunkResolver struct {
+	Logger *slog.Logger
+
+	// Fallback is used instead when stdin is not a terminal, and for conflicts without conflict markers.
+	Fallback Resolver
+
+	// In and Out default to os.Stdin and os.Stdout, when In is set the resolver will not check for a terminal.
+	In  io.Reader
+	Out io.Writer
+
+	step Step
+}
+
+func (h *HunkResolver) SetStep(step Step) {
+	h.step = step
+
+	if sr, ok := h.Fallback.(StepResolver); ok {
+		sr.SetStep(step)
+	}
+}
+
+func (h *HunkResolver) out() io.Writer {
+	if h.Out == nil {
+		return os.Stdout
+	}
+
+	return h.Out
+}
+
+func (h *HunkResolver) logger() *slog.Logger {
+	if h.Logger == nil {
+		return slog.Default()
+	}
+
+	return h.Logger
+}
+
+func (h *HunkResolver) width() int {
+	if h.Out != nil {
+		return DefaultTerminalWidth
+	}
+
+	width, _, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width <= 0 {
+		return DefaultTerminalWidth
+	}
+
+	return width
+}
+
+// splitByMarkers splits the conflicted files into those which contain conflict markers and those which do not, including any which were deleted.
+func splitByMarkers(root string, files []string) ([]string, []string, error) {
+	marked := make([]string, 0)
+	unmarked := make([]string, 0)
+
+	for _, file := range files {
+		data, err := os.ReadFile(filepath.Join(root, file))
+		if errors.Is(err, os.ErrNotExist) {
+			unmarked = append(unmarked, file)
+			continue
+		} else if err != nil {
+			return nil, nil, fmt.Errorf("failed to read file %s: %w", file, err)
+		}
+
+		if HasConflictMarkers(data) {
+			marked = append(marked, file)
+		} else {
+			unmarked = append(unmarked, file)
+		}
+	}
+
+	return marked, unmarked, nil
+}
+
+// fit pads or truncates s to exactly width runes.
+func fit(s string, width int) string {
+	s = strings.ReplaceAll(strings.TrimSuffix(s, "\r"), "\t", "    ")
+
+	n := utf8.RuneCountInString(s)
+	if n > width {
+		if width <= 1 {
+			return string([]rune(s)[:width])
+		}
+
+		return string([]rune(s)[:width-1]) + "…"
+	}
+
+	return s + strings.Repeat(" ", width-n)
+}
+
+// RenderSideBySide renders each column next to each other, fitting the whole table into width.
+func RenderSideBySide(width int, headers []string, columns [][]string) string {
+	n := len(columns)
+	if n == 0 {
+		return ""
+	}
+
+	colWidth := max((width-(n-1)*len(columnSeparator))/n, 1)
+
+	rows := 0
+	for _, column := range columns {
+		rows = max(rows, len(column))
+	}
+
+	b := strings.Builder{}
+
+	cells := make([]string, n)
+	for i := range columns {
+		cells[i] = fit(headers[i], colWidth)
+	}
+	b.WriteString(strings.TrimRight(strings.Join(cells, columnSeparator), " ") + "\n")
+
+	for i := range columns {
+		cells[i] = strings.Repeat("-", colWidth)
+	}
+	b.WriteString(strings.Join(cells, columnSeparator) + "\n")
+
+	for row := 0; row < rows; row++ {
+		for i, column := range columns {
+			line := ""
+			if row < len(column) {
+				line = column[row]
+			}
+
+			cells[i] = fit(line, colWidth)
+		}
+
+		b.WriteString(strings.TrimRight(strings.Join(cells, columnSeparator), " ") + "\n")
+	}
+
+	return b.String()
+}
+
+func (h *HunkResolver) renderHunk(hunk *Hunk, context []string) string {
+	b := strings.Builder{}
+
+	for _, line := range context {
+		b.WriteString("  " + strings.TrimSuffix(line, "\r") + "\n")
+	}
+
+	headers := []string{"ours " + hunk.OursLabel}
+	columns := [][]string{hunk.Ours}
+
+	if hunk.HasBase {
+		headers = append(headers, "base")
+		columns = append(columns, hunk.Base)
+	}
+
+	headers = append(headers, "theirs "+hunk.TheirsLabel)
+	columns = append(columns, hunk.Theirs)
+
+	b.WriteString(RenderSideBySide(h.width(), headers, columns))
+
+	return b.String()
+}
+
+// edit opens the hunk in the user's editor and returns the lines they saved.
+func (h *HunkResolver) edit(hunk *Hunk) ([]string, error) {
+	f, err := os.CreateTemp("", "chartsutil-hunk-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString(hunk.String()); err != nil {
+		return nil, fmt.Errorf("failed to write hunk to temp file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close temp file: %w", err)
+	}
+
+	if err := RunEditor(f.Name()); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read edited hunk: %w", err)
+	}
+
+	if HasConflictMarkers(data) {
+		return nil, fmt.Errorf("edited hunk still contains conflict markers")
+	}
+
+	content := strings.TrimSuffix(string(data), "\n")
+	if content == "" {
+		return []string{}, nil
+	}
+
+	return strings.Split(content, "\n"), nil
+}
+
+// RunEditor opens path in the editor from $VISUAL or $EDITOR, defaulting to vi.
+func RunEditor(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	// the editor may contain arguments (ex 'code --wait')
+	args := append(strings.Fields(editor), path)
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("editor '%s' failed: %w", editor, err)
+	}
+
+	return nil
+}
+
+func (h *HunkResolver) prompt() string {
+	options := "[o]urs, [t]heirs, [b]oth, [e]dit, [a]bort"
+	if h.step.Skippable {
+		options += ", [s]kip upstream"
+	}
+
+	return options + "? "
+}
+
+// resolveHunk prompts the user until they have chosen how to resolve the hunk.
+func (h *HunkResolver) resolveHunk(in *bufio.Reader, hunk *Hunk, context []string) ([]string, error) {
+	fmt.Fprint(h.out(), h.renderHunk(hunk, context))
+
+	for {
+		fmt.Fprint(h.out(), h.prompt())
+
+		answer, err := in.ReadString('\n')
+		if err != nil && answer == "" {
+			if err == io.EOF {
+				return nil, ErrAbort
+			}
+
+			return nil, fmt.Errorf("failed to read answer: %w", err)
+		}
+
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "o", "ours":
+			return hunk.Lines(ChoiceOurs), nil
+		case "t", "theirs":
+			return hunk.Lines(ChoiceTheirs), nil
+		case "b", "both":
+			return hunk.Lines(ChoiceBoth), nil
+		case "e", "edit":
+			lines, err := h.edit(hunk)
+			if err != nil {
+				fmt.Fprintf(h.out(), "could not use edited hunk: %s\n", err)
+				continue
+			}
+
+			return lines, nil
+		case "a", "abort":
+			return nil, ErrAbort
+		case "s", "skip":
+			if h.step.Skippable {
+				return nil, ErrSkip
+			}
+		}
+
+		fmt.Fprintf(h.out(), "unrecognized answer '%s'\n", strings.TrimSpace(answer))
+	}
+}
+
+func (h *HunkResolver) resolveFile(wt *git.Worktree, in *bufio.Reader, file string) error {
+	path := filepath.Join(wt.Filesystem.Root(), file)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+
+	conflicts, err := ParseConflicts(data)
+	if err != nil {
+		return fmt.Errorf("failed to parse conflicts: %w", err)
+	}
+
+	hunks := conflicts.Hunks()
+	resolved := make([][]string, 0, len(hunks))
+
+	for i, chunk := range conflicts.Chunks {
+		if chunk.Hunk == nil {
+			continue
+		}
+
+		var context []string
+		if i > 0 && conflicts.Chunks[i-1].Hunk == nil {
+			lines := conflicts.Chunks[i-1].Lines
+			context = lines[max(len(lines)-hunkContextLines, 0):]
+		}
+
+		fmt.Fprintf(h.out(), "\n%s (hunk %d of %d)\n", file, len(resolved)+1, len(hunks))
+
+		lines, err := h.resolveHunk(in, chunk.Hunk, context)
+		if err != nil {
+			return err
+		}
+
+		resolved = append(resolved, lines)
+	}
+
+	data, err = conflicts.Resolve(resolved)
+	if err != nil {
+		return fmt.Errorf("failed to resolve file: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write resolved file: %w", err)
+	}
+
+	return stage(wt, file)
+}
+
+func (h *HunkResolver) Resolve(wt *git.Worktree) error {
+	input := h.In
+	if input == nil {
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			if h.Fallback == nil {
+				return fmt.Errorf("stdin is not a terminal and no fallback resolver was given")
+			}
+
+			h.logger().Info("stdin is not a terminal, falling back to another resolver")
+
+			return h.Fallback.Resolve(wt)
+		}
+
+		input = os.Stdin
+	}
+
+	files, err := Conflicts(wt.Filesystem.Root())
+	if err != nil {
+		return err
+	}
+
+	if len(files) == 0 && h.step.ValidationError != nil {
+		return resolveInvalid(wt, h.step, h.Fallback, input, h.out())
+	}
+
+	marked, unmarked, err := splitByMarkers(wt.Filesystem.Root(), files)
+	if err != nil {
+		return err
+	}
+
+	if h.step.Index > 0 {
+		fmt.Fprintf(h.out(), "resolving %s\n", h.step)
+	}
+
+	fmt.Fprintf(h.out(), "found %d file(s) with conflicts\n", len(files))
+
+	in := bufio.NewReader(input)
+
+	for _, file := range marked {
+		if err := h.resolveFile(wt, in, file); err != nil {
+			return err
+		}
+	}
+
+	if len(unmarked) > 0 {
+		return resolveUnmarked(wt, unmarked, h.Fallback, in, h.out())
+	}
+
+	return nil
+}
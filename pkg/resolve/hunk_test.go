@@ -0,0 +1,342 @@
+package resolve_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/joshmeranda/chartsutil/pkg/resolve"
+	"golang.org/x/term"
+)
+
+func setupConflictRepo(t *testing.T) *git.Worktree {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatalf("failed to init repo: %v", err)
+	}
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("failed to get worktree: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "Chart.yaml"), []byte("apiVersion: v2\n"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	if _, err := wt.Add("Chart.yaml"); err != nil {
+		t.Fatalf("failed to stage file: %v", err)
+	}
+
+	if _, err := wt.Commit("initial commit", &git.CommitOptions{Author: &object.Signature{Name: "chartsutil-test"}}); err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+
+	// leave the file unmerged in the index like a merge would, so it is reported as conflicted by git
+	blob := strings.TrimSpace(runGit(t, dir, "rev-parse", "HEAD:Chart.yaml"))
+
+	cmd := exec.Command("git", "update-index", "--index-info")
+	cmd.Dir = dir
+	cmd.Stdin = strings.NewReader(fmt.Sprintf("0 %[2]s 0\tChart.yaml\n100644 %[1]s 1\tChart.yaml\n100644 %[1]s 2\tChart.yaml\n100644 %[1]s 3\tChart.yaml\n", blob, plumbing.ZeroHash))
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("failed to unmerge file: %v: %s", err, output)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "Chart.yaml"), []byte(conflictContent), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	return wt
+}
+
+// runGit runs git in dir, returning its output.
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+
+	cmd := exec.Command("git", append([]string{"-c", "user.name=chartsutil-test", "-c", "user.email=test@example.com"}, args...)...)
+	cmd.Dir = dir
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); !ok {
+			t.Fatalf("failed to run git %v: %v", args, err)
+		}
+	}
+
+	return string(output)
+}
+
+// setupMergeConflictRepo creates a repository with Chart.yaml left conflicted in the index by a squash merge, like the rebase does.
+func setupMergeConflictRepo(t *testing.T) *git.Worktree {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	runGit(t, dir, "init", "--initial-branch", "main")
+
+	write := func(content string) {
+		if err := os.WriteFile(filepath.Join(dir, "Chart.yaml"), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+
+	write("apiVersion: v2\nversion: 1.0.0\n")
+	runGit(t, dir, "add", "Chart.yaml")
+	runGit(t, dir, "commit", "-m", "initial commit")
+
+	runGit(t, dir, "checkout", "-b", "upstream")
+	write("apiVersion: v2\nversion: 1.1.0\n")
+	runGit(t, dir, "commit", "-am", "upstream change")
+
+	runGit(t, dir, "checkout", "main")
+	write("apiVersion: v2\nversion: 1.0.1\n")
+	runGit(t, dir, "commit", "-am", "local change")
+
+	runGit(t, dir, "merge", "--squash", "--no-commit", "upstream")
+
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		t.Fatalf("failed to open repo: %v", err)
+	}
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("failed to get worktree: %v", err)
+	}
+
+	return wt
+}
+
+// assertNoUnmergedStages checks that the index has a single entry for each file, which is all go-git will commit correctly.
+func assertNoUnmergedStages(t *testing.T, wt *git.Worktree) {
+	t.Helper()
+
+	if unmerged := runGit(t, wt.Filesystem.Root(), "ls-files", "--unmerged"); unmerged != "" {
+		t.Errorf("expected no unmerged index entries but found:\n%s", unmerged)
+	}
+}
+
+func TestHunkResolver(t *testing.T) {
+	wt := setupConflictRepo(t)
+
+	resolver := &resolve.HunkResolver{
+		In:  strings.NewReader("x\nt\no\n"),
+		Out: io.Discard,
+	}
+
+	if err := resolver.Resolve(wt); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(wt.Filesystem.Root(), "Chart.yaml"))
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+
+	expected := "apiVersion: v2\nname: example\nversion: 1.1.0\ndescription: an example chart\nappVersion: 1.0.0\n"
+	if string(data) != expected {
+		t.Errorf("resolved file does not match expected value:\nExpected: '%s'\n   Found: '%s'", expected, data)
+	}
+
+	status, err := wt.Status()
+	if err != nil {
+		t.Fatalf("failed to get status: %v", err)
+	}
+
+	if info := status.File("Chart.yaml"); info.Worktree != git.Unmodified {
+		t.Errorf("expected resolved file to be staged but found worktree status '%c'", info.Worktree)
+	}
+}
+
+func TestHunkResolverAbort(t *testing.T) {
+	wt := setupConflictRepo(t)
+
+	resolver := &resolve.HunkResolver{
+		In:  strings.NewReader("a\n"),
+		Out: io.Discard,
+	}
+
+	if err := resolver.Resolve(wt); !errors.Is(err, resolve.ErrAbort) {
+		t.Fatalf("expected abort but found: %v", err)
+	}
+}
+
+func TestHunkResolverMergeConflict(t *testing.T) {
+	wt := setupMergeConflictRepo(t)
+
+	resolver := &resolve.HunkResolver{
+		In:  strings.NewReader("t\n"),
+		Out: io.Discard,
+	}
+
+	if err := resolver.Resolve(wt); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	assertNoUnmergedStages(t, wt)
+}
+
+// setupModifyDeleteRepo creates a repository left by a squash merge with a conflict in Chart.yaml and values.yaml deleted locally but modified by the upstream, which has no conflict markers.
+func setupModifyDeleteRepo(t *testing.T) *git.Worktree {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	write := func(file string, content string) {
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+
+	runGit(t, dir, "init", "--initial-branch", "main")
+
+	write("Chart.yaml", "apiVersion: v2\nversion: 1.0.0\n")
+	write("values.yaml", "replicas: 1\n")
+	runGit(t, dir, "add", ".")
+	runGit(t, dir, "commit", "-m", "initial commit")
+
+	runGit(t, dir, "checkout", "-b", "upstream")
+	write("Chart.yaml", "apiVersion: v2\nversion: 1.1.0\n")
+	write("values.yaml", "replicas: 2\n")
+	runGit(t, dir, "commit", "-am", "upstream change")
+
+	runGit(t, dir, "checkout", "main")
+	write("Chart.yaml", "apiVersion: v2\nversion: 1.0.1\n")
+	runGit(t, dir, "rm", "-q", "values.yaml")
+	runGit(t, dir, "commit", "-am", "local change")
+
+	runGit(t, dir, "merge", "--squash", "--no-commit", "upstream")
+
+	if conflicts, _ := resolve.Conflicts(dir); len(conflicts) != 2 {
+		t.Fatalf("expected Chart.yaml and values.yaml to conflict but found %v", conflicts)
+	}
+
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		t.Fatalf("failed to open repo: %v", err)
+	}
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("failed to get worktree: %v", err)
+	}
+
+	return wt
+}
+
+func TestHunkResolverModifyDelete(t *testing.T) {
+	t.Run("Fallback", func(t *testing.T) {
+		wt := setupModifyDeleteRepo(t)
+
+		fallback := &recordingResolver{}
+
+		resolver := &resolve.HunkResolver{
+			Fallback: fallback,
+			In:       strings.NewReader("t\n"),
+			Out:      io.Discard,
+		}
+
+		if err := resolver.Resolve(wt); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if !fallback.called {
+			t.Errorf("expected conflict without markers to be handed to the fallback resolver")
+		}
+
+		conflicts, err := resolve.Conflicts(wt.Filesystem.Root())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(conflicts) != 1 || conflicts[0] != "values.yaml" {
+			t.Errorf("expected only values.yaml to be left for the fallback but found %v", conflicts)
+		}
+	})
+
+	t.Run("Abort", func(t *testing.T) {
+		wt := setupModifyDeleteRepo(t)
+
+		resolver := &resolve.HunkResolver{
+			In:  strings.NewReader("t\na\n"),
+			Out: io.Discard,
+		}
+
+		if err := resolver.Resolve(wt); !errors.Is(err, resolve.ErrAbort) {
+			t.Fatalf("expected abort but found: %v", err)
+		}
+	})
+}
+
+func TestHunkResolverNotTerminal(t *testing.T) {
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		t.Skip("stdin is a terminal")
+	}
+
+	wt := setupConflictRepo(t)
+
+	fallback := &recordingResolver{}
+
+	// no logger is given, like callers which only care about the fallback
+	resolver := &resolve.HunkResolver{
+		Fallback: fallback,
+		Out:      io.Discard,
+	}
+
+	if err := resolver.Resolve(wt); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !fallback.called {
+		t.Errorf("expected resolver to fall back when stdin is not a terminal")
+	}
+}
+
+// recordingResolver records whether it was asked to resolve the worktree.
+type recordingResolver struct {
+	called bool
+}
+
+func (r *recordingResolver) Resolve(*git.Worktree) error {
+	r.called = true
+	return nil
+}
+
+func TestHunkResolverValidationError(t *testing.T) {
+	wt := setupConflictRepo(t)
+
+	if err := os.WriteFile(filepath.Join(wt.Filesystem.Root(), "Chart.yaml"), []byte("apiVersion: v2\n"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	fallback := &recordingResolver{}
+
+	resolver := &resolve.HunkResolver{
+		Fallback: fallback,
+		In:       strings.NewReader(""),
+		Out:      io.Discard,
+	}
+
+	resolver.SetStep(resolve.Step{ValidationError: errors.New("chart failed to lint")})
+
+	if err := resolver.Resolve(wt); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !fallback.called {
+		t.Errorf("expected worktree which failed validation to be handed to the fallback resolver")
+	}
+}
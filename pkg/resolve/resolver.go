@@ -2,12 +2,18 @@ package resolve
 
 import (
 	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
 
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
 )
 
 var (
 	ErrAbort = fmt.Errorf("rebase aborted by user")
+	ErrSkip  = fmt.Errorf("upstream skipped by user")
 )
 
 // Resolver defines how to handle conflicts between the stating and quarantine brnanches, and stages the conflicting files in git once resolved.
@@ -15,6 +21,112 @@ type Resolver interface {
 	Resolve(*git.Worktree) error
 }
 
+// MergeConflict is a single conflict reported by git when merging an upstream.
+type MergeConflict struct {
+	// Kind is the type of conflict (ex. 'content' or 'modify/delete').
+	Kind    string
+	Path    string
+	Message string
+}
+
+// Rename is a file renamed by the upstream.
+type Rename struct {
+	From string
+	To   string
+}
+
+// MergeResult describes the outcome of merging an upstream into the charts.
+type MergeResult struct {
+	AutoMerged []string
+	Conflicts  []MergeConflict
+	Renamed    []Rename
+}
+
+func (m MergeResult) HasConflicts() bool {
+	return len(m.Conflicts) > 0
+}
+
+// Step describes the upstream currently being merged into the charts.
+type Step struct {
+	// Index is the 1-based position of the current upstream in the rebase.
+	Index int
+
+	// Total is the number of upstreams expected in the rebase, or 0 if unknown.
+	Total int
+
+	// Upstream is a human readable reference to the upstream being merged.
+	Upstream string
+
+	// UpstreamCommit is the commit on the staging branch containing the unmodified upstream charts.
+	UpstreamCommit plumbing.Hash
+
+	// PreviousUpstreamCommit is the commit containing the unmodified charts of the previous upstream, or the zero hash if not known.
+	PreviousUpstreamCommit plumbing.Hash
+
+	// Merge is the result of merging the upstream into the charts.
+	Merge MergeResult
+
+	// Skippable is true when the resolver may return ErrSkip to move on to the next upstream.
+	Skippable bool
+
+	// ValidationError is why the worktree failed validation after the resolver last returned, or nil if it has not been validated yet.
+	ValidationError error
+}
+
+// StepResolver is a Resolver which is told about each upstream step before being asked to resolve it.
+type StepResolver interface {
+	Resolver
+
+	SetStep(Step)
+}
+
+// stage adds the file to the index with git, since go-git only updates the first of the stages a conflicted file is left with by a merge and the others would be committed as duplicate entries.
+func stage(wt *git.Worktree, file string) error {
+	cmd := exec.Command("git", "add", "--", file)
+	cmd.Dir = wt.Filesystem.Root()
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to stage file %s: %s", file, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
+
+// resolveInvalid handles a worktree which has no conflicts left but failed validation, by handing it to fallback if there is one or else asking the user whether to validate it again or abort. Resolvers which only look for conflicts would otherwise return straight away and be asked to resolve the same worktree forever.
+func resolveInvalid(wt *git.Worktree, step Step, fallback Resolver, in io.Reader, out io.Writer) error {
+	if out == nil {
+		out = os.Stdout
+	}
+
+	fmt.Fprintf(out, "no conflicts remain but the worktree failed validation: %s\n", step.ValidationError)
+
+	if fallback != nil {
+		return fallback.Resolve(wt)
+	}
+
+	fmt.Fprintln(out, "fix the worktree from another terminal before retrying")
+
+	return promptRetry(in, out)
+}
+
+// resolveUnmarked handles conflicts which have no markers to resolve (ex. a file modified on one side and deleted on the other, or binary files), by handing them to fallback if there is one or else asking the user to resolve them elsewhere. They would otherwise be committed as whatever git left in the worktree.
+func resolveUnmarked(wt *git.Worktree, unmarked []string, fallback Resolver, in io.Reader, out io.Writer) error {
+	if out == nil {
+		out = os.Stdout
+	}
+
+	printRemaining(out, unmarked)
+	fmt.Fprintln(out, "these conflicts have no conflict markers to resolve")
+
+	if fallback != nil {
+		return fallback.Resolve(wt)
+	}
+
+	fmt.Fprintln(out, "resolve them from another terminal before retrying")
+
+	return promptRetry(in, out)
+}
+
 // Aborter immediately aborts the rebase.
 type Aborter struct{}
 
@@ -5,6 +5,9 @@ import (
 	"log/slog"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
 
 	"github.com/go-git/go-billy/v5"
 	"github.com/go-git/go-git/v5"
@@ -21,16 +24,188 @@ Once the index is in the desired state add all changes and run 'exit'!
 
 To abort the rebase at any time run 'abort'!`
 
+	ShellPrompt = "(interactive-rebase-shell)> "
+
 	AbortFileName = ".abort_rebase"
+	SkipFileName  = ".skip_rebase"
+)
+
+type shellKind int
+
+const (
+	shellBash shellKind = iota
+	shellZsh
+	shellFish
 )
 
-func getShellRcContents() []byte {
-	return []byte(fmt.Sprintf(`PS1="(interactive-rebase-shell)> "; alias abort='touch %s && exit'; echo '%s'`, AbortFileName, ShellWelcomeMessage))
+// shellHelper is a command made available in the interactive shell.
+type shellHelper struct {
+	Name  string
+	Usage string
+}
+
+// ShellHelpers are the helpers which call back into chartsutil, the name of each is also the name of the chartsutil subcommand it calls.
+var ShellHelpers = []shellHelper{
+	{Name: "conflicts", Usage: "list files with unresolved conflicts"},
+	{Name: "upstream-diff", Usage: "show what upstream changed in <file> since the last step"},
+	{Name: "patch-diff", Usage: "show the generated changes for <file>"},
+	{Name: "validate", Usage: "run the validators against the current worktree"},
+	{Name: "status", Usage: "show the current upstream step"},
+}
+
+// aliasName returns the name of the helper's alias in the given shell, since fish relies on its own 'status' builtin we can't shadow it.
+func (h shellHelper) aliasName(kind shellKind) string {
+	if kind == shellFish && h.Name == "status" {
+		return "rebase-status"
+	}
+
+	return h.Name
+}
+
+// getShellKind returns the kind of the shell at the given path, any shell we do not know the flags of is treated as bash and replaced by it.
+func getShellKind(shell string) shellKind {
+	switch filepath.Base(shell) {
+	case "zsh":
+		return shellZsh
+	case "fish":
+		return shellFish
+	default:
+		return shellBash
+	}
+}
+
+// quote wraps s in double quotes so it can be used as a single word inside of a single-quoted alias.
+func quote(s string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`, "`", "\\`", `'`, `'\''`)
+	return `"` + replacer.Replace(s) + `"`
 }
 
 type Shell struct {
 	Logger  *slog.Logger
 	Package *charts.Package
+
+	// HelperCommand is the command used to call back into chartsutil for the shell helpers (ex. 'chartsutil --package foo shell'). If empty, only 'abort' and 'skip' are available.
+	HelperCommand []string
+
+	step Step
+}
+
+func (s *Shell) SetStep(step Step) {
+	s.step = step
+}
+
+func (s *Shell) aliases(kind shellKind) map[string]string {
+	aliases := map[string]string{
+		"abort": fmt.Sprintf("touch %s && exit", AbortFileName),
+	}
+
+	if s.step.Skippable {
+		aliases["skip"] = fmt.Sprintf("touch %s && exit", SkipFileName)
+	}
+
+	if len(s.HelperCommand) > 0 {
+		quoted := make([]string, len(s.HelperCommand))
+		for i, arg := range s.HelperCommand {
+			quoted[i] = quote(arg)
+		}
+		prefix := strings.Join(quoted, " ")
+
+		for _, helper := range ShellHelpers {
+			aliases[helper.aliasName(kind)] = prefix + " " + helper.Name
+		}
+	}
+
+	return aliases
+}
+
+func (s *Shell) getShellRcContents(kind shellKind) []byte {
+	b := strings.Builder{}
+
+	switch kind {
+	case shellFish:
+		b.WriteString(fmt.Sprintf("function fish_prompt; echo -n '%s'; end\n", ShellPrompt))
+	case shellZsh:
+		b.WriteString(fmt.Sprintf("PROMPT='%s'\n", ShellPrompt))
+	default:
+		b.WriteString(fmt.Sprintf("PS1='%s'\n", ShellPrompt))
+	}
+
+	aliases := s.aliases(kind)
+	names := make([]string, 0, len(aliases))
+	for name := range aliases {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		b.WriteString(fmt.Sprintf("alias %s='%s'\n", name, aliases[name]))
+	}
+
+	return []byte(b.String())
+}
+
+func (s *Shell) welcomeMessage(kind shellKind) string {
+	b := strings.Builder{}
+
+	b.WriteString(ShellWelcomeMessage)
+	b.WriteRune('\n')
+
+	if s.step.Skippable {
+		b.WriteString("To skip this upstream and move on to the next run 'skip'!\n")
+	}
+
+	if len(s.HelperCommand) > 0 {
+		b.WriteString("\nAvailable helpers:\n")
+		for _, helper := range ShellHelpers {
+			b.WriteString(fmt.Sprintf("  %-14s %s\n", helper.aliasName(kind), helper.Usage))
+		}
+	}
+
+	if s.step.Index > 0 {
+		b.WriteString(fmt.Sprintf("\nCurrently on %s\n", s.step))
+	}
+
+	if s.step.ValidationError != nil {
+		b.WriteString(fmt.Sprintf("\nThe worktree failed validation: %s\n", s.step.ValidationError))
+	}
+
+	if s.step.Merge.HasConflicts() {
+		b.WriteString("\nConflicts:\n")
+		for _, conflict := range s.step.Merge.Conflicts {
+			b.WriteString(fmt.Sprintf("  (%s) %s\n", conflict.Kind, conflict.Path))
+		}
+	}
+
+	return b.String()
+}
+
+// command builds the command to launch the user's shell using the rc file in rcDir.
+func (s *Shell) command(kind shellKind, shell string, rcDir string) (*exec.Cmd, error) {
+	rcFile := filepath.Join(rcDir, "rc")
+	if kind == shellZsh {
+		// zsh has no --rcfile so we point ZDOTDIR at a directory containing our rc file
+		rcFile = filepath.Join(rcDir, ".zshrc")
+	}
+
+	if err := os.WriteFile(rcFile, s.getShellRcContents(kind), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write to shell rc file: %w", err)
+	}
+
+	switch kind {
+	case shellZsh:
+		cmd := exec.Command(shell, "-i")
+		cmd.Env = append(cmd.Environ(), "ZDOTDIR="+rcDir)
+		return cmd, nil
+	case shellFish:
+		return exec.Command(shell, "--init-command", "source "+rcFile), nil
+	default:
+		// shells we do not know the flags of (ex. sh or tcsh) would fail to start with bash's
+		if filepath.Base(shell) != "bash" {
+			shell = "bash"
+		}
+
+		return exec.Command(shell, "--rcfile", rcFile, "-i"), nil
+	}
 }
 
 func (s *Shell) shouldAbort(fs billy.Filesystem) bool {
@@ -38,26 +213,34 @@ func (s *Shell) shouldAbort(fs billy.Filesystem) bool {
 	return err == nil
 }
 
+func (s *Shell) shouldSkip(fs billy.Filesystem) bool {
+	_, err := fs.Stat(SkipFileName)
+	return err == nil
+}
+
 func (s *Shell) Resolve(wt *git.Worktree) error {
-	f, err := os.CreateTemp("", "rebase-shell-rc-*")
+	shell := os.Getenv("SHELL")
+	kind := getShellKind(shell)
+
+	rcDir, err := os.MkdirTemp("", "rebase-shell-rc-*")
 	if err != nil {
-		return fmt.Errorf("failed to create shell rc file: %w", err)
+		return fmt.Errorf("failed to create shell rc dir: %w", err)
 	}
+	defer os.RemoveAll(rcDir)
 
-	if _, err := f.Write(getShellRcContents()); err != nil {
-		return fmt.Errorf("failed to write to shell rc file: %w", err)
-	}
-	if err := f.Close(); err != nil {
-		return fmt.Errorf("failed to close shell rc file: %w", err)
+	cmd, err := s.command(kind, shell, rcDir)
+	if err != nil {
+		return err
 	}
-	defer os.Remove(f.Name())
 
-	cmd := exec.Command("bash", "--rcfile", f.Name(), "-i")
 	cmd.Dir = wt.Filesystem.Root()
+	cmd.Env = append(cmd.Environ(), StepEnv(s.step)...)
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
+	fmt.Println(s.welcomeMessage(kind))
+
 	err = cmd.Run()
 	_, isExitErr := err.(*exec.ExitError)
 	if err != nil && !isExitErr {
@@ -72,5 +255,13 @@ func (s *Shell) Resolve(wt *git.Worktree) error {
 		return ErrAbort
 	}
 
+	if s.shouldSkip(wt.Filesystem) {
+		if err := wt.Filesystem.Remove(SkipFileName); err != nil {
+			s.Logger.Error("failed to remove skip file", "err", err)
+		}
+
+		return ErrSkip
+	}
+
 	return nil
 }
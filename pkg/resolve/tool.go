@@ -0,0 +1,226 @@
+package resolve
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+)
+
+// printRemaining lists the files which still have conflicts.
+func printRemaining(out io.Writer, remaining []string) {
+	if out == nil {
+		out = os.Stdout
+	}
+
+	fmt.Fprintf(out, "%d file(s) still have conflicts:\n", len(remaining))
+	for _, file := range remaining {
+		fmt.Fprintf(out, "  %s\n", file)
+	}
+}
+
+// promptRetry asks the user whether to retry resolving the worktree or abort the rebase, returning ErrAbort if they choose to abort.
+func promptRetry(in io.Reader, out io.Writer) error {
+	if in == nil {
+		in = os.Stdin
+	}
+
+	if out == nil {
+		out = os.Stdout
+	}
+
+	reader := bufio.NewReader(in)
+
+	for {
+		fmt.Fprint(out, "[r]etry, [a]bort? ")
+
+		answer, err := reader.ReadString('\n')
+		if err != nil && answer == "" {
+			return ErrAbort
+		}
+
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "r", "retry":
+			return nil
+		case "a", "abort":
+			return ErrAbort
+		}
+	}
+}
+
+// Mergetool opens each unmerged file with 'git mergetool' using the tool configured by the user.
+type Mergetool struct {
+	Logger *slog.Logger
+
+	// Tool overrides the configured merge tool when not empty.
+	Tool string
+
+	// Fallback is used when no conflicts remain but the worktree failed validation, if nil the user is asked whether to retry or abort.
+	Fallback Resolver
+
+	// In and Out are used to prompt the user when conflicts remain, defaulting to os.Stdin and os.Stdout.
+	In  io.Reader
+	Out io.Writer
+
+	step Step
+}
+
+func (m *Mergetool) SetStep(step Step) {
+	m.step = step
+
+	if sr, ok := m.Fallback.(StepResolver); ok {
+		sr.SetStep(step)
+	}
+}
+
+func (m *Mergetool) Resolve(wt *git.Worktree) error {
+	root := wt.Filesystem.Root()
+
+	files, err := Conflicts(root)
+	if err != nil {
+		return err
+	}
+
+	if len(files) == 0 && m.step.ValidationError != nil {
+		return resolveInvalid(wt, m.step, m.Fallback, m.In, m.Out)
+	}
+
+	for _, file := range files {
+		// backups would be left as untracked files in the package and fail validation
+		args := []string{"-c", "mergetool.keepBackup=false", "mergetool", "--no-prompt"}
+		if m.Tool != "" {
+			args = append(args, "--tool", m.Tool)
+		}
+		args = append(args, file)
+
+		cmd := exec.Command("git", args...)
+		cmd.Dir = root
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+
+		// mergetool exits non-zero when the user did not resolve the file, which will be reported below
+		if err := cmd.Run(); err != nil {
+			if _, ok := err.(*exec.ExitError); !ok {
+				return fmt.Errorf("could not run '%s': %w", cmd.String(), err)
+			}
+
+			m.Logger.Warn("merge tool did not resolve file", "file", file)
+		}
+	}
+
+	remaining, err := Conflicts(root)
+	if err != nil {
+		return err
+	}
+
+	if len(remaining) > 0 {
+		printRemaining(m.Out, remaining)
+		return promptRetry(m.In, m.Out)
+	}
+
+	return nil
+}
+
+// Editor opens each file with conflict markers in the user's editor one by one, staging each once it no longer contains any conflict markers.
+type Editor struct {
+	Logger *slog.Logger
+
+	// Fallback is used for conflicts without conflict markers, and when no conflicts remain but the worktree failed validation. If nil the user is asked whether to retry or abort.
+	Fallback Resolver
+
+	// In and Out are used to prompt the user when conflicts remain, defaulting to os.Stdin and os.Stdout.
+	In  io.Reader
+	Out io.Writer
+
+	step Step
+}
+
+func (e *Editor) SetStep(step Step) {
+	e.step = step
+
+	if sr, ok := e.Fallback.(StepResolver); ok {
+		sr.SetStep(step)
+	}
+}
+
+// editFile opens the file in the editor until it no longer contains conflict markers, returning false if the user gave up on it by saving without changes.
+func (e *Editor) editFile(path string) (bool, error) {
+	for {
+		before, err := os.ReadFile(path)
+		if err != nil {
+			return false, fmt.Errorf("failed to read file: %w", err)
+		}
+
+		if err := RunEditor(path); err != nil {
+			return false, err
+		}
+
+		after, err := os.ReadFile(path)
+		if err != nil {
+			return false, fmt.Errorf("failed to read file: %w", err)
+		}
+
+		if !HasConflictMarkers(after) {
+			return true, nil
+		}
+
+		if bytes.Equal(before, after) {
+			return false, nil
+		}
+
+		e.Logger.Warn("file still contains conflict markers, reopening", "file", path)
+	}
+}
+
+func (e *Editor) Resolve(wt *git.Worktree) error {
+	files, err := Conflicts(wt.Filesystem.Root())
+	if err != nil {
+		return err
+	}
+
+	if len(files) == 0 && e.step.ValidationError != nil {
+		return resolveInvalid(wt, e.step, e.Fallback, e.In, e.Out)
+	}
+
+	marked, unmarked, err := splitByMarkers(wt.Filesystem.Root(), files)
+	if err != nil {
+		return err
+	}
+
+	remaining := make([]string, 0)
+
+	for _, file := range marked {
+		resolved, err := e.editFile(filepath.Join(wt.Filesystem.Root(), file))
+		if err != nil {
+			return fmt.Errorf("failed to edit file %s: %w", file, err)
+		}
+
+		if !resolved {
+			remaining = append(remaining, file)
+			continue
+		}
+
+		if err := stage(wt, file); err != nil {
+			return err
+		}
+	}
+
+	if len(remaining) > 0 {
+		printRemaining(e.Out, remaining)
+		return promptRetry(e.In, e.Out)
+	}
+
+	if len(unmarked) > 0 {
+		return resolveUnmarked(wt, unmarked, e.Fallback, e.In, e.Out)
+	}
+
+	return nil
+}
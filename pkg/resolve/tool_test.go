@@ -0,0 +1,180 @@
+package resolve_test
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/joshmeranda/chartsutil/pkg/resolve"
+)
+
+// setEditorScript points $EDITOR at a script with the given body, which receives the file to edit as $1.
+func setEditorScript(t *testing.T, body string) {
+	t.Helper()
+
+	script := filepath.Join(t.TempDir(), "editor.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\n"+body+"\n"), 0755); err != nil {
+		t.Fatalf("failed to write editor script: %v", err)
+	}
+
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", script)
+}
+
+func TestEditor(t *testing.T) {
+	wt := setupConflictRepo(t)
+	setEditorScript(t, `printf 'apiVersion: v2\n' > "$1"`)
+
+	resolver := &resolve.Editor{
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Out:    io.Discard,
+	}
+
+	if err := resolver.Resolve(wt); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	status, err := wt.Status()
+	if err != nil {
+		t.Fatalf("failed to get status: %v", err)
+	}
+
+	if !status.IsClean() {
+		t.Errorf("expected resolved file to be staged and clean but found:\n%s", status)
+	}
+}
+
+func TestEditorUnresolved(t *testing.T) {
+	wt := setupConflictRepo(t)
+	setEditorScript(t, "true")
+
+	resolver := &resolve.Editor{
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		In:     strings.NewReader("a\n"),
+		Out:    io.Discard,
+	}
+
+	if err := resolver.Resolve(wt); !errors.Is(err, resolve.ErrAbort) {
+		t.Fatalf("expected abort but found: %v", err)
+	}
+
+	status, err := wt.Status()
+	if err != nil {
+		t.Fatalf("failed to get status: %v", err)
+	}
+
+	if info := status.File("Chart.yaml"); info.Worktree != git.Modified {
+		t.Errorf("expected unresolved file to not be staged but found worktree status '%c'", info.Worktree)
+	}
+}
+
+func TestEditorMergeConflict(t *testing.T) {
+	wt := setupMergeConflictRepo(t)
+	setEditorScript(t, `printf 'apiVersion: v2\nversion: 1.1.0\n' > "$1"`)
+
+	resolver := &resolve.Editor{
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Out:    io.Discard,
+	}
+
+	if err := resolver.Resolve(wt); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	assertNoUnmergedStages(t, wt)
+}
+
+func TestEditorModifyDelete(t *testing.T) {
+	wt := setupModifyDeleteRepo(t)
+	setEditorScript(t, `printf 'apiVersion: v2\nversion: 1.1.0\n' > "$1"`)
+
+	fallback := &recordingResolver{}
+
+	resolver := &resolve.Editor{
+		Logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Fallback: fallback,
+		Out:      io.Discard,
+	}
+
+	if err := resolver.Resolve(wt); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !fallback.called {
+		t.Errorf("expected conflict without markers to be handed to the fallback resolver")
+	}
+
+	conflicts, err := resolve.Conflicts(wt.Filesystem.Root())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(conflicts) != 1 || conflicts[0] != "values.yaml" {
+		t.Errorf("expected only values.yaml to be left for the fallback but found %v", conflicts)
+	}
+}
+
+func TestToolValidationError(t *testing.T) {
+	type testCase struct {
+		Name     string
+		Resolver func(fallback resolve.Resolver, in io.Reader) resolve.StepResolver
+	}
+
+	cases := []testCase{
+		{
+			Name: "Editor",
+			Resolver: func(fallback resolve.Resolver, in io.Reader) resolve.StepResolver {
+				return &resolve.Editor{Logger: slog.New(slog.NewTextHandler(io.Discard, nil)), Fallback: fallback, In: in, Out: io.Discard}
+			},
+		},
+		{
+			Name: "Mergetool",
+			Resolver: func(fallback resolve.Resolver, in io.Reader) resolve.StepResolver {
+				return &resolve.Mergetool{Logger: slog.New(slog.NewTextHandler(io.Discard, nil)), Fallback: fallback, In: in, Out: io.Discard}
+			},
+		},
+	}
+
+	step := resolve.Step{ValidationError: errors.New("chart failed to lint")}
+
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			t.Run("Fallback", func(t *testing.T) {
+				wt := setupMergeConflictRepo(t)
+				runGit(t, wt.Filesystem.Root(), "checkout", "--theirs", "Chart.yaml")
+				runGit(t, wt.Filesystem.Root(), "add", "Chart.yaml")
+
+				fallback := &recordingResolver{}
+
+				resolver := c.Resolver(fallback, strings.NewReader(""))
+				resolver.SetStep(step)
+
+				if err := resolver.Resolve(wt); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+
+				if !fallback.called {
+					t.Errorf("expected worktree which failed validation to be handed to the fallback resolver")
+				}
+			})
+
+			t.Run("Abort", func(t *testing.T) {
+				wt := setupMergeConflictRepo(t)
+				runGit(t, wt.Filesystem.Root(), "checkout", "--theirs", "Chart.yaml")
+				runGit(t, wt.Filesystem.Root(), "add", "Chart.yaml")
+
+				resolver := c.Resolver(nil, strings.NewReader("a\n"))
+				resolver.SetStep(step)
+
+				if err := resolver.Resolve(wt); !errors.Is(err, resolve.ErrAbort) {
+					t.Fatalf("expected abort but found: %v", err)
+				}
+			})
+		})
+	}
+}